@@ -99,6 +99,7 @@ class Tudy < Formula
         # LLM_API_KEY=sk-your-key-here
         #
         # Optional settings:
+        # LLM_PROVIDER=openai
         # LLM_API_URL=https://openrouter.ai/api/v1/chat/completions
         # MODEL=anthropic/claude-haiku-4.5
       EOS
@@ -19,6 +19,7 @@ Commands:
   restart     Restart the proxy
   trust       Trust the HTTPS certificate
   logs        Tail the proxy log file
+  eval        Measure routing accuracy against a fixture file (see tudy eval --help)
 
 All other commands (run, version, etc.) are passed through to Caddy.
 `
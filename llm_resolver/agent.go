@@ -0,0 +1,342 @@
+package llm_resolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAgentMaxSteps  = 6
+	defaultAgentMaxTokens = 20000
+
+	// Limits on what inspection tools return to the model
+	maxManifestBytes   = 2000
+	maxPageBytes       = 64 * 1024
+	maxTranscriptChars = 500
+)
+
+// Project manifests read by the read_manifest tool
+var manifestFiles = []string{"package.json", "composer.json", "go.mod"}
+
+var titleRegex = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// inspectClient fetches candidate pages for the fetch_page_title tool
+var inspectClient = &http.Client{Timeout: 3 * time.Second}
+
+// AgentConfig enables the agentic resolution loop, where the model may call
+// read-only inspection tools before answering
+type AgentConfig struct {
+	// MaxSteps is the maximum number of model calls (default: 6)
+	MaxSteps int `json:"max_steps,omitempty"`
+
+	// MaxTokens is the token budget across all steps (default: 20000)
+	MaxTokens int `json:"max_tokens,omitempty"`
+}
+
+// TranscriptStep records one tool call made during agentic resolution
+type TranscriptStep struct {
+	Tool      string `json:"tool"`
+	Arguments string `json:"arguments"`
+	Result    string `json:"result"`
+}
+
+const agentInstructions = `
+
+You can inspect candidates before answering with these read-only tools:
+- read_manifest: read package.json, composer.json or go.mod from a candidate's working directory
+- fetch_page_title: fetch the HTML <title> a candidate serves
+- list_compose_services: list the running services of a Docker Compose project
+Only inspect when the lists are ambiguous. Answer by calling route_choice.`
+
+// askAgent runs the tool-calling loop until the model calls the answer tool.
+// Once the step or token cap is reached, the answer tool is forced. Every
+// tool call is recorded in the returned transcript.
+func (r *Resolver) askAgent(
+	ctx context.Context,
+	res *Resolution,
+	systemPrompt, prompt string,
+	candidates Candidates,
+	processes []LocalProcess,
+	containers []DockerContainer,
+) (*LLMResponse, []TranscriptStep, error) {
+	cfg := r.opts.Agent
+	answer := candidateSchema(candidates.IDs())
+	tools := append(inspectionTools(candidates, containers), answer)
+
+	var messages []Message
+	var transcript []TranscriptStep
+	tokens := 0
+
+	for step := 1; ; step++ {
+		final := step >= cfg.MaxSteps || tokens >= cfg.MaxTokens
+		req := &CompletionRequest{
+			SystemPrompt: systemPrompt + agentInstructions,
+			UserPrompt:   prompt,
+			Messages:     messages,
+			Tools:        tools,
+		}
+		if final {
+			req.ToolChoice = answer.Name
+		}
+
+		resp, err := r.complete(ctx, res, req)
+		if err != nil {
+			return nil, transcript, err
+		}
+		tokens += resp.Usage.Total()
+
+		// Runtimes without tool support answer in plain text
+		if len(resp.ToolCalls) == 0 {
+			reportProgress(ctx, StageValidating, "Checking the answer against discovery")
+			response, err := r.acceptAnswer(res, resp.Content, candidates, processes, containers)
+			return response, transcript, err
+		}
+
+		messages = append(messages, Message{Role: "assistant", Content: resp.Content, ToolCalls: resp.ToolCalls})
+		for _, call := range resp.ToolCalls {
+			var result string
+			if call.Name == answer.Name {
+				reportProgress(ctx, StageValidating, "Checking the answer against discovery")
+				response, err := r.acceptAnswer(res, string(call.Arguments), candidates, processes, containers)
+				if err == nil {
+					transcript = append(transcript, newTranscriptStep(call, "accepted"))
+					return response, transcript, nil
+				}
+				if final {
+					return nil, transcript, err
+				}
+				result = fmt.Sprintf("Answer rejected: %v. Choose a candidate ID from the lists.", err)
+			} else {
+				reportProgress(ctx, StageInspecting, fmt.Sprintf("%s %s", call.Name, call.Arguments))
+				result = runInspectionTool(ctx, call, candidates, containers)
+			}
+
+			r.logger.Debug("agent tool call",
+				zap.Int("step", step),
+				zap.String("tool", call.Name),
+				zap.String("arguments", string(call.Arguments)),
+			)
+			transcript = append(transcript, newTranscriptStep(call, result))
+			messages = append(messages, Message{
+				Role:       "tool",
+				Content:    result,
+				ToolCallID: call.ID,
+				ToolName:   call.Name,
+			})
+		}
+
+		if final {
+			return nil, transcript, fmt.Errorf("agent did not answer within %d steps", cfg.MaxSteps)
+		}
+	}
+}
+
+// acceptAnswer parses and verifies an answer from the agent loop
+func (r *Resolver) acceptAnswer(res *Resolution, content string, candidates Candidates, processes []LocalProcess, containers []DockerContainer) (*LLMResponse, error) {
+	response, err := parseLLMResponse(content)
+	if err != nil {
+		res.check(content, err)
+		return nil, err
+	}
+	if err := validateAnswer(res, response, candidates, processes, containers); err != nil {
+		return nil, fmt.Errorf("invalid LLM response: %w", err)
+	}
+	return response, nil
+}
+
+// newTranscriptStep records a tool call with a truncated result
+func newTranscriptStep(call ToolCall, result string) TranscriptStep {
+	return TranscriptStep{
+		Tool:      call.Name,
+		Arguments: string(call.Arguments),
+		Result:    truncate(result, maxTranscriptChars),
+	}
+}
+
+// inspectionTools returns the read-only tool definitions for the candidates
+func inspectionTools(candidates Candidates, containers []DockerContainer) []*ResponseSchema {
+	candidateParam := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"candidate_id": map[string]interface{}{
+				"type": "string",
+				"enum": candidates.IDs(),
+			},
+		},
+		"required":             []string{"candidate_id"},
+		"additionalProperties": false,
+	}
+
+	projectParam := map[string]interface{}{
+		"type":        "string",
+		"description": "Compose project name",
+	}
+	if projects := composeProjects(containers); len(projects) > 0 {
+		projectParam["enum"] = projects
+	}
+
+	return []*ResponseSchema{
+		{
+			Name:        "read_manifest",
+			Description: "Read package.json, composer.json or go.mod from the candidate's working directory",
+			Schema:      candidateParam,
+		},
+		{
+			Name:        "fetch_page_title",
+			Description: "Fetch the HTML <title> served by the candidate",
+			Schema:      candidateParam,
+		},
+		{
+			Name:        "list_compose_services",
+			Description: "List the running services of a Docker Compose project",
+			Schema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"project": projectParam,
+				},
+				"required":             []string{"project"},
+				"additionalProperties": false,
+			},
+		},
+	}
+}
+
+// runInspectionTool executes a read-only tool and returns its result text
+func runInspectionTool(ctx context.Context, call ToolCall, candidates Candidates, containers []DockerContainer) string {
+	var args struct {
+		CandidateID string `json:"candidate_id"`
+		Project     string `json:"project"`
+	}
+	if err := json.Unmarshal(call.Arguments, &args); err != nil {
+		return fmt.Sprintf("Invalid arguments: %v", err)
+	}
+
+	switch call.Name {
+	case "read_manifest":
+		candidate := candidates.Find(args.CandidateID)
+		if candidate == nil {
+			return fmt.Sprintf("Unknown candidate %q", args.CandidateID)
+		}
+		return readManifest(candidateWorkdir(candidate))
+	case "fetch_page_title":
+		candidate := candidates.Find(args.CandidateID)
+		if candidate == nil {
+			return fmt.Sprintf("Unknown candidate %q", args.CandidateID)
+		}
+		return fetchPageTitle(ctx, candidate)
+	case "list_compose_services":
+		return listComposeServices(args.Project, candidates, containers)
+	default:
+		return fmt.Sprintf("Unknown tool %q", call.Name)
+	}
+}
+
+// candidateWorkdir returns the working directory of a candidate
+func candidateWorkdir(c *Candidate) string {
+	if c.Process != nil {
+		return c.Process.Workdir
+	}
+	return c.Container.Workdir
+}
+
+// readManifest reads the known project manifests from a workdir
+func readManifest(workdir string) string {
+	if workdir == "" {
+		return "Candidate has no known working directory"
+	}
+
+	var b strings.Builder
+	for _, name := range manifestFiles {
+		data, err := os.ReadFile(filepath.Join(workdir, name))
+		if err != nil {
+			continue
+		}
+		if len(data) > maxManifestBytes {
+			data = append(data[:maxManifestBytes], "\n..."...)
+		}
+		b.WriteString(fmt.Sprintf("### %s\n%s\n", name, data))
+	}
+	if b.Len() == 0 {
+		return fmt.Sprintf("No manifest found in %s", workdir)
+	}
+	return b.String()
+}
+
+// fetchPageTitle requests the candidate's root page and extracts its <title>
+func fetchPageTitle(ctx context.Context, c *Candidate) string {
+	addr := fmt.Sprintf("127.0.0.1:%d", c.Port)
+	if c.Container != nil {
+		if hostIP, hostPort, found := GetContainerHostAddress(c.Container.Name, c.Port); found {
+			addr = fmt.Sprintf("%s:%d", hostIP, hostPort)
+		} else if c.Container.IP != "" {
+			addr = fmt.Sprintf("%s:%d", c.Container.IP, c.Port)
+		} else {
+			return "Container address is not reachable"
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+addr+"/", nil)
+	if err != nil {
+		return fmt.Sprintf("Request failed: %v", err)
+	}
+	resp, err := inspectClient.Do(req)
+	if err != nil {
+		return fmt.Sprintf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+	if match := titleRegex.FindSubmatch(body); len(match) > 1 {
+		return fmt.Sprintf("Status %d, title: %s", resp.StatusCode, strings.TrimSpace(string(match[1])))
+	}
+	return fmt.Sprintf("Status %d, no <title> (content-type: %s)", resp.StatusCode, resp.Header.Get("Content-Type"))
+}
+
+// listComposeServices lists the services of a compose project from the discovery snapshot
+func listComposeServices(project string, candidates Candidates, containers []DockerContainer) string {
+	var b strings.Builder
+	for _, container := range containers {
+		if container.Labels["com.docker.compose.project"] != project {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("- %s (container: %s, image: %s)", container.Labels["com.docker.compose.service"], container.Name, container.Image))
+		if ports := candidates.ForContainer(container.Name); len(ports) > 0 {
+			labels := make([]string, len(ports))
+			for i, c := range ports {
+				labels[i] = fmt.Sprintf("%d [%s]", c.Port, c.ID)
+			}
+			b.WriteString(" ports: " + strings.Join(labels, ", "))
+		}
+		b.WriteString("\n")
+	}
+	if b.Len() == 0 {
+		return fmt.Sprintf("No running services in compose project %q", project)
+	}
+	return b.String()
+}
+
+// composeProjects returns the sorted compose project names in the snapshot
+func composeProjects(containers []DockerContainer) []string {
+	seen := make(map[string]bool)
+	var projects []string
+	for _, container := range containers {
+		project := container.Labels["com.docker.compose.project"]
+		if project != "" && !seen[project] {
+			seen[project] = true
+			projects = append(projects, project)
+		}
+	}
+	sort.Strings(projects)
+	return projects
+}
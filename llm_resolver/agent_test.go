@@ -0,0 +1,83 @@
+package llm_resolver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// toolProvider answers each step with the next scripted tool calls and
+// records the requests it was sent
+type toolProvider struct {
+	steps    [][]ToolCall
+	requests []*CompletionRequest
+}
+
+func (p *toolProvider) Name() string  { return "tools" }
+func (p *toolProvider) Model() string { return "test-model" }
+
+func (p *toolProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	p.requests = append(p.requests, req)
+	calls := p.steps[0]
+	if len(p.steps) > 1 {
+		p.steps = p.steps[1:]
+	}
+	return &CompletionResponse{ToolCalls: calls, Usage: Usage{InputTokens: 100, OutputTokens: 10}}, nil
+}
+
+func toolCall(name, args string) ToolCall {
+	return ToolCall{ID: name, Name: name, Arguments: json.RawMessage(args)}
+}
+
+func TestAskAgent(t *testing.T) {
+	workdir := t.TempDir()
+	os.WriteFile(filepath.Join(workdir, "package.json"), []byte(`{"name":"blog"}`), 0o644)
+	processes := []LocalProcess{{Port: 3000, Command: "node", Workdir: workdir}}
+	candidates := buildCandidates(processes, nil)
+
+	provider := &toolProvider{steps: [][]ToolCall{
+		{toolCall("read_manifest", `{"candidate_id":"p1"}`)},
+		{toolCall("route_choice", `{"candidate_id":"p1","reason":"blog manifest"}`)},
+	}}
+	r := NewResolver([]Endpoint{{Provider: provider}}, ResolverOptions{Agent: &AgentConfig{MaxSteps: 6, MaxTokens: 20000}}, zap.NewNop())
+
+	response, transcript, err := r.askAgent(context.Background(), nil, "system", "prompt", candidates, processes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Type != "process" || response.Port != 3000 || response.Workdir != workdir {
+		t.Errorf("response = %+v", response)
+	}
+	if len(transcript) != 2 || transcript[0].Tool != "read_manifest" || !strings.Contains(transcript[0].Result, `"blog"`) {
+		t.Errorf("transcript = %+v", transcript)
+	}
+	if last := provider.requests[1]; len(last.Messages) != 2 || last.Messages[1].Role != "tool" {
+		t.Errorf("second step does not carry the tool result: %+v", last.Messages)
+	}
+}
+
+func TestAskAgentForcesAnswer(t *testing.T) {
+	processes := []LocalProcess{{Port: 3000, Command: "node", Workdir: "/home/dev/blog"}}
+	candidates := buildCandidates(processes, nil)
+
+	// The model keeps inspecting; the last step must call the answer tool
+	provider := &toolProvider{steps: [][]ToolCall{
+		{toolCall("fetch_page_title", `{"candidate_id":"p9"}`)},
+	}}
+	r := NewResolver([]Endpoint{{Provider: provider}}, ResolverOptions{Agent: &AgentConfig{MaxSteps: 2, MaxTokens: 20000}}, zap.NewNop())
+
+	if _, _, err := r.askAgent(context.Background(), nil, "system", "prompt", candidates, processes, nil); err == nil {
+		t.Error("got no error")
+	}
+	if len(provider.requests) != 2 {
+		t.Fatalf("made %d calls, want 2", len(provider.requests))
+	}
+	if provider.requests[0].ToolChoice != "" || provider.requests[1].ToolChoice != "route_choice" {
+		t.Errorf("tool choices = %q, %q", provider.requests[0].ToolChoice, provider.requests[1].ToolChoice)
+	}
+}
@@ -0,0 +1,212 @@
+package llm_resolver
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// maxReasonChars is the length existing mapping reasons are cut to in prompts
+	maxReasonChars = 100
+
+	// charsPerToken approximates tokenization of prompt text (commands, paths)
+	charsPerToken = 4
+
+	// portLabelTokens is the cost of listing one more port of a shown container
+	portLabelTokens = 3
+)
+
+// hostMapping is an existing mapping shown in the prompt
+type hostMapping struct {
+	Host    string
+	Mapping *RouteMapping
+}
+
+// promptContext is the part of the discovery snapshot and existing mappings
+// shown to the model, with counts of what was left out
+type promptContext struct {
+	Candidates Candidates
+	Containers []DockerContainer // sorted by name
+	Mappings   []hostMapping     // most relevant first
+
+	// Corrections are past manual fixes shown as examples, most relevant first
+	Corrections []*Correction
+
+	OmittedProcesses  int
+	OmittedContainers int
+	OmittedMappings   int
+}
+
+// newPromptContext ranks candidates and mappings by lexical overlap with the
+// hostname and, when budget is positive, keeps only the most relevant ones
+// that fit into roughly budget tokens. The most relevant candidate is always
+// kept. Kept candidates stay in ID order so the prompt reads predictably.
+// Corrections come ranked already and take precedence over mappings.
+func newPromptContext(hostname string, candidates Candidates, containers []DockerContainer, mappings Mappings, corrections []*Correction, budget int) *promptContext {
+	terms := relevanceTerms(hostname)
+
+	sortedContainers := make([]DockerContainer, len(containers))
+	copy(sortedContainers, containers)
+	sort.SliceStable(sortedContainers, func(i, j int) bool { return sortedContainers[i].Name < sortedContainers[j].Name })
+
+	ranked := rankMappings(terms, mappings)
+
+	if budget <= 0 {
+		return &promptContext{
+			Candidates:  candidates,
+			Containers:  sortedContainers,
+			Mappings:    ranked,
+			Corrections: corrections,
+		}
+	}
+
+	scores := make([]int, len(candidates))
+	order := make([]int, len(candidates))
+	for i, c := range candidates {
+		scores[i] = candidateScore(terms, c)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	remaining := budget
+	keep := make([]bool, len(candidates))
+	shown := make(map[string]bool)
+	for n, i := range order {
+		c := candidates[i]
+		var cost int
+		switch {
+		case c.Process != nil:
+			cost = estimateTokens(processLine(c))
+		case shown[c.Container.Name]:
+			cost = portLabelTokens
+		default:
+			cost = estimateTokens(containerLine(*c.Container, nil)) + portLabelTokens
+		}
+		if cost > remaining && n > 0 {
+			continue
+		}
+		remaining -= cost
+		keep[i] = true
+		if c.Container != nil {
+			shown[c.Container.Name] = true
+		}
+	}
+
+	pc := &promptContext{}
+	for i, c := range candidates {
+		if keep[i] {
+			pc.Candidates = append(pc.Candidates, c)
+		} else if c.Process != nil {
+			pc.OmittedProcesses++
+		}
+	}
+	for _, container := range sortedContainers {
+		if shown[container.Name] {
+			pc.Containers = append(pc.Containers, container)
+		} else {
+			pc.OmittedContainers++
+		}
+	}
+	for _, correction := range corrections {
+		cost := estimateTokens(correctionLine(correction))
+		if cost > remaining {
+			continue
+		}
+		remaining -= cost
+		pc.Corrections = append(pc.Corrections, correction)
+	}
+	for _, hm := range ranked {
+		cost := estimateTokens(mappingLine(hm))
+		if cost > remaining {
+			pc.OmittedMappings++
+			continue
+		}
+		remaining -= cost
+		pc.Mappings = append(pc.Mappings, hm)
+	}
+
+	return pc
+}
+
+// relevanceTerms splits a hostname into normalized labels and label parts
+// (e.g. "my-app.localhost" yields "myapp", "my" and "app")
+func relevanceTerms(hostname string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		term = normalizeToken(term)
+		if len(term) < 2 || term == "localhost" || seen[term] {
+			return
+		}
+		seen[term] = true
+		terms = append(terms, term)
+	}
+
+	for _, label := range strings.Split(strings.ToLower(hostname), ".") {
+		add(label)
+		for _, part := range strings.FieldsFunc(label, func(r rune) bool { return r == '-' || r == '_' }) {
+			add(part)
+		}
+	}
+	return terms
+}
+
+// candidateScore counts the terms found in a candidate's description
+func candidateScore(terms []string, c Candidate) int {
+	var text string
+	if c.Process != nil {
+		text = c.Process.Workdir + " " + c.Process.Command + " " + c.Process.Args
+	} else {
+		ctr := c.Container
+		text = ctr.Name + " " + ctr.Image + " " + ctr.Workdir + " " +
+			ctr.Labels["com.docker.compose.project"] + " " + ctr.Labels["com.docker.compose.service"]
+	}
+	return overlap(terms, text)
+}
+
+// rankMappings orders mappings by overlap with the terms, newest first on ties
+func rankMappings(terms []string, mappings Mappings) []hostMapping {
+	ranked := make([]hostMapping, 0, len(mappings))
+	scores := make(map[string]int, len(mappings))
+	for host, mapping := range mappings {
+		ranked = append(ranked, hostMapping{Host: host, Mapping: mapping})
+		scores[host] = overlap(terms, host)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if scores[a.Host] != scores[b.Host] {
+			return scores[a.Host] > scores[b.Host]
+		}
+		if a.Mapping.CreatedAt != b.Mapping.CreatedAt {
+			return a.Mapping.CreatedAt > b.Mapping.CreatedAt
+		}
+		return a.Host < b.Host
+	})
+	return ranked
+}
+
+// overlap counts how many terms occur in the normalized text
+func overlap(terms []string, text string) int {
+	text = normalizeToken(text)
+	n := 0
+	for _, term := range terms {
+		if strings.Contains(text, term) {
+			n++
+		}
+	}
+	return n
+}
+
+// estimateTokens approximates the token count of prompt text
+func estimateTokens(s string) int {
+	return (len(s) + charsPerToken - 1) / charsPerToken
+}
+
+// truncate shortens s to at most n characters, marking the cut with "..."
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
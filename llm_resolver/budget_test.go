@@ -0,0 +1,99 @@
+package llm_resolver
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRelevanceTerms(t *testing.T) {
+	got := relevanceTerms("My-App.api.localhost")
+	want := []string{"myapp", "my", "app", "api"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("relevanceTerms = %v, want %v", got, want)
+	}
+}
+
+func TestNewPromptContext(t *testing.T) {
+	var processes []LocalProcess
+	for i := 0; i < 20; i++ {
+		processes = append(processes, LocalProcess{
+			PID:     100 + i,
+			Port:    4000 + i,
+			Command: "node",
+			Args:    "node server.js --some --long --arguments",
+			Workdir: fmt.Sprintf("/home/dev/projects/unrelated-project-%d", i),
+		})
+	}
+	processes = append(processes, LocalProcess{PID: 99, Port: 5173, Command: "node", Args: "vite", Workdir: "/home/dev/projects/shop"})
+	containers := []DockerContainer{
+		{Name: "shop-db-1", Image: "postgres:16", Ports: []int{5432}},
+		{Name: "other-redis-1", Image: "redis:7", Ports: []int{6379}},
+	}
+	mappings := Mappings{
+		"shop.localhost":     {Type: "process", Target: "localhost", Port: 5173},
+		"other.localhost":    {Type: "docker", Target: "other-redis-1", Port: 6379},
+		"api.shop.localhost": {Type: "docker", Target: "shop-db-1", Port: 5432},
+	}
+	candidates := buildCandidates(processes, containers)
+
+	t.Run("unlimited", func(t *testing.T) {
+		pc := newPromptContext("shop.localhost", candidates, containers, mappings, nil, 0)
+		if len(pc.Candidates) != len(candidates) || len(pc.Containers) != 2 || len(pc.Mappings) != 3 {
+			t.Errorf("kept %d candidates, %d containers, %d mappings", len(pc.Candidates), len(pc.Containers), len(pc.Mappings))
+		}
+		if pc.OmittedProcesses+pc.OmittedContainers+pc.OmittedMappings != 0 {
+			t.Error("entries were omitted without a budget")
+		}
+		if pc.Mappings[2].Host != "other.localhost" {
+			t.Errorf("least relevant mapping = %s, want other.localhost", pc.Mappings[2].Host)
+		}
+	})
+
+	t.Run("budget keeps the relevant entries", func(t *testing.T) {
+		pc := newPromptContext("shop.localhost", candidates, containers, mappings, nil, 60)
+
+		var kept []string
+		for _, c := range pc.Candidates {
+			kept = append(kept, c.Mapping().Target+fmt.Sprint(c.Port))
+		}
+		joined := strings.Join(kept, " ")
+		if !strings.Contains(joined, "localhost5173") || !strings.Contains(joined, "shop-db-15432") {
+			t.Errorf("kept %v, want the shop process and database", kept)
+		}
+		if pc.OmittedProcesses == 0 || pc.OmittedProcesses+len(pc.Candidates) != len(candidates)-pc.OmittedContainers {
+			t.Errorf("omitted %d processes, %d containers of %d candidates, kept %d", pc.OmittedProcesses, pc.OmittedContainers, len(candidates), len(pc.Candidates))
+		}
+
+		// Kept candidates stay in ID order
+		index := make(map[string]int)
+		for i, c := range candidates {
+			index[c.ID] = i
+		}
+		for i := 1; i < len(pc.Candidates); i++ {
+			if index[pc.Candidates[i-1].ID] > index[pc.Candidates[i].ID] {
+				t.Errorf("candidates out of ID order: %v", pc.Candidates.IDs())
+			}
+		}
+	})
+
+	t.Run("tiny budget keeps the best candidate", func(t *testing.T) {
+		pc := newPromptContext("shop.localhost", candidates, containers, mappings, nil, 1)
+		if len(pc.Candidates) != 1 || pc.Candidates[0].Port != 5173 {
+			t.Errorf("kept %+v, want only the shop process", pc.Candidates)
+		}
+		if pc.OmittedMappings != 3 {
+			t.Errorf("omitted %d mappings, want 3", pc.OmittedMappings)
+		}
+	})
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("short", 10); got != "short" {
+		t.Errorf("truncate = %q", got)
+	}
+	if got := truncate("žluťoučký kůň", 5); got != "žluťo..." {
+		t.Errorf("truncate = %q, want a rune-safe cut", got)
+	}
+}
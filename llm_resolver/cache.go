@@ -18,14 +18,39 @@ type ProcessIdentifier struct {
 
 // RouteMapping represents a hostname to target mapping
 type RouteMapping struct {
-	Type      string `json:"type"`      // "process" or "docker"
-	Target    string `json:"target"`    // For process: "localhost", for docker: container name
-	Port      int    `json:"port"`      // Target port number (hint/fallback for process type)
-	CreatedAt string `json:"createdAt"` // ISO timestamp
-	LLMReason string `json:"llmReason"` // AI reasoning for the mapping
+	Type      string `json:"type"`           // "process" or "docker"
+	Target    string `json:"target"`         // For process: "localhost", for docker: container name
+	Port      int    `json:"port"`           // Target port number (hint/fallback for process type)
+	CreatedAt string `json:"createdAt"`      // ISO timestamp
+	LLMReason string `json:"llmReason"`      // AI reasoning for the mapping
+	Tier      string `json:"tier,omitempty"` // Resolution tier that produced the mapping: "heuristic", "llm" or "manual"
+
+	// Confidence is the model's certainty in an LLM mapping (0-1, 0 if not given)
+	Confidence float64 `json:"confidence,omitempty"`
+
+	// Choices are the candidates offered on the picker page, kept on a
+	// picked mapping so another one can be chosen later
+	Choices []*RouteMapping `json:"choices,omitempty"`
+
+	// Wait holds requests while the target restarts instead of failing them
+	Wait bool `json:"wait,omitempty"`
+
+	// Launch starts the target on demand when it is not running (process type only)
+	Launch *LaunchConfig `json:"launch,omitempty"`
 
 	// ProcessIdentifier for dynamic port resolution (process type only)
 	ProcessIdentifier *ProcessIdentifier `json:"processIdentifier,omitempty"`
+
+	// Transcript of inspection tool calls made by the agentic resolver
+	Transcript []TranscriptStep `json:"transcript,omitempty"`
+
+	// ResolutionID links the mapping to its entry in the resolution journal
+	ResolutionID string `json:"resolutionId,omitempty"`
+
+	// StaleSince is set while the target is missing from discovery (ISO
+	// timestamp of when it was first missed); StaleReason says what is missing
+	StaleSince  string `json:"staleSince,omitempty"`
+	StaleReason string `json:"staleReason,omitempty"`
 }
 
 // Mappings is a map of hostname to RouteMapping
@@ -108,6 +133,42 @@ func (c *Cache) Set(hostname string, mapping *RouteMapping) {
 	c.mappings[hostname] = mapping
 }
 
+// Replace stores a newly resolved mapping for a hostname, keeping the
+// settings made on the mapping it replaces: wait mode, and the launch command
+// if the new target is a process in the same workdir
+func (c *Cache) Replace(hostname string, mapping *RouteMapping) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if previous, ok := c.mappings[hostname]; ok {
+		mapping.Wait = previous.Wait
+		if launchApplies(mapping, previous) {
+			mapping.Launch = previous.Launch
+		}
+	}
+	if mapping.CreatedAt == "" {
+		mapping.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	c.mappings[hostname] = mapping
+}
+
+// Update applies fn to a copy of the mapping for hostname and stores the
+// copy, unless the mapping was replaced since expected was read. It reports
+// whether the mapping was updated.
+func (c *Cache) Update(hostname string, expected *RouteMapping, fn func(mapping *RouteMapping)) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current, ok := c.mappings[hostname]
+	if !ok || current != expected {
+		return false
+	}
+	updated := *current
+	fn(&updated)
+	c.mappings[hostname] = &updated
+	return true
+}
+
 // Delete removes a mapping
 func (c *Cache) Delete(hostname string) {
 	c.mu.Lock()
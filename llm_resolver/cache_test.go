@@ -0,0 +1,46 @@
+package llm_resolver
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCacheReplaceKeepsSettings(t *testing.T) {
+	cache := NewCache(filepath.Join(t.TempDir(), "mappings.json"), zap.NewNop())
+	cache.Set("blog.localhost", &RouteMapping{Type: "process", Target: "localhost", Port: 3000, Wait: true})
+
+	cache.Replace("blog.localhost", &RouteMapping{Type: "process", Target: "localhost", Port: 3001})
+	got := cache.Get("blog.localhost")
+	if got.Port != 3001 || !got.Wait || got.CreatedAt == "" {
+		t.Errorf("replaced mapping = %+v, want port 3001 in wait mode", got)
+	}
+
+	cache.Replace("shop.localhost", &RouteMapping{Type: "docker", Target: "shop", Port: 80})
+	if got := cache.Get("shop.localhost"); got.Wait {
+		t.Error("new mapping is in wait mode")
+	}
+}
+
+func TestCacheUpdate(t *testing.T) {
+	cache := NewCache(filepath.Join(t.TempDir(), "mappings.json"), zap.NewNop())
+	cache.Set("blog.localhost", &RouteMapping{Type: "process", Target: "localhost", Port: 3000})
+	read := cache.Get("blog.localhost")
+
+	if !cache.Update("blog.localhost", read, func(mapping *RouteMapping) { mapping.Wait = true }) {
+		t.Fatal("update of the current mapping refused")
+	}
+	if !cache.Get("blog.localhost").Wait || read.Wait {
+		t.Error("update not applied to a copy")
+	}
+
+	// A PATCH that read the mapping before this replacement answers 409
+	cache.Replace("blog.localhost", &RouteMapping{Type: "process", Target: "localhost", Port: 3001})
+	if cache.Update("blog.localhost", read, func(mapping *RouteMapping) { mapping.Port = 4000 }) {
+		t.Error("update of a replaced mapping applied")
+	}
+	if cache.Update("shop.localhost", nil, func(mapping *RouteMapping) {}) {
+		t.Error("update of an unknown hostname applied")
+	}
+}
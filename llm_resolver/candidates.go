@@ -0,0 +1,183 @@
+package llm_resolver
+
+import (
+	"fmt"
+	"sort"
+)
+
+// noneCandidateID is the answer for "no listed target serves this hostname"
+const noneCandidateID = "none"
+
+// Candidate is a routable target from the discovery snapshot with a stable
+// ID the model can choose instead of typing names, ports and paths
+type Candidate struct {
+	ID        string           `json:"id"`
+	Type      string           `json:"type"` // "process" or "docker"
+	Port      int              `json:"port"`
+	Process   *LocalProcess    `json:"process,omitempty"`
+	Container *DockerContainer `json:"container,omitempty"`
+}
+
+// Candidates is the ordered list of routable targets shown to the model
+type Candidates []Candidate
+
+// buildCandidates assigns IDs to discovered targets. Processes are ordered by
+// port and get IDs p1..pN; every exposed container port gets an ID d1..dN,
+// ordered by container name and port, so the same snapshot always yields
+// the same IDs.
+func buildCandidates(processes []LocalProcess, containers []DockerContainer) Candidates {
+	procs := make([]LocalProcess, len(processes))
+	copy(procs, processes)
+	sort.SliceStable(procs, func(i, j int) bool { return procs[i].Port < procs[j].Port })
+
+	ctrs := make([]DockerContainer, len(containers))
+	copy(ctrs, containers)
+	sort.SliceStable(ctrs, func(i, j int) bool { return ctrs[i].Name < ctrs[j].Name })
+
+	var candidates Candidates
+	for i := range procs {
+		candidates = append(candidates, Candidate{
+			ID:      fmt.Sprintf("p%d", i+1),
+			Type:    "process",
+			Port:    procs[i].Port,
+			Process: &procs[i],
+		})
+	}
+
+	n := 0
+	for i := range ctrs {
+		ports := make([]int, len(ctrs[i].Ports))
+		copy(ports, ctrs[i].Ports)
+		sort.Ints(ports)
+		for _, port := range ports {
+			n++
+			candidates = append(candidates, Candidate{
+				ID:        fmt.Sprintf("d%d", n),
+				Type:      "docker",
+				Port:      port,
+				Container: &ctrs[i],
+			})
+		}
+	}
+
+	return candidates
+}
+
+// Find returns the candidate with the given ID, or nil
+func (c Candidates) Find(id string) *Candidate {
+	for i := range c {
+		if c[i].ID == id {
+			return &c[i]
+		}
+	}
+	return nil
+}
+
+// IDs returns all candidate IDs in order
+func (c Candidates) IDs() []string {
+	ids := make([]string, len(c))
+	for i, candidate := range c {
+		ids[i] = candidate.ID
+	}
+	return ids
+}
+
+// ForContainer returns the candidates for a container's ports
+func (c Candidates) ForContainer(name string) Candidates {
+	var result Candidates
+	for _, candidate := range c {
+		if candidate.Container != nil && candidate.Container.Name == name {
+			result = append(result, candidate)
+		}
+	}
+	return result
+}
+
+// ForProcess returns the candidate for a process, or nil
+func (c Candidates) ForProcess(proc LocalProcess) *Candidate {
+	for i := range c {
+		if c[i].Process != nil && c[i].Process.PID == proc.PID && c[i].Port == proc.Port {
+			return &c[i]
+		}
+	}
+	return nil
+}
+
+// Mapping rebuilds a route mapping from the real discovery record
+func (c *Candidate) Mapping() *RouteMapping {
+	if c.Process != nil {
+		return processMapping(*c.Process)
+	}
+	return &RouteMapping{
+		Type:   "docker",
+		Target: c.Container.Name,
+		Port:   c.Port,
+	}
+}
+
+// processMapping creates a process route mapping with a ProcessIdentifier
+// for dynamic port resolution
+func processMapping(proc LocalProcess) *RouteMapping {
+	mapping := &RouteMapping{
+		Type:   "process",
+		Target: "localhost",
+		Port:   proc.Port,
+	}
+	if proc.Workdir != "" {
+		mapping.ProcessIdentifier = &ProcessIdentifier{
+			Workdir: proc.Workdir,
+		}
+	}
+	return mapping
+}
+
+// candidateSchema is the strict JSON schema for a candidate choice. Besides
+// the candidate IDs, the model may answer "none".
+func candidateSchema(ids []string) *ResponseSchema {
+	choices := append(append([]string{}, ids...), noneCandidateID)
+	return &ResponseSchema{
+		Name:        "route_choice",
+		Description: "Choose the target the hostname should be routed to",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"candidate_id": map[string]interface{}{
+					"type":        "string",
+					"enum":        choices,
+					"description": "ID of the chosen process or container port, or \"none\" if no listed target serves the hostname",
+				},
+				"confidence": map[string]interface{}{
+					"type":        "number",
+					"description": "How certain the choice is, from 0 (a guess) to 1 (certain)",
+				},
+				"reason": map[string]interface{}{
+					"type":        "string",
+					"description": "Brief explanation of why this target was chosen",
+				},
+				"alternatives": map[string]interface{}{
+					"type":        "array",
+					"description": "Other candidates that could also plausibly serve the hostname, empty if none",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"candidate_id": map[string]interface{}{
+								"type": "string",
+								"enum": ids,
+							},
+							"confidence": map[string]interface{}{
+								"type": "number",
+							},
+							"reason": map[string]interface{}{
+								"type": "string",
+							},
+						},
+						"required":             []string{"candidate_id", "confidence", "reason"},
+						"additionalProperties": false,
+					},
+				},
+			},
+			"required":             []string{"candidate_id", "confidence", "reason", "alternatives"},
+			"additionalProperties": false,
+		},
+	}
+}
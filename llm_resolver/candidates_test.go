@@ -0,0 +1,93 @@
+package llm_resolver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildCandidates(t *testing.T) {
+	processes := []LocalProcess{
+		{PID: 2, Port: 8000, Workdir: "/home/dev/shop"},
+		{PID: 1, Port: 3000, Workdir: "/home/dev/blog"},
+	}
+	containers := []DockerContainer{
+		{Name: "web", Ports: []int{443, 80}},
+		{Name: "db", Ports: []int{5432}},
+	}
+
+	candidates := buildCandidates(processes, containers)
+	if got, want := candidates.IDs(), []string{"p1", "p2", "d1", "d2", "d3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("IDs = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		id     string
+		typ    string
+		target string
+		port   int
+	}{
+		{"p1", "process", "localhost", 3000},
+		{"p2", "process", "localhost", 8000},
+		{"d1", "docker", "db", 5432},
+		{"d2", "docker", "web", 80},
+		{"d3", "docker", "web", 443},
+	}
+	for _, tt := range tests {
+		mapping := candidates.Find(tt.id).Mapping()
+		if mapping.Type != tt.typ || mapping.Target != tt.target || mapping.Port != tt.port {
+			t.Errorf("%s = %s %s:%d, want %s %s:%d", tt.id, mapping.Type, mapping.Target, mapping.Port, tt.typ, tt.target, tt.port)
+		}
+	}
+
+	if c := candidates.Find("p1"); c.Mapping().ProcessIdentifier == nil || c.Mapping().ProcessIdentifier.Workdir != "/home/dev/blog" {
+		t.Error("process candidate has no workdir identifier")
+	}
+	if candidates.Find("p9") != nil {
+		t.Error("Find returned a candidate for an unknown ID")
+	}
+	if got := len(candidates.ForContainer("web")); got != 2 {
+		t.Errorf("ForContainer(web) returned %d candidates, want 2", got)
+	}
+	if c := candidates.ForProcess(processes[0]); c == nil || c.ID != "p2" {
+		t.Errorf("ForProcess = %v, want p2", c)
+	}
+
+	// The input order does not change the IDs
+	reordered := buildCandidates([]LocalProcess{processes[1], processes[0]}, []DockerContainer{containers[1], containers[0]})
+	if !reflect.DeepEqual(reordered.IDs(), candidates.IDs()) || reordered.Find("d1").Container.Name != "db" {
+		t.Error("IDs depend on discovery order")
+	}
+}
+
+func TestApplyCandidate(t *testing.T) {
+	candidates := buildCandidates(verifyProcesses, verifyContainers)
+
+	// Typed fields are replaced by the discovery record
+	response := &LLMResponse{CandidateID: "p1", Type: "docker", Target: "typo", Port: 1, CommandPattern: "node"}
+	if err := applyCandidate(response, candidates); err != nil {
+		t.Fatal(err)
+	}
+	if response.Type != "process" || response.Target != "localhost" || response.Port != 3000 ||
+		response.Workdir != "/home/dev/projects/blog" || response.CommandPattern != "" {
+		t.Errorf("response = %+v", response)
+	}
+
+	if err := applyCandidate(&LLMResponse{CandidateID: "x1"}, candidates); err == nil {
+		t.Error("unknown candidate: got no error")
+	}
+
+	// "none" clears the typed target
+	none := &LLMResponse{CandidateID: noneCandidateID, Type: "process", Target: "localhost", Port: 3000, Workdir: "/tmp"}
+	if err := applyCandidate(none, candidates); err != nil || none.Type != noneType || none.Port != 0 || none.Workdir != "" {
+		t.Errorf("none answer: %v, %+v", err, none)
+	}
+	if err := verifyResponse(none, verifyProcesses, verifyContainers); err != nil {
+		t.Errorf("verifyResponse(none) = %v", err)
+	}
+
+	// Answers without a candidate ID are left for verifyResponse
+	legacy := &LLMResponse{Type: "docker", Target: "web", Port: 80}
+	if err := applyCandidate(legacy, candidates); err != nil || legacy.Target != "web" {
+		t.Errorf("legacy answer: %v, %+v", err, legacy)
+	}
+}
@@ -0,0 +1,221 @@
+package llm_resolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// correctionsSize is the number of corrections kept, one per hostname
+	correctionsSize = 50
+
+	// maxCorrectionExamples is the number of corrections shown in a prompt
+	maxCorrectionExamples = 3
+
+	// maxDescriptionChars is the length target descriptions are cut to
+	maxDescriptionChars = 120
+)
+
+// CorrectionTarget is one side of a correction, described as it was
+// discovered when the correction was made
+type CorrectionTarget struct {
+	Type        string `json:"type"`
+	Target      string `json:"target"`
+	Port        int    `json:"port"`
+	Description string `json:"description,omitempty"`
+}
+
+// Correction records a resolved mapping that was fixed by hand, so later
+// prompts can show the model what it got wrong
+type Correction struct {
+	Hostname     string           `json:"hostname"`
+	Time         string           `json:"time"`
+	Original     CorrectionTarget `json:"original"`
+	Reason       string           `json:"reason,omitempty"` // the model's reason for the original choice
+	Corrected    CorrectionTarget `json:"corrected"`
+	ResolutionID string           `json:"resolutionId,omitempty"`
+
+	// Discovery snapshot at the time of the correction
+	Processes  []LocalProcess    `json:"processes,omitempty"`
+	Containers []DockerContainer `json:"containers,omitempty"`
+}
+
+// Corrections is the persistent store of manual corrections
+type Corrections struct {
+	mu       sync.RWMutex
+	entries  []*Correction // oldest first
+	filePath string
+	logger   *zap.Logger
+}
+
+// NewCorrections creates a correction store at filePath
+func NewCorrections(filePath string, logger *zap.Logger) *Corrections {
+	return &Corrections{
+		filePath: filePath,
+		logger:   logger,
+	}
+}
+
+// Load reads corrections from the file
+func (c *Corrections) Load() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(data, &c.entries)
+}
+
+// save writes the corrections atomically; the caller holds the lock
+func (c *Corrections) save() error {
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.filePath), 0755); err != nil {
+		return err
+	}
+	tmpFile := c.filePath + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, c.filePath)
+}
+
+// Record stores the correction of hostname from original to corrected.
+// Editing a hostname again keeps the model's original choice; editing it back
+// to that choice drops the correction. Manual mappings without a recorded
+// correction and unchanged targets are ignored.
+func (c *Corrections) Record(hostname string, original, corrected *RouteMapping, processes []LocalProcess, containers []DockerContainer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index := -1
+	for i, entry := range c.entries {
+		if entry.Hostname == hostname {
+			index = i
+			break
+		}
+	}
+
+	var correction *Correction
+	switch {
+	case index >= 0:
+		correction = c.entries[index]
+		c.entries = append(c.entries[:index], c.entries[index+1:]...)
+		if sameTarget(correction.Original, corrected) {
+			return c.save()
+		}
+	case original == nil || original.Tier == TierManual || original.Tier == TierPicked || sameTarget(correctionTarget(original, nil, nil), corrected):
+		return nil
+	default:
+		correction = &Correction{
+			Hostname:     hostname,
+			Original:     correctionTarget(original, processes, containers),
+			Reason:       original.LLMReason,
+			ResolutionID: original.ResolutionID,
+		}
+	}
+
+	correction.Time = time.Now().UTC().Format(time.RFC3339)
+	correction.Corrected = correctionTarget(corrected, processes, containers)
+	correction.Processes = processes
+	correction.Containers = containers
+
+	c.entries = append(c.entries, correction)
+	if len(c.entries) > correctionsSize {
+		c.entries = c.entries[len(c.entries)-correctionsSize:]
+	}
+	return c.save()
+}
+
+// List returns all corrections, newest first
+func (c *Corrections) List() []*Correction {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]*Correction, 0, len(c.entries))
+	for i := len(c.entries) - 1; i >= 0; i-- {
+		result = append(result, c.entries[i])
+	}
+	return result
+}
+
+// Relevant returns up to n corrections ordered by lexical overlap of their
+// hostname with the given one, newest first on ties. Safe to call on nil.
+func (c *Corrections) Relevant(hostname string, n int) []*Correction {
+	if c == nil {
+		return nil
+	}
+	ranked := c.List()
+	terms := relevanceTerms(hostname)
+	scores := make(map[*Correction]int, len(ranked))
+	for _, correction := range ranked {
+		scores[correction] = overlap(terms, correction.Hostname)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return scores[ranked[i]] > scores[ranked[j]] })
+	if len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	return ranked
+}
+
+// correctionTarget describes a mapping's target from the discovery snapshot
+func correctionTarget(mapping *RouteMapping, processes []LocalProcess, containers []DockerContainer) CorrectionTarget {
+	return CorrectionTarget{
+		Type:        mapping.Type,
+		Target:      mapping.Target,
+		Port:        mapping.Port,
+		Description: truncate(describeTarget(mapping, processes, containers), maxDescriptionChars),
+	}
+}
+
+// describeTarget describes the discovered process or container behind a
+// mapping, or returns "" if it is not in the snapshot
+func describeTarget(mapping *RouteMapping, processes []LocalProcess, containers []DockerContainer) string {
+	switch mapping.Type {
+	case "process":
+		for _, proc := range processes {
+			if proc.Port != mapping.Port {
+				continue
+			}
+			description := proc.Command
+			if proc.Args != "" {
+				description += fmt.Sprintf(" (args: %s)", proc.Args)
+			}
+			if proc.Workdir != "" {
+				description += fmt.Sprintf(" [workdir: %s]", proc.Workdir)
+			}
+			return description
+		}
+	case "docker":
+		for _, container := range containers {
+			if container.Name != mapping.Target {
+				continue
+			}
+			description := fmt.Sprintf("image: %s", container.Image)
+			if container.Workdir != "" {
+				description += fmt.Sprintf(" [workdir: %s]", container.Workdir)
+			}
+			return description
+		}
+	}
+	return ""
+}
+
+// sameTarget reports whether a correction side points at the mapping's target
+func sameTarget(t CorrectionTarget, mapping *RouteMapping) bool {
+	return t.Type == mapping.Type && t.Target == mapping.Target && t.Port == mapping.Port
+}
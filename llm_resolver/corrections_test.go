@@ -0,0 +1,128 @@
+package llm_resolver
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCorrectionsRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corrections.json")
+	corrections := NewCorrections(path, zap.NewNop())
+
+	llm := &RouteMapping{Type: "process", Target: "localhost", Port: 3000, Tier: TierLLM, LLMReason: "blog workdir"}
+	shop := &RouteMapping{Type: "docker", Target: "shop-postgres-1", Port: 5432, Tier: TierManual}
+	web := &RouteMapping{Type: "docker", Target: "web", Port: 80, Tier: TierManual}
+
+	// Nothing to correct
+	for _, original := range []*RouteMapping{nil, shop} {
+		if err := corrections.Record("blog.localhost", original, web, verifyProcesses, verifyContainers); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := corrections.Record("blog.localhost", llm, &RouteMapping{Type: "process", Target: "localhost", Port: 3000}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if list := corrections.List(); len(list) != 0 {
+		t.Fatalf("got %d corrections, want none", len(list))
+	}
+
+	if err := corrections.Record("blog.localhost", llm, shop, verifyProcesses, verifyContainers); err != nil {
+		t.Fatal(err)
+	}
+	// Editing again keeps the model's original choice
+	if err := corrections.Record("blog.localhost", shop, web, verifyProcesses, verifyContainers); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := NewCorrections(path, zap.NewNop())
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	list := reloaded.List()
+	if len(list) != 1 {
+		t.Fatalf("got %d corrections, want 1", len(list))
+	}
+	c := list[0]
+	if c.Original.Port != 3000 || c.Reason != "blog workdir" || c.Corrected.Target != "web" {
+		t.Errorf("correction = %+v", c)
+	}
+	if !strings.Contains(c.Original.Description, "/home/dev/projects/blog") {
+		t.Errorf("original description = %q, want the process workdir", c.Original.Description)
+	}
+
+	// Editing back to the original choice drops the correction
+	if err := reloaded.Record("blog.localhost", web, llm, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if list := reloaded.List(); len(list) != 0 {
+		t.Errorf("got %d corrections after reverting, want none", len(list))
+	}
+}
+
+func TestCorrectionsBounded(t *testing.T) {
+	corrections := NewCorrections(filepath.Join(t.TempDir(), "corrections.json"), zap.NewNop())
+	llm := &RouteMapping{Type: "process", Target: "localhost", Port: 3000, Tier: TierLLM}
+	manual := &RouteMapping{Type: "process", Target: "localhost", Port: 4000, Tier: TierManual}
+	for i := 0; i < correctionsSize+5; i++ {
+		if err := corrections.Record(fmt.Sprintf("app%d.localhost", i), llm, manual, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list := corrections.List()
+	if len(list) != correctionsSize {
+		t.Fatalf("got %d corrections, want %d", len(list), correctionsSize)
+	}
+	if want := fmt.Sprintf("app%d.localhost", correctionsSize+4); list[0].Hostname != want {
+		t.Errorf("newest = %q, want %q", list[0].Hostname, want)
+	}
+}
+
+func TestCorrectionsRelevant(t *testing.T) {
+	corrections := NewCorrections(filepath.Join(t.TempDir(), "corrections.json"), zap.NewNop())
+	llm := &RouteMapping{Type: "process", Target: "localhost", Port: 3000, Tier: TierLLM}
+	manual := &RouteMapping{Type: "process", Target: "localhost", Port: 4000, Tier: TierManual}
+	for _, hostname := range []string{"api.shop.localhost", "blog.localhost", "admin.shop.localhost", "docs.localhost"} {
+		if err := corrections.Record(hostname, llm, manual, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	relevant := corrections.Relevant("www.shop.localhost", 3)
+	var got []string
+	for _, c := range relevant {
+		got = append(got, c.Hostname)
+	}
+	want := "admin.shop.localhost api.shop.localhost docs.localhost"
+	if strings.Join(got, " ") != want {
+		t.Errorf("relevant = %v, want %s", got, want)
+	}
+
+	var none *Corrections
+	if got := none.Relevant("shop.localhost", 3); got != nil {
+		t.Errorf("nil store returned %v", got)
+	}
+}
+
+func TestPromptCorrections(t *testing.T) {
+	correction := &Correction{
+		Hostname:  "admin.shop.localhost",
+		Original:  CorrectionTarget{Type: "process", Target: "localhost", Port: 3000, Description: "node"},
+		Corrected: CorrectionTarget{Type: "docker", Target: "shop-admin-1", Port: 8080},
+	}
+	pc := newPromptContext("shop.localhost", nil, nil, nil, []*Correction{correction}, 0)
+
+	var b strings.Builder
+	writeCorrections(&b, pc)
+	want := "- admin.shop.localhost: answered process:localhost:3000 (node), corrected to docker:shop-admin-1:8080\n"
+	if !strings.Contains(b.String(), "## Past Corrections") || !strings.HasSuffix(b.String(), want) {
+		t.Errorf("prompt section = %q", b.String())
+	}
+
+	if pc := newPromptContext("shop.localhost", nil, nil, nil, []*Correction{correction}, 1); len(pc.Corrections) != 0 {
+		t.Errorf("correction kept under a budget of 1 token")
+	}
+}
@@ -1,6 +1,8 @@
 package llm_resolver
 
 import (
+	"context"
+
 	"github.com/contember/tudy/llm_resolver/discovery"
 )
 
@@ -9,13 +11,13 @@ type LocalProcess = discovery.LocalProcess
 type DockerContainer = discovery.DockerContainer
 
 // DiscoverLocalProcesses discovers locally running processes with open ports
-func DiscoverLocalProcesses() ([]LocalProcess, error) {
-	return discovery.DiscoverLocalProcesses()
+func DiscoverLocalProcesses(ctx context.Context) ([]LocalProcess, error) {
+	return discovery.DiscoverLocalProcesses(ctx)
 }
 
 // DiscoverDockerContainers discovers running Docker containers
-func DiscoverDockerContainers(ownComposeProject string) ([]DockerContainer, error) {
-	return discovery.DiscoverDockerContainers(ownComposeProject)
+func DiscoverDockerContainers(ctx context.Context, ownComposeProject string) ([]DockerContainer, error) {
+	return discovery.DiscoverDockerContainers(ctx, ownComposeProject)
 }
 
 // GetContainerIP gets the IP address of a container by name or ID
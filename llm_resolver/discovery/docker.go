@@ -65,17 +65,18 @@ type dockerInspectOutput struct {
 }
 
 // DiscoverDockerContainers discovers running Docker containers
-func DiscoverDockerContainers(ownComposeProject string) ([]DockerContainer, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+func DiscoverDockerContainers(ctx context.Context, ownComposeProject string) ([]DockerContainer, error) {
+	// Each inspect below gets its own timeout, so this one only covers ps
+	psCtx, cancel := context.WithTimeout(ctx, commandTimeout)
 	defer cancel()
 
 	// Check if docker is available
-	if err := exec.CommandContext(ctx, "docker", "info").Run(); err != nil {
+	if err := exec.CommandContext(psCtx, "docker", "info").Run(); err != nil {
 		return nil, nil // Docker not available, return empty list
 	}
 
 	// Get running containers
-	cmd := exec.CommandContext(ctx, "docker", "ps", "--format", "{{json .}}")
+	cmd := exec.CommandContext(psCtx, "docker", "ps", "--format", "{{json .}}")
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, err
@@ -95,7 +96,7 @@ func DiscoverDockerContainers(ownComposeProject string) ([]DockerContainer, erro
 		}
 
 		// Get detailed container info
-		details, err := getContainerDetails(ps.ID)
+		details, err := getContainerDetails(ctx, ps.ID)
 		if err != nil || details == nil {
 			continue
 		}
@@ -115,8 +116,8 @@ func DiscoverDockerContainers(ownComposeProject string) ([]DockerContainer, erro
 }
 
 // getContainerDetails gets detailed information about a container
-func getContainerDetails(containerID string) (*DockerContainer, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+func getContainerDetails(ctx context.Context, containerID string) (*DockerContainer, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "docker", "inspect", containerID)
@@ -227,7 +228,7 @@ func GetContainerIP(containerIDOrName string) (string, error) {
 // On macOS/Windows, Docker container IPs are not accessible from the host, so we
 // need to use published ports. Returns (hostIP, hostPort, found).
 func GetContainerHostAddress(containerIDOrName string, containerPort int) (string, int, bool) {
-	details, err := getContainerDetails(containerIDOrName)
+	details, err := getContainerDetails(context.Background(), containerIDOrName)
 	if err != nil || details == nil {
 		return "", 0, false
 	}
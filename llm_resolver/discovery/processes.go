@@ -107,20 +107,21 @@ var ignoredArgsPatterns = []string{
 	"apple.systempreferences",
 }
 
-// DiscoverLocalProcesses discovers locally running processes with open ports
-func DiscoverLocalProcesses() ([]LocalProcess, error) {
+// DiscoverLocalProcesses discovers locally running processes with open ports.
+// Commands are killed when ctx is cancelled.
+func DiscoverLocalProcesses(ctx context.Context) ([]LocalProcess, error) {
 	var processes []LocalProcess
 	var err error
 
 	if runtime.GOOS == "darwin" {
 		// macOS: try lsof first, fallback to netstat
-		processes, err = discoverWithLsof()
+		processes, err = discoverWithLsof(ctx)
 		if err != nil || len(processes) == 0 {
-			processes, err = discoverWithNetstat()
+			processes, err = discoverWithNetstat(ctx)
 		}
 	} else {
 		// Linux: try ss first, fallback to /proc
-		processes, err = tryWithSs()
+		processes, err = tryWithSs(ctx)
 		if err != nil || len(processes) == 0 {
 			processes, err = parseFromProc()
 		}
@@ -153,7 +154,7 @@ func DiscoverLocalProcesses() ([]LocalProcess, error) {
 	// Add PPID to each process for child filtering
 	for i := range filtered {
 		if runtime.GOOS == "darwin" {
-			filtered[i].PPID = getProcessPPIDMac(filtered[i].PID)
+			filtered[i].PPID = getProcessPPIDMac(ctx, filtered[i].PID)
 		} else {
 			filtered[i].PPID = getProcessPPID(filtered[i].PID)
 		}
@@ -215,8 +216,8 @@ func shouldIgnoreByArgs(args string) bool {
 
 // discoverWithLsof uses lsof to discover listening processes (macOS)
 // lsof -iTCP -sTCP:LISTEN -n -P
-func discoverWithLsof() ([]LocalProcess, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+func discoverWithLsof(ctx context.Context) ([]LocalProcess, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "lsof", "-iTCP", "-sTCP:LISTEN", "-n", "-P")
@@ -279,8 +280,8 @@ func discoverWithLsof() ([]LocalProcess, error) {
 		}
 
 		// Get full command args and workdir using ps and lsof
-		args := cleanArgs(getProcessArgsMac(pid))
-		workdir := getProcessWorkdirMac(pid)
+		args := cleanArgs(getProcessArgsMac(ctx, pid))
+		workdir := getProcessWorkdirMac(ctx, pid)
 
 		processes = append(processes, LocalProcess{
 			Port:     port,
@@ -297,8 +298,8 @@ func discoverWithLsof() ([]LocalProcess, error) {
 
 // discoverWithNetstat is a fallback for macOS when lsof fails (e.g., in launchd services).
 // Uses netstat -anv which reads from kernel network tables and includes PID info.
-func discoverWithNetstat() ([]LocalProcess, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+func discoverWithNetstat(ctx context.Context) ([]LocalProcess, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "netstat", "-anv", "-p", "tcp")
@@ -364,8 +365,8 @@ func discoverWithNetstat() ([]LocalProcess, error) {
 			continue
 		}
 
-		args := cleanArgs(getProcessArgsMac(pid))
-		workdir := getProcessWorkdirMac(pid)
+		args := cleanArgs(getProcessArgsMac(ctx, pid))
+		workdir := getProcessWorkdirMac(ctx, pid)
 
 		processes = append(processes, LocalProcess{
 			Port:     port,
@@ -381,8 +382,8 @@ func discoverWithNetstat() ([]LocalProcess, error) {
 }
 
 // getProcessArgsMac gets process arguments on macOS using ps
-func getProcessArgsMac(pid int) string {
-	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+func getProcessArgsMac(ctx context.Context, pid int) string {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "ps", "-p", strconv.Itoa(pid), "-o", "args=")
@@ -394,8 +395,8 @@ func getProcessArgsMac(pid int) string {
 }
 
 // getProcessWorkdirMac gets process working directory on macOS using lsof
-func getProcessWorkdirMac(pid int) string {
-	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+func getProcessWorkdirMac(ctx context.Context, pid int) string {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "lsof", "-a", "-p", strconv.Itoa(pid), "-Fn", "-d", "cwd")
@@ -418,8 +419,8 @@ func getProcessWorkdirMac(pid int) string {
 }
 
 // tryWithSs uses the ss command to discover processes (Linux, faster)
-func tryWithSs() ([]LocalProcess, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+func tryWithSs(ctx context.Context) ([]LocalProcess, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "ss", "-tlnp")
@@ -666,8 +667,8 @@ func getProcessPPID(pid int) int {
 }
 
 // getProcessPPIDMac gets the parent PID for a process (macOS)
-func getProcessPPIDMac(pid int) int {
-	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+func getProcessPPIDMac(ctx context.Context, pid int) int {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "ps", "-p", strconv.Itoa(pid), "-o", "ppid=")
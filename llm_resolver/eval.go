@@ -0,0 +1,356 @@
+package llm_resolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	caddycmd "github.com/caddyserver/caddy/v2/cmd"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func init() {
+	caddycmd.RegisterCommand(caddycmd.Command{
+		Name:  "eval",
+		Usage: "--fixtures <path> [--model <names>] [--provider <name>] [--system-prompt-file <path>] [--json]",
+		Short: "Measures routing accuracy against recorded discovery snapshots",
+		Long: `
+Replays the discovery snapshots of a fixture file through the resolver and
+compares the chosen targets with the expected ones. Reports accuracy, latency
+and token usage per model.
+
+The endpoint is configured with flags, defaulting to the environment variables
+used by the Caddyfile (LLM_PROVIDER, LLM_API_URL, LLM_API_KEY, MODEL). Pass
+several comma-separated models to compare them side by side. The local name
+matcher is skipped unless --heuristics is given, so the model is measured.
+`,
+		CobraFunc: func(cmd *cobra.Command) {
+			cmd.Flags().StringP("fixtures", "f", "", "Fixture file with discovery snapshots and expected targets (required)")
+			cmd.Flags().String("provider", os.Getenv("LLM_PROVIDER"), "LLM provider: openai, anthropic or ollama")
+			cmd.Flags().String("api-url", os.Getenv("LLM_API_URL"), "LLM API URL (default depends on provider)")
+			cmd.Flags().String("api-key", os.Getenv("LLM_API_KEY"), "LLM API key")
+			cmd.Flags().String("model", os.Getenv("MODEL"), "Comma-separated models to evaluate (default depends on provider)")
+			cmd.Flags().String("system-prompt-file", "", "Prompt template for hostname resolution")
+			cmd.Flags().String("related-prompt-file", "", "Prompt template for related service resolution")
+			cmd.Flags().Int("context-budget", 0, "Approximate token budget for the discovery and mappings lists")
+			cmd.Flags().Bool("agent", false, "Enable the agentic loop with inspection tools")
+			cmd.Flags().Bool("heuristics", false, "Try the local name matcher before the model")
+			cmd.Flags().Float64("min-confidence", defaultMinConfidence, "Confidence below which an answer counts as no match")
+			cmd.Flags().Duration("timeout", defaultResolveTimeout, "Deadline for a single resolution")
+			cmd.Flags().Bool("json", false, "Print the report as JSON")
+			cmd.RunE = caddycmd.WrapCommandFuncForCobra(cmdEval)
+		},
+	})
+}
+
+// evalFixture is the fixture file format
+type evalFixture struct {
+	Snapshots []evalSnapshot `json:"snapshots"`
+}
+
+// evalSnapshot is a recorded discovery state with the hostnames to resolve in it
+type evalSnapshot struct {
+	Name       string            `json:"name"`
+	Processes  []LocalProcess    `json:"processes"`
+	Containers []DockerContainer `json:"containers"`
+	Mappings   Mappings          `json:"mappings,omitempty"`
+	Cases      []evalCase        `json:"cases"`
+}
+
+// evalCase is one hostname (or related service of a hostname) and its expected target
+type evalCase struct {
+	Hostname string     `json:"hostname"`
+	Service  string     `json:"service,omitempty"` // resolves /_proxy/<service> of hostname
+	Prompt   string     `json:"prompt,omitempty"`
+	Expect   evalTarget `json:"expect"`
+}
+
+// evalTarget describes the expected mapping; empty fields are not compared.
+// Type "none" expects that nothing matches.
+type evalTarget struct {
+	Type    string `json:"type,omitempty"`
+	Target  string `json:"target,omitempty"`
+	Port    int    `json:"port,omitempty"`
+	Workdir string `json:"workdir,omitempty"`
+}
+
+// evalResult is the outcome of one case
+type evalResult struct {
+	Snapshot  string      `json:"snapshot"`
+	Hostname  string      `json:"hostname"`
+	Service   string      `json:"service,omitempty"`
+	Expected  evalTarget  `json:"expected"`
+	Got       *evalTarget `json:"got,omitempty"`
+	Correct   bool        `json:"correct"`
+	Error     string      `json:"error,omitempty"`
+	Reason    string      `json:"reason,omitempty"`
+	Tier      string      `json:"tier,omitempty"`
+	LatencyMs int64       `json:"latencyMs"`
+	Calls     int         `json:"calls"`
+	Usage     Usage       `json:"usage"`
+}
+
+// evalReport summarizes the results of one model
+type evalReport struct {
+	Provider      string       `json:"provider"`
+	Model         string       `json:"model"`
+	PromptFile    string       `json:"promptFile,omitempty"`
+	Total         int          `json:"total"`
+	Correct       int          `json:"correct"`
+	Errors        int          `json:"errors"`
+	Accuracy      float64      `json:"accuracy"`
+	MeanLatencyMs int64        `json:"meanLatencyMs"`
+	P50LatencyMs  int64        `json:"p50LatencyMs"`
+	Calls         int          `json:"calls"`
+	InputTokens   int          `json:"inputTokens"`
+	OutputTokens  int          `json:"outputTokens"`
+	Cost          float64      `json:"cost"`
+	Results       []evalResult `json:"results"`
+}
+
+func cmdEval(fl caddycmd.Flags) (int, error) {
+	fixturesPath := fl.String("fixtures")
+	if fixturesPath == "" {
+		return caddy.ExitCodeFailedStartup, fmt.Errorf("--fixtures is required")
+	}
+	data, err := os.ReadFile(fixturesPath)
+	if err != nil {
+		return caddy.ExitCodeFailedStartup, err
+	}
+	var fixture evalFixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return caddy.ExitCodeFailedStartup, fmt.Errorf("invalid fixture file: %w", err)
+	}
+
+	opts := ResolverOptions{
+		DisableHeuristics: !fl.Bool("heuristics"),
+		MinConfidence:     fl.Float64("min-confidence"),
+		PickMargin:        -1, // measure the model's own choice
+		ContextBudget:     fl.Int("context-budget"),
+	}
+	if fl.Bool("agent") {
+		opts.Agent = &AgentConfig{MaxSteps: defaultAgentMaxSteps, MaxTokens: defaultAgentMaxTokens}
+	}
+	if path := fl.String("system-prompt-file"); path != "" {
+		if opts.SystemPromptTemplate, err = LoadPromptTemplate(path); err != nil {
+			return caddy.ExitCodeFailedStartup, fmt.Errorf("system prompt file: %w", err)
+		}
+	}
+	if path := fl.String("related-prompt-file"); path != "" {
+		if opts.RelatedPromptTemplate, err = LoadPromptTemplate(path); err != nil {
+			return caddy.ExitCodeFailedStartup, fmt.Errorf("related prompt file: %w", err)
+		}
+	}
+
+	provider := fl.String("provider")
+	if provider == "" {
+		provider = ProviderOpenAI
+	}
+	models := strings.Split(fl.String("model"), ",")
+
+	httpClient := &http.Client{Timeout: defaultLLMTimeout}
+	var reports []*evalReport
+	for _, model := range models {
+		model = strings.TrimSpace(model)
+		if model == "" {
+			model = defaultModelFor(provider)
+		}
+		endpoint, err := NewEndpoint(EndpointConfig{
+			Provider: provider,
+			APIURL:   fl.String("api-url"),
+			APIKey:   fl.String("api-key"),
+			Model:    model,
+		}, httpClient)
+		if err != nil {
+			return caddy.ExitCodeFailedStartup, err
+		}
+
+		report := &evalReport{
+			Provider:   provider,
+			Model:      model,
+			PromptFile: fl.String("system-prompt-file"),
+		}
+		for _, snapshot := range fixture.Snapshots {
+			snapshot := snapshot
+			snapshotOpts := opts
+			snapshotOpts.Discover = func(context.Context) ([]LocalProcess, []DockerContainer) {
+				return snapshot.Processes, snapshot.Containers
+			}
+			resolver := NewResolver([]Endpoint{endpoint}, snapshotOpts, zap.NewNop())
+
+			for _, c := range snapshot.Cases {
+				if !fl.Bool("json") {
+					fmt.Fprintf(os.Stderr, "%s: %s %s\n", model, snapshot.Name, caseLabel(c))
+				}
+				report.Results = append(report.Results, runEvalCase(resolver, snapshot, c, fl.Duration("timeout")))
+			}
+		}
+		report.summarize()
+		reports = append(reports, report)
+	}
+
+	if fl.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(map[string]interface{}{"reports": reports}); err != nil {
+			return caddy.ExitCodeFailedStartup, err
+		}
+		return 0, nil
+	}
+	printEvalReports(reports)
+	return 0, nil
+}
+
+// runEvalCase resolves one case against its snapshot
+func runEvalCase(resolver *Resolver, snapshot evalSnapshot, c evalCase, timeout time.Duration) evalResult {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	result := evalResult{
+		Snapshot: snapshot.Name,
+		Hostname: c.Hostname,
+		Service:  c.Service,
+		Expected: c.Expect,
+	}
+
+	var mapping *RouteMapping
+	var err error
+	res := newResolution(c.Hostname, c.Service)
+	if c.Service != "" {
+		mapping, err = resolver.resolveRelatedService(ctx, res, c.Hostname, snapshot.Mappings[c.Hostname], c.Service, c.Prompt, snapshot.Mappings)
+	} else {
+		mapping, err = resolver.resolveTarget(ctx, res, c.Hostname, c.Prompt, snapshot.Mappings)
+	}
+	res.finish(mapping, err)
+
+	result.LatencyMs = res.DurationMs
+	result.Calls = res.Calls
+	result.Usage = res.Usage
+	if errors.Is(err, ErrNoMatch) {
+		result.Got = &evalTarget{Type: noneType}
+		result.Reason = err.Error()
+		result.Correct = c.Expect.matches(result.Got)
+		return result
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	got := &evalTarget{Type: mapping.Type, Target: mapping.Target, Port: mapping.Port}
+	if mapping.ProcessIdentifier != nil {
+		got.Workdir = mapping.ProcessIdentifier.Workdir
+	}
+	result.Got = got
+	result.Reason = mapping.LLMReason
+	result.Tier = mapping.Tier
+	result.Correct = c.Expect.matches(got)
+	return result
+}
+
+// matches compares the set fields of the expectation with the resolved target
+func (e evalTarget) matches(got *evalTarget) bool {
+	if e.Type != "" && e.Type != got.Type {
+		return false
+	}
+	if e.Target != "" && e.Target != got.Target {
+		return false
+	}
+	if e.Port != 0 && e.Port != got.Port {
+		return false
+	}
+	if e.Workdir != "" && !matchesWorkdir(got.Workdir, e.Workdir) {
+		return false
+	}
+	return true
+}
+
+func (e evalTarget) String() string {
+	var parts []string
+	if e.Type != "" {
+		parts = append(parts, e.Type)
+	}
+	if e.Target != "" {
+		parts = append(parts, e.Target)
+	}
+	if e.Port != 0 {
+		parts = append(parts, fmt.Sprintf("port %d", e.Port))
+	}
+	if e.Workdir != "" {
+		parts = append(parts, "in "+e.Workdir)
+	}
+	return strings.Join(parts, " ")
+}
+
+// summarize computes the totals of the report from its results
+func (r *evalReport) summarize() {
+	var latencies []int64
+	var sum int64
+	for _, result := range r.Results {
+		r.Total++
+		if result.Correct {
+			r.Correct++
+		}
+		if result.Error != "" {
+			r.Errors++
+		}
+		r.Calls += result.Calls
+		r.InputTokens += result.Usage.InputTokens
+		r.OutputTokens += result.Usage.OutputTokens
+		r.Cost += result.Usage.Cost
+		latencies = append(latencies, result.LatencyMs)
+		sum += result.LatencyMs
+	}
+	if r.Total == 0 {
+		return
+	}
+	r.Accuracy = float64(r.Correct) / float64(r.Total)
+	r.MeanLatencyMs = sum / int64(r.Total)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	r.P50LatencyMs = latencies[len(latencies)/2]
+}
+
+// printEvalReports prints the failed cases and a summary table per model
+func printEvalReports(reports []*evalReport) {
+	for _, report := range reports {
+		for _, result := range report.Results {
+			if result.Correct {
+				continue
+			}
+			label := caseLabel(evalCase{Hostname: result.Hostname, Service: result.Service})
+			if result.Error != "" {
+				fmt.Printf("FAIL %s %s %s: %s\n", report.Model, result.Snapshot, label, result.Error)
+				continue
+			}
+			fmt.Printf("FAIL %s %s %s: expected %s, got %s (%s)\n",
+				report.Model, result.Snapshot, label, result.Expected, result.Got, result.Reason)
+		}
+	}
+	fmt.Println()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tACCURACY\tERRORS\tMEAN\tP50\tCALLS\tTOKENS IN\tTOKENS OUT\tCOST")
+	for _, r := range reports {
+		fmt.Fprintf(tw, "%s/%s\t%d/%d (%.0f%%)\t%d\t%dms\t%dms\t%d\t%d\t%d\t$%.4f\n",
+			r.Provider, r.Model, r.Correct, r.Total, r.Accuracy*100, r.Errors,
+			r.MeanLatencyMs, r.P50LatencyMs, r.Calls, r.InputTokens, r.OutputTokens, r.Cost)
+	}
+	tw.Flush()
+}
+
+// caseLabel names a case in progress and failure output
+func caseLabel(c evalCase) string {
+	if c.Service != "" {
+		return c.Hostname + "/_proxy/" + c.Service
+	}
+	return c.Hostname
+}
@@ -0,0 +1,84 @@
+package llm_resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestEvalTargetMatches(t *testing.T) {
+	got := &evalTarget{Type: "process", Target: "localhost", Port: 3000, Workdir: "/home/dev/projects/blog/web"}
+	tests := []struct {
+		expect evalTarget
+		want   bool
+	}{
+		{evalTarget{}, true},
+		{evalTarget{Port: 3000}, true},
+		{evalTarget{Type: "process", Workdir: "/home/dev/projects/blog"}, true},
+		{evalTarget{Type: "docker"}, false},
+		{evalTarget{Port: 8080}, false},
+		{evalTarget{Workdir: "/home/dev/projects/shop"}, false},
+	}
+	for _, tt := range tests {
+		if matched := tt.expect.matches(got); matched != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.expect, matched, tt.want)
+		}
+	}
+}
+
+func TestEvalReportSummarize(t *testing.T) {
+	report := &evalReport{Results: []evalResult{
+		{Correct: true, LatencyMs: 30, Calls: 1, Usage: Usage{InputTokens: 100, OutputTokens: 10, Cost: 0.01}},
+		{Correct: false, LatencyMs: 10, Calls: 2, Usage: Usage{InputTokens: 200, OutputTokens: 20}},
+		{Error: "timeout", LatencyMs: 20, Calls: 1},
+	}}
+	report.summarize()
+
+	if report.Total != 3 || report.Correct != 1 || report.Errors != 1 {
+		t.Errorf("total, correct, errors = %d, %d, %d, want 3, 1, 1", report.Total, report.Correct, report.Errors)
+	}
+	if report.MeanLatencyMs != 20 || report.P50LatencyMs != 20 {
+		t.Errorf("mean, p50 latency = %d, %d, want 20, 20", report.MeanLatencyMs, report.P50LatencyMs)
+	}
+	if report.Calls != 4 || report.InputTokens != 300 || report.OutputTokens != 30 || report.Cost != 0.01 {
+		t.Errorf("calls %d, tokens %d/%d, cost %v", report.Calls, report.InputTokens, report.OutputTokens, report.Cost)
+	}
+
+	empty := &evalReport{}
+	empty.summarize()
+	if empty.Accuracy != 0 || empty.P50LatencyMs != 0 {
+		t.Errorf("empty report = %+v", empty)
+	}
+}
+
+func TestRunEvalCase(t *testing.T) {
+	snapshot := evalSnapshot{Name: "blog", Processes: verifyProcesses, Containers: verifyContainers}
+	newEvalResolver := func(reply string) *Resolver {
+		provider := &replyProvider{replies: []string{reply}}
+		return NewResolver([]Endpoint{{Provider: provider}}, ResolverOptions{
+			DisableHeuristics: true,
+			Discover: func(context.Context) ([]LocalProcess, []DockerContainer) {
+				return snapshot.Processes, snapshot.Containers
+			},
+		}, zap.NewNop())
+	}
+	c := evalCase{
+		Hostname: "blog.localhost",
+		Expect:   evalTarget{Type: "process", Port: 3000, Workdir: "/home/dev/projects/blog"},
+	}
+
+	result := runEvalCase(newEvalResolver(`{"type":"process","target":"localhost","port":3000,"workdir":"/home/dev/projects/blog"}`), snapshot, c, time.Second)
+	if !result.Correct || result.Error != "" || result.Calls != 1 {
+		t.Errorf("result = %+v, want a correct answer after one call", result)
+	}
+	if result.Got == nil || result.Got.Workdir != "/home/dev/projects/blog" {
+		t.Errorf("got = %+v", result.Got)
+	}
+
+	result = runEvalCase(newEvalResolver(`{"type":"docker","target":"web","port":80}`), snapshot, c, time.Second)
+	if result.Correct || result.Error != "" {
+		t.Errorf("result = %+v, want an incorrect answer without error", result)
+	}
+}
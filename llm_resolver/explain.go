@@ -0,0 +1,50 @@
+package llm_resolver
+
+import (
+	"context"
+	"errors"
+)
+
+// Explanation is the full record of a resolution: the candidates the model
+// chose from, every prompt exactly as sent with the raw answer, the
+// validation of each answer and the resulting mapping
+type Explanation struct {
+	*Resolution
+
+	// Candidates are the targets shown to the model (all discovered targets
+	// when the heuristic matcher decided)
+	Candidates Candidates `json:"candidates"`
+
+	// Omitted counts what the context budget left out of the prompt
+	OmittedProcesses  int `json:"omittedProcesses,omitempty"`
+	OmittedContainers int `json:"omittedContainers,omitempty"`
+	OmittedMappings   int `json:"omittedMappings,omitempty"`
+
+	Mapping *RouteMapping `json:"mapping,omitempty"`
+
+	// Choices are the plausible targets when the answer was ambiguous
+	Choices []*RouteMapping `json:"choices,omitempty"`
+}
+
+// Explain runs the resolution pipeline for a hostname and returns its record
+// instead of just the mapping. The resolution is journaled and counts toward
+// usage limits; dryRun marks it in the journal as not cached.
+func (r *Resolver) Explain(ctx context.Context, hostname, userPrompt string, existingMappings Mappings, dryRun bool) (*Explanation, error) {
+	res := newResolution(hostname, "")
+	res.DryRun = dryRun
+	mapping, err := r.resolveTarget(ctx, res, hostname, userPrompt, existingMappings)
+	r.finishResolution(res, mapping, err)
+
+	exp := &Explanation{Resolution: res, Mapping: mapping}
+	var ambiguous *AmbiguousError
+	if errors.As(err, &ambiguous) {
+		exp.Choices = ambiguous.Choices
+	}
+	if pc := res.prompt; pc != nil {
+		exp.Candidates = pc.Candidates
+		exp.OmittedProcesses = pc.OmittedProcesses
+		exp.OmittedContainers = pc.OmittedContainers
+		exp.OmittedMappings = pc.OmittedMappings
+	}
+	return exp, err
+}
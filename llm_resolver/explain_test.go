@@ -0,0 +1,69 @@
+package llm_resolver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestExplain(t *testing.T) {
+	provider := &replyProvider{replies: []string{
+		`{"type":"docker","target":"redis","port":6379}`,
+		`{"type":"docker","target":"shop-postgres-1","port":5432}`,
+	}}
+	journal := NewJournal(filepath.Join(t.TempDir(), "resolutions.jsonl"), zap.NewNop())
+	r := NewResolver([]Endpoint{{Provider: provider}}, ResolverOptions{
+		DisableHeuristics: true,
+		Journal:           journal,
+		Discover: func(context.Context) ([]LocalProcess, []DockerContainer) {
+			return verifyProcesses, verifyContainers
+		},
+	}, zap.NewNop())
+
+	exp, err := r.Explain(context.Background(), "shop-db.localhost", "", nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.Mapping == nil || exp.Mapping.Target != "shop-postgres-1" || exp.Mapping.Port != 5432 {
+		t.Errorf("mapping = %+v", exp.Mapping)
+	}
+	if len(exp.Candidates) != 2 {
+		t.Errorf("got %d candidates, want 2", len(exp.Candidates))
+	}
+	if len(exp.Validations) != 2 || exp.Validations[0].Accepted || !exp.Validations[1].Accepted {
+		t.Errorf("validations = %+v, want a rejected then an accepted answer", exp.Validations)
+	}
+	if len(exp.Exchanges) != 2 {
+		t.Errorf("got %d exchanges, want 2", len(exp.Exchanges))
+	}
+
+	journaled := journal.Get(exp.ID)
+	if journaled == nil || !journaled.DryRun {
+		t.Errorf("journal entry = %+v, want a dry run", journaled)
+	}
+}
+
+func TestHandleResolveAPIRequests(t *testing.T) {
+	m := &LLMResolver{}
+	tests := []struct {
+		method string
+		target string
+		want   int
+	}{
+		{http.MethodDelete, "/_api/resolve?host=blog.localhost", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/_api/resolve", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		if err := m.handleResolveAPI(w, httptest.NewRequest(tt.method, tt.target, nil)); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.target, w.Code, tt.want)
+		}
+	}
+}
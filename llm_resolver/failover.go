@@ -0,0 +1,236 @@
+package llm_resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultRetries = 2
+	backoffBase    = 500 * time.Millisecond
+	backoffMax     = 8 * time.Second
+)
+
+// EndpointConfig configures one provider/model endpoint in the failover chain
+type EndpointConfig struct {
+	// Provider is the adapter name ("openai", "anthropic" or "ollama")
+	Provider string `json:"provider,omitempty"`
+
+	// APIURL overrides the provider's default endpoint URL
+	APIURL string `json:"api_url,omitempty"`
+
+	// APIKey is the API key for this endpoint
+	APIKey string `json:"api_key,omitempty"`
+
+	// Model is the model to request (default depends on provider)
+	Model string `json:"model,omitempty"`
+
+	// Retries is the number of retries after the first attempt (default: 2)
+	Retries *int `json:"retries,omitempty"`
+
+	// StructuredOutput enables schema-constrained answers (default: true).
+	// Disable for runtimes that support neither JSON schema nor tool calling.
+	// Agent tools are sent regardless, since the agent loop needs them.
+	StructuredOutput *bool `json:"structured_output,omitempty"`
+
+	// Redact scrubs secrets from prompts sent to this endpoint (default: true)
+	Redact *bool `json:"redact,omitempty"`
+
+	// Privacy replaces home directory paths (and the usernames in them) with
+	// stable pseudonyms, mapped back in the answer (default: false)
+	Privacy *bool `json:"privacy,omitempty"`
+}
+
+// Endpoint is a provisioned provider with its retry budget and privacy settings
+type Endpoint struct {
+	Provider Provider
+	Retries  int
+	Redact   bool
+	Privacy  bool
+}
+
+// NewEndpoint creates an endpoint from its configuration, applying defaults
+func NewEndpoint(cfg EndpointConfig, httpClient *http.Client) (Endpoint, error) {
+	if cfg.Provider == "" {
+		cfg.Provider = ProviderOpenAI
+	}
+	if cfg.Model == "" {
+		cfg.Model = defaultModelFor(cfg.Provider)
+	}
+	retries := defaultRetries
+	if cfg.Retries != nil {
+		retries = *cfg.Retries
+	}
+
+	redact := true
+	if cfg.Redact != nil {
+		redact = *cfg.Redact
+	}
+	privacy := cfg.Privacy != nil && *cfg.Privacy
+
+	provider, err := NewProvider(cfg, httpClient)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	return Endpoint{Provider: provider, Retries: retries, Redact: redact, Privacy: privacy}, nil
+}
+
+// errAPIKeyNotSet is returned by providers that require an API key
+var errAPIKeyNotSet = errors.New("API key is not set")
+
+// APIError is a non-200 response from an LLM API
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error: %d - %s", e.StatusCode, e.Body)
+}
+
+// isRetryable reports whether an attempt failure may succeed on retry.
+// Rate limits, server errors and transport failures are retryable; other
+// API errors (bad key, unknown model) are not.
+func isRetryable(err error) bool {
+	if errors.Is(err, errAPIKeyNotSet) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+// backoff returns a jittered exponential delay for the given retry number
+func backoff(retry int) time.Duration {
+	d := backoffBase << retry
+	if d > backoffMax || d <= 0 {
+		d = backoffMax
+	}
+	// Equal jitter: random delay between d/2 and d
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// complete sends the request through the endpoint chain in order, retrying
+// each endpoint with backoff before moving on to the next one. Prompts are
+// sanitized per endpoint. Every attempt is counted against the daily limits
+// before it is sent, and recorded on res as it was sent; a *LimitError stops
+// the chain.
+func (r *Resolver) complete(ctx context.Context, res *Resolution, req *CompletionRequest) (*CompletionResponse, error) {
+	if len(r.endpoints) == 0 {
+		return nil, fmt.Errorf("no LLM endpoints configured")
+	}
+
+	var hostname string
+	if res != nil {
+		hostname = res.Hostname
+	}
+
+	var failures []string
+	for _, endpoint := range r.endpoints {
+		provider := endpoint.Provider
+		sent, pseudonyms := r.sanitizeRequest(endpoint, req)
+		var lastErr error
+		for attempt := 0; attempt <= endpoint.Retries; attempt++ {
+			if attempt > 0 {
+				if err := sleepContext(ctx, backoff(attempt-1)); err != nil {
+					return nil, err
+				}
+			}
+
+			if r.opts.Usage != nil {
+				if err := r.opts.Usage.Reserve(hostname); err != nil {
+					return nil, err
+				}
+			}
+
+			message := fmt.Sprintf("Asking %s/%s", provider.Name(), provider.Model())
+			if attempt > 0 {
+				message += fmt.Sprintf(" (attempt %d)", attempt+1)
+			}
+			reportProgress(ctx, StageAsking, message)
+
+			start := time.Now()
+			resp, err := provider.Complete(ctx, sent)
+			latency := time.Since(start)
+			res.record(newExchange(provider, attempt+1, sent, resp, err, start, latency))
+
+			if err == nil {
+				if r.opts.Usage != nil {
+					r.opts.Usage.Add(hostname, resp.Usage)
+				}
+				r.logger.Info("LLM attempt succeeded",
+					zap.String("provider", provider.Name()),
+					zap.String("model", provider.Model()),
+					zap.Int("attempt", attempt+1),
+					zap.Duration("latency", latency),
+				)
+				if pseudonyms != nil {
+					pseudonyms.restoreResponse(resp)
+				}
+				return resp, nil
+			}
+
+			r.logger.Warn("LLM attempt failed",
+				zap.String("provider", provider.Name()),
+				zap.String("model", provider.Model()),
+				zap.Int("attempt", attempt+1),
+				zap.Duration("latency", latency),
+				zap.Error(err),
+			)
+			lastErr = err
+
+			// The caller is gone or out of time; other endpoints would not help
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if !isRetryable(err) {
+				break
+			}
+		}
+		failures = append(failures, fmt.Sprintf("%s/%s: %v", provider.Name(), provider.Model(), lastErr))
+	}
+
+	return nil, fmt.Errorf("all LLM endpoints failed: %s", strings.Join(failures, "; "))
+}
+
+// sleepContext waits for d, returning early with the context error when ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newExchange builds the journal record of a single attempt
+func newExchange(provider Provider, attempt int, req *CompletionRequest, resp *CompletionResponse, err error, start time.Time, latency time.Duration) Exchange {
+	ex := Exchange{
+		Time:         start.UTC().Format(time.RFC3339),
+		Provider:     provider.Name(),
+		Model:        provider.Model(),
+		Attempt:      attempt,
+		SystemPrompt: req.SystemPrompt,
+		UserPrompt:   req.UserPrompt,
+		Messages:     req.Messages,
+		LatencyMs:    latency.Milliseconds(),
+	}
+	if err != nil {
+		ex.Error = err.Error()
+		return ex
+	}
+	ex.Response = resp.Raw
+	ex.Usage = resp.Usage
+	return ex
+}
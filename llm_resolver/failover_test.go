@@ -0,0 +1,117 @@
+package llm_resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// scriptedProvider fails with errs in order, then answers with its name
+type scriptedProvider struct {
+	name  string
+	errs  []error
+	usage Usage
+	calls int
+}
+
+func (p *scriptedProvider) Name() string  { return p.name }
+func (p *scriptedProvider) Model() string { return "test-model" }
+
+func (p *scriptedProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	p.calls++
+	if p.calls <= len(p.errs) {
+		return nil, p.errs[p.calls-1]
+	}
+	return &CompletionResponse{Content: p.name, Usage: p.usage}, nil
+}
+
+func TestBackoff(t *testing.T) {
+	for retry := 0; retry <= 10; retry++ {
+		d := backoffBase << retry
+		if d > backoffMax {
+			d = backoffMax
+		}
+		for i := 0; i < 50; i++ {
+			got := backoff(retry)
+			if got < d/2 || got > d {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", retry, got, d/2, d)
+			}
+		}
+	}
+	if got := backoff(100); got > backoffMax {
+		t.Errorf("backoff(100) = %v, want at most %v", got, backoffMax)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{&APIError{StatusCode: http.StatusInternalServerError}, true},
+		{fmt.Errorf("anthropic: %w", &APIError{StatusCode: http.StatusBadGateway}), true},
+		{&APIError{StatusCode: http.StatusUnauthorized}, false},
+		{&APIError{StatusCode: http.StatusNotFound}, false},
+		{fmt.Errorf("openai: %w", errAPIKeyNotSet), false},
+		{errors.New("connection refused"), true},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCompleteFailover(t *testing.T) {
+	unauthorized := &APIError{StatusCode: http.StatusUnauthorized}
+	overloaded := &APIError{StatusCode: http.StatusServiceUnavailable}
+
+	t.Run("non-retryable error moves to the next endpoint", func(t *testing.T) {
+		primary := &scriptedProvider{name: "primary", errs: []error{unauthorized}}
+		fallback := &scriptedProvider{name: "fallback"}
+		r := NewResolver([]Endpoint{{Provider: primary, Retries: 2}, {Provider: fallback}}, ResolverOptions{}, zap.NewNop())
+
+		resp, err := r.complete(context.Background(), nil, &CompletionRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Content != "fallback" || primary.calls != 1 || fallback.calls != 1 {
+			t.Errorf("answer from %q after %d+%d calls, want fallback after 1+1", resp.Content, primary.calls, fallback.calls)
+		}
+	})
+
+	t.Run("retryable error is retried on the same endpoint", func(t *testing.T) {
+		primary := &scriptedProvider{name: "primary", errs: []error{overloaded}}
+		fallback := &scriptedProvider{name: "fallback"}
+		r := NewResolver([]Endpoint{{Provider: primary, Retries: 1}, {Provider: fallback}}, ResolverOptions{}, zap.NewNop())
+
+		start := time.Now()
+		resp, err := r.complete(context.Background(), nil, &CompletionRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Content != "primary" || primary.calls != 2 || fallback.calls != 0 {
+			t.Errorf("answer from %q after %d+%d calls, want primary after 2+0", resp.Content, primary.calls, fallback.calls)
+		}
+		if elapsed := time.Since(start); elapsed < backoffBase/2 {
+			t.Errorf("retry after %v, want a backoff of at least %v", elapsed, backoffBase/2)
+		}
+	})
+
+	t.Run("all endpoints failing", func(t *testing.T) {
+		r := NewResolver([]Endpoint{
+			{Provider: &scriptedProvider{name: "primary", errs: []error{unauthorized}}},
+			{Provider: &scriptedProvider{name: "fallback", errs: []error{overloaded}}},
+		}, ResolverOptions{}, zap.NewNop())
+
+		if _, err := r.complete(context.Background(), nil, &CompletionRequest{}); err == nil {
+			t.Error("got no error")
+		}
+	})
+}
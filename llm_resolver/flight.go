@@ -0,0 +1,154 @@
+package llm_resolver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// flightGroup deduplicates concurrent resolutions for the same key. Unlike
+// singleflight, the work runs on a context detached from the first caller:
+// it keeps going while any caller is still waiting and is cancelled once the
+// last one has gone away.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is one in-progress resolution
+type flight struct {
+	done     chan struct{}
+	val      interface{}
+	err      error
+	waiters  int
+	shared   bool
+	cancel   context.CancelFunc
+	progress *Progress
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: make(map[string]*flight)}
+}
+
+// flightKey identifies the flight for a cache key. Forced resolutions and
+// custom prompts get flights of their own, so a caller never joins a
+// resolution that asks a different question than its own.
+func flightKey(cacheKey string, force bool, userPrompt string) string {
+	if !force && userPrompt == "" {
+		return cacheKey
+	}
+	return fmt.Sprintf("%s\x00force=%t\x00prompt=%s", cacheKey, force, userPrompt)
+}
+
+// Do runs fn once per key at a time and returns its result to every caller
+// waiting on the key. fn gets a context carrying the values of ctx, limited
+// to timeout when positive. A caller whose ctx is done stops waiting and gets
+// the context error; shared reports whether the flight had other callers.
+func (g *flightGroup) Do(ctx context.Context, key string, timeout time.Duration, fn func(ctx context.Context) (interface{}, error)) (v interface{}, err error, shared bool) {
+	f := g.join(ctx, key, timeout, fn, true)
+	return g.wait(ctx, key, f)
+}
+
+// Start runs fn for key in the background unless it is already in flight.
+// The work is not tied to ctx; it finishes (or times out) even if nobody
+// waits for it, so a later Do or Watch can pick up the result.
+func (g *flightGroup) Start(ctx context.Context, key string, timeout time.Duration, fn func(ctx context.Context) (interface{}, error)) {
+	g.join(ctx, key, timeout, fn, false)
+}
+
+// Watch is Do that also passes every progress event of the flight to
+// onProgress, starting with the ones reported before the call
+func (g *flightGroup) Watch(ctx context.Context, key string, timeout time.Duration, fn func(ctx context.Context) (interface{}, error), onProgress func(ProgressEvent)) (interface{}, error) {
+	f := g.join(ctx, key, timeout, fn, true)
+
+	seen := 0
+	for {
+		events, changed := f.progress.since(seen)
+		seen += len(events)
+		for _, event := range events {
+			onProgress(event)
+		}
+
+		select {
+		case <-changed:
+		case <-f.done:
+			events, _ := f.progress.since(seen)
+			for _, event := range events {
+				onProgress(event)
+			}
+			v, err, _ := g.wait(ctx, key, f)
+			return v, err
+		case <-ctx.Done():
+			v, err, _ := g.wait(ctx, key, f)
+			return v, err
+		}
+	}
+}
+
+// join returns the flight for key, starting it if needed, and counts the
+// caller as a waiter when wait is set
+func (g *flightGroup) join(ctx context.Context, key string, timeout time.Duration, fn func(ctx context.Context) (interface{}, error), wait bool) *flight {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if f, ok := g.flights[key]; ok {
+		if wait {
+			f.waiters++
+			f.shared = true
+		}
+		return f
+	}
+
+	var workCtx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		workCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	} else {
+		workCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+	}
+	f := &flight{done: make(chan struct{}), cancel: cancel, progress: newProgress()}
+	if wait {
+		f.waiters = 1
+	}
+	g.flights[key] = f
+	go g.run(withProgress(workCtx, f.progress), key, f, fn)
+	return f
+}
+
+// wait blocks until the flight is done or the caller's ctx is
+func (g *flightGroup) wait(ctx context.Context, key string, f *flight) (v interface{}, err error, shared bool) {
+	select {
+	case <-f.done:
+		g.mu.Lock()
+		shared = f.shared
+		g.mu.Unlock()
+		return f.val, f.err, shared
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Nobody is waiting anymore; abandon the work so the next
+			// request for the key starts a fresh resolution
+			f.cancel()
+			if g.flights[key] == f {
+				delete(g.flights, key)
+			}
+		}
+		shared = f.shared
+		g.mu.Unlock()
+		return nil, ctx.Err(), shared
+	}
+}
+
+func (g *flightGroup) run(ctx context.Context, key string, f *flight, fn func(ctx context.Context) (interface{}, error)) {
+	f.val, f.err = fn(ctx)
+	f.cancel()
+
+	g.mu.Lock()
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+	g.mu.Unlock()
+	close(f.done)
+}
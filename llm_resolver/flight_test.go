@@ -0,0 +1,263 @@
+package llm_resolver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waiters returns the number of callers waiting on the flight for key, or
+// -1 if nothing is in flight
+func (g *flightGroup) waiters(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f, ok := g.flights[key]; ok {
+		return f.waiters
+	}
+	return -1
+}
+
+// eventually fails the test unless cond holds within a second
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// blockingWork returns work that counts its runs, hands its context to
+// started and returns "done" once release is closed or ctx is done
+func blockingWork(runs *atomic.Int32, started chan<- context.Context, release <-chan struct{}) func(ctx context.Context) (interface{}, error) {
+	return func(ctx context.Context) (interface{}, error) {
+		runs.Add(1)
+		started <- ctx
+		select {
+		case <-release:
+			return "done", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func TestFlightGroupDoShares(t *testing.T) {
+	g := newFlightGroup()
+	var runs atomic.Int32
+	started := make(chan context.Context, 2)
+	release := make(chan struct{})
+	work := blockingWork(&runs, started, release)
+
+	type result struct {
+		v      interface{}
+		err    error
+		shared bool
+	}
+	results := make(chan result, 2)
+	do := func() {
+		v, err, shared := g.Do(context.Background(), "key", 0, work)
+		results <- result{v, err, shared}
+	}
+
+	go do()
+	<-started
+	go do()
+	eventually(t, "both callers wait", func() bool { return g.waiters("key") == 2 })
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		r := <-results
+		if r.v != "done" || r.err != nil || !r.shared {
+			t.Errorf("Do = %v, %v, shared %v; want done, nil, shared", r.v, r.err, r.shared)
+		}
+	}
+	if n := runs.Load(); n != 1 {
+		t.Errorf("work ran %d times, want 1", n)
+	}
+	if n := g.waiters("key"); n != -1 {
+		t.Errorf("flight still registered with %d waiters", n)
+	}
+}
+
+func TestFlightGroupCancelsOnLastLeave(t *testing.T) {
+	g := newFlightGroup()
+	var runs atomic.Int32
+	started := make(chan context.Context, 2)
+	release := make(chan struct{})
+	work := blockingWork(&runs, started, release)
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, err, _ := g.Do(ctx1, "key", 0, work)
+		errs <- err
+	}()
+	workCtx := <-started
+	go func() {
+		_, err, _ := g.Do(ctx2, "key", 0, work)
+		errs <- err
+	}()
+	eventually(t, "both callers wait", func() bool { return g.waiters("key") == 2 })
+
+	// The work goes on while a caller is left
+	cancel1()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("first caller got %v, want context.Canceled", err)
+	}
+	if n := g.waiters("key"); n != 1 {
+		t.Fatalf("%d waiters after the first caller left, want 1", n)
+	}
+	select {
+	case <-workCtx.Done():
+		t.Fatal("work cancelled while a caller is still waiting")
+	default:
+	}
+
+	// ...and is abandoned once the last one leaves
+	cancel2()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("second caller got %v, want context.Canceled", err)
+	}
+	select {
+	case <-workCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("work not cancelled after the last caller left")
+	}
+
+	// The next caller starts afresh
+	go func() {
+		v, err, _ := g.Do(context.Background(), "key", 0, work)
+		if v != "done" || err != nil {
+			t.Errorf("fresh Do = %v, %v", v, err)
+		}
+		errs <- err
+	}()
+	<-started
+	close(release)
+	<-errs
+	if n := runs.Load(); n != 2 {
+		t.Errorf("work ran %d times, want 2", n)
+	}
+}
+
+func TestFlightGroupStartOutlivesCaller(t *testing.T) {
+	g := newFlightGroup()
+	var runs atomic.Int32
+	started := make(chan context.Context, 1)
+	release := make(chan struct{})
+	work := blockingWork(&runs, started, release)
+
+	type ctxKey struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "value"))
+	g.Start(ctx, "key", time.Minute, work)
+	workCtx := <-started
+	cancel()
+
+	if n := g.waiters("key"); n != 0 {
+		t.Errorf("Start counted %d waiters, want 0", n)
+	}
+	if workCtx.Err() != nil {
+		t.Fatal("work cancelled with the caller of Start")
+	}
+	if workCtx.Value(ctxKey{}) != "value" {
+		t.Error("work context lost the caller's values")
+	}
+	if _, ok := workCtx.Deadline(); !ok {
+		t.Error("work context has no deadline")
+	}
+
+	// A later caller joins the flight instead of starting another one
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		v, err, shared := g.Do(context.Background(), "key", time.Minute, work)
+		if v != "done" || err != nil || !shared {
+			t.Errorf("Do = %v, %v, shared %v; want done, nil, shared", v, err, shared)
+		}
+	}()
+	eventually(t, "Do joins the flight", func() bool { return g.waiters("key") == 1 })
+	close(release)
+	<-done
+	if n := runs.Load(); n != 1 {
+		t.Errorf("work ran %d times, want 1", n)
+	}
+}
+
+func TestFlightGroupTimeout(t *testing.T) {
+	g := newFlightGroup()
+	_, err, _ := g.Do(context.Background(), "key", 10*time.Millisecond, func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestFlightGroupWatch(t *testing.T) {
+	g := newFlightGroup()
+	reported := make(chan struct{})
+	release := make(chan struct{})
+	work := func(ctx context.Context) (interface{}, error) {
+		reportProgress(ctx, StageDiscovering, "first")
+		close(reported)
+		<-release
+		reportProgress(ctx, StageAsking, "second")
+		return "done", nil
+	}
+
+	g.Start(context.Background(), "key", 0, work)
+	<-reported
+
+	var mu sync.Mutex
+	var stages []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		v, err := g.Watch(context.Background(), "key", 0, work, func(event ProgressEvent) {
+			mu.Lock()
+			stages = append(stages, event.Stage)
+			mu.Unlock()
+		})
+		if v != "done" || err != nil {
+			t.Errorf("Watch = %v, %v", v, err)
+		}
+	}()
+	eventually(t, "Watch joins the flight", func() bool { return g.waiters("key") == 1 })
+	close(release)
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(stages) != 2 || stages[0] != StageDiscovering || stages[1] != StageAsking {
+		t.Errorf("Watch saw stages %v, want [%s %s]", stages, StageDiscovering, StageAsking)
+	}
+}
+
+func TestFlightKey(t *testing.T) {
+	plain := flightKey("blog.localhost", false, "")
+	if plain != "blog.localhost" {
+		t.Errorf("plain key = %q, want the cache key", plain)
+	}
+
+	// Forced and prompted resolutions never share a flight with plain ones
+	keys := map[string]bool{plain: true}
+	for _, key := range []string{
+		flightKey("blog.localhost", true, ""),
+		flightKey("blog.localhost", false, "use the docker one"),
+		flightKey("blog.localhost", true, "use the docker one"),
+		flightKey("blog.localhost", false, "use the process"),
+	} {
+		if keys[key] {
+			t.Errorf("duplicate flight key %q", key)
+		}
+		keys[key] = true
+	}
+}
@@ -1,16 +1,23 @@
 package llm_resolver
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
 	"go.uber.org/zap"
 )
 
+// maxDashboardResolutions is the number of journal entries shown on the dashboard
+const maxDashboardResolutions = 20
+
 // ServeHTTP implements caddyhttp.MiddlewareHandler.
 func (m *LLMResolver) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
 	hostname := extractHostname(r)
@@ -30,6 +37,7 @@ func (m *LLMResolver) ServeHTTP(w http.ResponseWriter, r *http.Request, next cad
 			domain = hostname
 		}
 		if strings.HasSuffix(domain, ".localhost") {
+			m.resolveSpeculatively(r.Context(), strings.ToLower(domain))
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte("OK"))
 			return nil
@@ -44,6 +52,52 @@ func (m *LLMResolver) ServeHTTP(w http.ResponseWriter, r *http.Request, next cad
 		return m.handleMappingsAPI(w, r)
 	}
 
+	// Explained resolution of any hostname
+	if r.URL.Path == "/_api/resolve" {
+		return m.handleResolveAPI(w, r)
+	}
+
+	// Ambiguous resolutions waiting for a choice
+	if r.URL.Path == "/_api/picks" || strings.HasPrefix(r.URL.Path, "/_api/picks/") {
+		return m.handlePicksAPI(w, r)
+	}
+
+	// Manual corrections used as prompt examples
+	if r.URL.Path == "/_api/corrections" {
+		return m.handleCorrectionsAPI(w, r)
+	}
+
+	// Processes started from launch commands
+	if r.URL.Path == "/_api/launched" || strings.HasPrefix(r.URL.Path, "/_api/launched/") {
+		return m.handleLaunchedAPI(w, r)
+	}
+
+	// Resolution journal
+	if r.URL.Path == "/_api/resolutions" || strings.HasPrefix(r.URL.Path, "/_api/resolutions/") {
+		return m.handleResolutionsAPI(w, r)
+	}
+
+	// Picker page, also linked from the dashboard
+	if r.URL.Path == pickPath {
+		choices := m.choicesFor(hostname)
+		if len(choices) == 0 {
+			http.Error(w, fmt.Sprintf("No choices recorded for %s", hostname), http.StatusNotFound)
+			return nil
+		}
+		servePickerPage(w, r, hostname, choices, m.cache.Get(hostname))
+		return nil
+	}
+
+	// Progress of the resolution shown on the resolving page
+	if r.URL.Path == resolveEventsPath {
+		return m.handleResolveEvents(w, r, hostname)
+	}
+
+	// Liveness of the target shown on the "is down" page
+	if r.URL.Path == upstreamEventsPath {
+		return m.handleUpstreamEvents(w, r, hostname)
+	}
+
 	// Debug endpoint
 	if hostname == "proxy.localhost" || r.URL.Path == "/_debug" {
 		return m.handleDebug(w, r)
@@ -64,6 +118,11 @@ func (m *LLMResolver) ServeHTTP(w http.ResponseWriter, r *http.Request, next cad
 	force := r.URL.Query().Has("force")
 	userPrompt := r.URL.Query().Get("prompt")
 
+	// Explain how this hostname would resolve, without caching or proxying
+	if r.URL.Query().Has("explain") {
+		return m.serveExplanation(w, r, hostname, userPrompt, true)
+	}
+
 	// Get or resolve target
 	var mapping *RouteMapping
 	var err error
@@ -71,64 +130,110 @@ func (m *LLMResolver) ServeHTTP(w http.ResponseWriter, r *http.Request, next cad
 	if !force {
 		mapping = m.cache.Get(hostname)
 	}
+	cached := mapping != nil
 
 	if mapping == nil {
+		// Nothing is proxied while the user has yet to pick a target
+		if pick := m.picks.Get(hostname); pick != nil && !force {
+			servePickerPage(w, r, hostname, pick.Choices, nil)
+			return nil
+		}
+
+		// Junk hostnames and recent failures are answered without resolving
+		if err := m.hostFilter.Check(hostname, force); err != nil {
+			m.logger.Debug("not resolving hostname", zap.String("hostname", hostname), zap.Error(err))
+			m.serveNotFound(w, r, hostname, err)
+			return nil
+		}
+
 		m.logger.Info("resolving target",
 			zap.String("hostname", hostname),
 			zap.Bool("forced", force),
 		)
 
-		// Use singleflight to deduplicate concurrent requests for same hostname
-		result, err, shared := m.resolveGroup.Do(hostname, func() (interface{}, error) {
-			// Double-check cache inside singleflight (another request may have just finished)
-			if cached := m.cache.Get(hostname); cached != nil && !force {
-				return cached, nil
-			}
+		// Browser navigations get a progress page right away instead of a
+		// hanging tab; the page follows the resolution over SSE
+		if isNavigation(r) {
+			m.resolveGroup.Start(r.Context(), flightKey(hostname, force, userPrompt), time.Duration(m.ResolveTimeout), m.resolveHostname(hostname, force, userPrompt))
+			serveResolvingPage(w, hostname, resolvedURL(r), resolveEventsURL(force, userPrompt))
+			return nil
+		}
 
-			resolved, err := m.resolver.ResolveTarget(hostname, userPrompt, m.cache.GetAll())
-			if err != nil {
-				return nil, err
+		// Deduplicate concurrent requests for the same hostname. The resolution
+		// outlives this request as long as another client is waiting for it.
+		result, err, _ := m.resolveGroup.Do(r.Context(), flightKey(hostname, force, userPrompt), time.Duration(m.ResolveTimeout), m.resolveHostname(hostname, force, userPrompt))
+		if err != nil {
+			var limitErr *LimitError
+			if errors.As(err, &limitErr) {
+				m.logger.Warn("refusing LLM resolution",
+					zap.String("hostname", hostname),
+					zap.Error(err),
+				)
+				serveLimitPage(w, r, hostname, limitErr)
+				return nil
 			}
-
-			// Cache the result
-			m.cache.Set(hostname, resolved)
-			if err := m.cache.Save(); err != nil {
-				m.logger.Warn("failed to save cache", zap.Error(err))
+			if r.Context().Err() != nil {
+				// The client went away; nobody is left to answer
+				return nil
+			}
+			var rejected *RejectedError
+			if errors.As(err, &rejected) || errors.Is(err, ErrNoMatch) {
+				m.serveNotFound(w, r, hostname, err)
+				return nil
+			}
+			var ambiguous *AmbiguousError
+			if errors.As(err, &ambiguous) {
+				servePickerPage(w, r, hostname, ambiguous.Choices, nil)
+				return nil
 			}
-
-			return resolved, nil
-		})
-
-		if err != nil {
 			m.logger.Error("failed to resolve target",
 				zap.String("hostname", hostname),
 				zap.Error(err),
 			)
-			http.Error(w, fmt.Sprintf("Failed to resolve target: %v", err), http.StatusBadGateway)
+			http.Error(w, fmt.Sprintf("Failed to resolve target: %v", err), resolveErrorStatus(err))
 			return nil
 		}
 
 		mapping = result.(*RouteMapping)
-
-		m.logger.Info("resolved target",
-			zap.String("hostname", hostname),
-			zap.String("type", mapping.Type),
-			zap.String("target", mapping.Target),
-			zap.Int("port", mapping.Port),
-			zap.String("reason", mapping.LLMReason),
-			zap.Bool("shared", shared),
-		)
 	}
 
-	// Build upstream URL
-	upstream, err := m.buildUpstreamURL(mapping)
+	// Build upstream URL. A mapping with a launch command starts its target
+	// when it is not running, and a mapping in wait mode holds the request
+	// while its target restarts. A cached mapping whose target has vanished
+	// is re-resolved once, unless the user chose it; until the target is
+	// back or replaced, requests get a "target is down" page.
+	upstream, err := m.liveUpstream(mapping)
+	if err != nil && mapping.Launch != nil && mapping.Type == "process" {
+		upstream, err = m.launchUpstream(r.Context(), hostname, mapping)
+	} else if err != nil && mapping.Wait && staleFor(mapping) < time.Duration(m.WaitTimeout) {
+		upstream, err = m.waitForUpstream(r.Context(), hostname, mapping, err)
+	}
 	if err != nil {
-		m.logger.Error("failed to build upstream URL",
-			zap.String("hostname", hostname),
-			zap.Error(err),
-		)
-		http.Error(w, fmt.Sprintf("Failed to build upstream: %v", err), http.StatusBadGateway)
-		return nil
+		if m.markStale(hostname, mapping, err) && cached && !keepsTarget(mapping) {
+			m.logger.Info("re-resolving stale mapping", zap.String("hostname", hostname))
+			resolved, resolveErr := m.reresolveStale(r.Context(), hostname)
+			switch {
+			case resolveErr == nil:
+				mapping = resolved
+				if upstream, err = m.liveUpstream(mapping); err != nil {
+					m.markStale(hostname, mapping, err)
+				}
+			case r.Context().Err() != nil:
+				// The client went away; nobody is left to answer
+				return nil
+			default:
+				m.logger.Warn("re-resolution failed, keeping stale mapping",
+					zap.String("hostname", hostname),
+					zap.Error(resolveErr),
+				)
+			}
+		}
+		if err != nil {
+			m.serveTargetDown(w, r, hostname, mapping, err)
+			return nil
+		}
+	} else {
+		m.markSeen(hostname, mapping)
 	}
 
 	m.logger.Debug("proxying request",
@@ -136,10 +241,300 @@ func (m *LLMResolver) ServeHTTP(w http.ResponseWriter, r *http.Request, next cad
 		zap.String("upstream", upstream),
 	)
 
-	// Set the upstream variable for reverse_proxy to use
+	return m.proxy(w, r, next, hostname, mapping, upstream)
+}
+
+// proxy hands the request to reverse_proxy through the upstream variable.
+// When the upstream refuses the connection, e.g. because the dev server
+// restarted on another port, the upstream is rebuilt from fresh discovery
+// (or waited for, in wait mode) and a retryable request is sent once more.
+// If it still cannot be reached, the client gets the "is down" page.
+func (m *LLMResolver) proxy(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler, key string, mapping *RouteMapping, upstream string) error {
 	caddyhttp.SetVar(r.Context(), "upstream", upstream)
+	err := next.ServeHTTP(w, r)
+	if dialErr := dialError(err); dialErr != nil && canResend(r) {
+		if retryUpstream, ok := m.retryUpstream(r.Context(), key, mapping, upstream, dialErr); ok {
+			m.logger.Info("upstream refused connection, retrying",
+				zap.String("hostname", key),
+				zap.String("upstream", upstream),
+				zap.String("retry", retryUpstream),
+				zap.Error(dialErr),
+			)
+			caddyhttp.SetVar(r.Context(), "upstream", retryUpstream)
+			err = next.ServeHTTP(w, r)
+		}
+	}
+
+	if dialErr := dialError(err); dialErr != nil && r.Context().Err() == nil {
+		m.serveTargetDown(w, r, key, mapping, dialErr)
+		return nil
+	}
+	return err
+}
+
+// retryUpstream returns where to resend a request after a dial failure: the
+// rebuilt upstream if it changed, or in wait mode the upstream once it is
+// back, which may be the same one
+func (m *LLMResolver) retryUpstream(ctx context.Context, key string, mapping *RouteMapping, upstream string, dialErr error) (string, bool) {
+	if mapping.Wait {
+		retryUpstream, err := m.waitForUpstream(ctx, key, mapping, dialErr)
+		return retryUpstream, err == nil
+	}
+	m.processCache.Invalidate()
+	retryUpstream, err := m.buildUpstreamURL(mapping)
+	return retryUpstream, err == nil && retryUpstream != upstream
+}
+
+// dialError returns the failure to connect to the upstream behind a
+// reverse_proxy error, or nil. Nothing has been sent to the upstream or to
+// the client in that case.
+func dialError(err error) error {
+	var dialErr reverseproxy.DialError
+	if err != nil && errors.As(err, &dialErr) {
+		return dialErr
+	}
+	return nil
+}
+
+// canResend reports whether a request can be sent again: an idempotent
+// method without a body, which reverse_proxy has consumed
+func canResend(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return r.ContentLength == 0
+	}
+	return false
+}
+
+// resolveHostname returns the resolveGroup work that resolves and caches a hostname
+func (m *LLMResolver) resolveHostname(hostname string, force bool, userPrompt string) func(ctx context.Context) (interface{}, error) {
+	return func(ctx context.Context) (interface{}, error) {
+		// Double-check cache inside the flight (another request may have just finished)
+		if cached := m.cache.Get(hostname); cached != nil && !force {
+			return cached, nil
+		}
+		if err := m.hostFilter.Check(hostname, force); err != nil {
+			return nil, err
+		}
+
+		resolved, err := m.resolver.ResolveTarget(ctx, hostname, userPrompt, m.cache.GetAll())
+		if err != nil {
+			// Keep ambiguous results for the picker. Remember other
+			// failures, unless it is the daily limit or every client went away.
+			var ambiguous *AmbiguousError
+			var limitErr *LimitError
+			switch {
+			case errors.As(err, &ambiguous):
+				m.picks.Set(hostname, ambiguous.Choices)
+			case !errors.As(err, &limitErr) && !errors.Is(err, context.Canceled):
+				m.hostFilter.Fail(hostname, err)
+			}
+			return nil, err
+		}
+		m.hostFilter.Forget(hostname)
+		m.picks.Delete(hostname)
+
+		// Cache the result
+		m.cache.Replace(hostname, resolved)
+		if err := m.cache.Save(); err != nil {
+			m.logger.Warn("failed to save cache", zap.Error(err))
+		}
+
+		m.logger.Info("resolved target",
+			zap.String("hostname", hostname),
+			zap.String("type", resolved.Type),
+			zap.String("target", resolved.Target),
+			zap.Int("port", resolved.Port),
+			zap.String("reason", resolved.LLMReason),
+			zap.String("tier", resolved.Tier),
+		)
+
+		return resolved, nil
+	}
+}
+
+// resolveRelated returns the resolveGroup work that resolves and caches a
+// related service of an origin hostname
+func (m *LLMResolver) resolveRelated(originHostname, serviceName string, force bool, userPrompt string) func(ctx context.Context) (interface{}, error) {
+	cacheKey := fmt.Sprintf("%s:%s", originHostname, serviceName)
+	return func(ctx context.Context) (interface{}, error) {
+		// Double-check cache inside the flight
+		if cached := m.cache.Get(cacheKey); cached != nil && !force {
+			return cached, nil
+		}
+
+		// Get origin mapping for context
+		originMapping := m.cache.Get(originHostname)
+
+		resolved, err := m.resolver.ResolveRelatedService(
+			ctx,
+			originHostname,
+			originMapping,
+			serviceName,
+			userPrompt,
+			m.cache.GetAll(),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Cache the result
+		m.cache.Replace(cacheKey, resolved)
+		if err := m.cache.Save(); err != nil {
+			m.logger.Warn("failed to save cache", zap.Error(err))
+		}
+
+		m.logger.Info("resolved related service",
+			zap.String("origin", originHostname),
+			zap.String("service", serviceName),
+			zap.String("type", resolved.Type),
+			zap.String("target", resolved.Target),
+			zap.Int("port", resolved.Port),
+		)
 
-	return next.ServeHTTP(w, r)
+		return resolved, nil
+	}
+}
+
+// handleResolveEvents streams the progress of a hostname's resolution as
+// server-sent events for the resolving page. A "done" event carries the
+// mapping, a "failed" event the error. The force and prompt parameters select
+// the flight the page was served for. A resolution is started if none is in
+// flight, which returns the cached mapping right away when there is one, so
+// a forced resolution that already finished is not repeated.
+func (m *LLMResolver) handleResolveEvents(w http.ResponseWriter, r *http.Request, hostname string) error {
+	query := r.URL.Query()
+	key := flightKey(hostname, query.Has("force"), query.Get("prompt"))
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, data interface{}) {
+		payload, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		rc.Flush()
+	}
+
+	result, err := m.resolveGroup.Watch(r.Context(), key, time.Duration(m.ResolveTimeout), m.resolveHostname(hostname, false, query.Get("prompt")), func(event ProgressEvent) {
+		send("progress", event)
+	})
+	if r.Context().Err() != nil {
+		return nil
+	}
+	if err != nil {
+		// The page reloads on no match or an ambiguous answer to show the
+		// services to route to
+		var ambiguous *AmbiguousError
+		send("failed", map[string]interface{}{
+			"message": err.Error(),
+			"noMatch": errors.Is(err, ErrNoMatch),
+			"pick":    errors.As(err, &ambiguous),
+		})
+		return nil
+	}
+	send("done", result.(*RouteMapping))
+	return nil
+}
+
+// handleUpstreamEvents streams to the "is down" page until the target of a
+// mapping accepts connections again, then sends "up" so the page reloads. A
+// replaced or removed mapping counts as up too; the reload shows what
+// happens now. ?key= selects a related service mapping.
+func (m *LLMResolver) handleUpstreamEvents(w http.ResponseWriter, r *http.Request, hostname string) error {
+	key := hostname
+	if k := r.URL.Query().Get("key"); k != "" {
+		key = k
+	}
+	mapping := m.cache.Get(key)
+	if mapping == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return nil
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, data interface{}) {
+		payload, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		rc.Flush()
+	}
+
+	ticker := time.NewTicker(downPollInterval)
+	defer ticker.Stop()
+	lastMessage := ""
+	for {
+		current := m.cache.Get(key)
+		if current == nil || current.Type != mapping.Type || current.Target != mapping.Target || current.Port != mapping.Port {
+			send("up", current)
+			return nil
+		}
+
+		// Open pages share one process discovery per poll
+		if current.Type == "process" {
+			m.processCache.GetFresh(downPollInterval)
+		}
+		upstream, err := m.liveUpstream(current)
+		if err == nil {
+			err = dialUpstream(r.Context(), upstream)
+		}
+		if err == nil {
+			m.markSeen(key, current)
+			send("up", current)
+			return nil
+		}
+		if err.Error() != lastMessage {
+			lastMessage = err.Error()
+			send("waiting", map[string]string{"message": lastMessage})
+		}
+
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// serveTargetDown answers for a mapping whose target is not running or does
+// not accept connections
+func (m *LLMResolver) serveTargetDown(w http.ResponseWriter, r *http.Request, key string, mapping *RouteMapping, err error) {
+	// Show the stale state recorded for the mapping, if still current
+	if current := m.cache.Get(key); current != nil && current.Type == mapping.Type && current.Target == mapping.Target && current.Port == mapping.Port {
+		mapping = current
+	}
+	var lastSeen *Sighting
+	if sighting, ok := m.sightings.Get(key); ok {
+		lastSeen = &sighting
+	}
+	serveTargetDownPage(w, r, key, mapping, err, lastSeen, m.picks.Get(key) != nil)
+}
+
+// serveNotFound answers a hostname that is not resolved. When nothing
+// matched, the discovered services are listed so the hostname can be routed
+// to one of them by hand.
+func (m *LLMResolver) serveNotFound(w http.ResponseWriter, r *http.Request, hostname string, err error) {
+	var candidates Candidates
+	if errors.Is(err, ErrNoMatch) && wantsHTML(r) {
+		processes, containers := m.resolver.discover(r.Context())
+		candidates = buildCandidates(processes, containers)
+	}
+	serveNotFoundPage(w, r, hostname, err, m.cache.GetAll(), candidates)
+}
+
+// resolveErrorStatus maps a resolution error to a response status
+func resolveErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	if errors.Is(err, ErrNoMatch) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadGateway
 }
 
 // handleSecondLevelProxy handles /_proxy/serviceName/path requests
@@ -177,56 +572,32 @@ func (m *LLMResolver) handleSecondLevelProxy(w http.ResponseWriter, r *http.Requ
 	}
 
 	if mapping == nil {
-		// Use singleflight to deduplicate concurrent requests for same cache key
-		result, err, shared := m.resolveGroup.Do(cacheKey, func() (interface{}, error) {
-			// Double-check cache inside singleflight
-			if cached := m.cache.Get(cacheKey); cached != nil && !force {
-				return cached, nil
-			}
-
-			// Get origin mapping for context
-			originMapping := m.cache.Get(originHostname)
-
-			resolved, err := m.resolver.ResolveRelatedService(
-				originHostname,
-				originMapping,
-				serviceName,
-				userPrompt,
-				m.cache.GetAll(),
-			)
-			if err != nil {
-				return nil, err
+		// Deduplicate concurrent requests for the same cache key
+		result, err, _ := m.resolveGroup.Do(r.Context(), flightKey(cacheKey, force, userPrompt), time.Duration(m.ResolveTimeout), m.resolveRelated(originHostname, serviceName, force, userPrompt))
+		if err != nil {
+			var limitErr *LimitError
+			if errors.As(err, &limitErr) {
+				m.logger.Warn("refusing LLM resolution",
+					zap.String("origin", originHostname),
+					zap.String("service", serviceName),
+					zap.Error(err),
+				)
+				serveLimitPage(w, r, cacheKey, limitErr)
+				return nil
 			}
-
-			// Cache the result
-			m.cache.Set(cacheKey, resolved)
-			if err := m.cache.Save(); err != nil {
-				m.logger.Warn("failed to save cache", zap.Error(err))
+			if r.Context().Err() != nil {
+				return nil
 			}
-
-			return resolved, nil
-		})
-
-		if err != nil {
 			m.logger.Error("failed to resolve related service",
 				zap.String("origin", originHostname),
 				zap.String("service", serviceName),
 				zap.Error(err),
 			)
-			http.Error(w, fmt.Sprintf("Failed to resolve service: %v", err), http.StatusBadGateway)
+			http.Error(w, fmt.Sprintf("Failed to resolve service: %v", err), resolveErrorStatus(err))
 			return nil
 		}
 
 		mapping = result.(*RouteMapping)
-
-		m.logger.Info("resolved related service",
-			zap.String("origin", originHostname),
-			zap.String("service", serviceName),
-			zap.String("type", mapping.Type),
-			zap.String("target", mapping.Target),
-			zap.Int("port", mapping.Port),
-			zap.Bool("shared", shared),
-		)
 	}
 
 	// Build upstream URL
@@ -239,10 +610,7 @@ func (m *LLMResolver) handleSecondLevelProxy(w http.ResponseWriter, r *http.Requ
 	// Modify request path to remove /_proxy/serviceName prefix
 	r.URL.Path = remainingPath
 
-	// Set upstream for reverse_proxy
-	caddyhttp.SetVar(r.Context(), "upstream", upstream)
-
-	return next.ServeHTTP(w, r)
+	return m.proxy(w, r, next, cacheKey, mapping, upstream)
 }
 
 // handleDebug returns debug information
@@ -257,8 +625,12 @@ func (m *LLMResolver) handleDebug(w http.ResponseWriter, r *http.Request) error
 	// Return JSON
 	data := map[string]interface{}{
 		"mappings":   m.cache.GetAll(),
+		"provider":   m.Provider,
 		"model":      m.Model,
 		"cache_file": m.CacheFile,
+		"usage":      m.usage.Snapshot(),
+		"picks":      m.picks.List(),
+		"launched":   m.launcher.List(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -268,10 +640,17 @@ func (m *LLMResolver) handleDebug(w http.ResponseWriter, r *http.Request) error
 // handleDebugHTML returns an HTML debug page
 func (m *LLMResolver) handleDebugHTML(w http.ResponseWriter, r *http.Request) error {
 	// Get discovery data for the page
-	processes, _ := DiscoverLocalProcesses()
-	containers, _ := DiscoverDockerContainers(m.ComposeProject)
+	processes, _ := DiscoverLocalProcesses(r.Context())
+	containers, _ := DiscoverDockerContainers(r.Context(), m.ComposeProject)
 	mappings := m.cache.GetAll()
 	logEntries := m.logBuffer.Entries()
+	usageToday := m.usage.Today()
+	picks := m.picks.List()
+	launched := m.launcher.List()
+	resolutions := m.journal.List("")
+	if len(resolutions) > maxDashboardResolutions {
+		resolutions = resolutions[:maxDashboardResolutions]
+	}
 
 	// Build available targets for inline editing dropdown
 	var availableTargets []map[string]interface{}
@@ -623,6 +1002,17 @@ func (m *LLMResolver) handleDebugHTML(w http.ResponseWriter, r *http.Request) er
         .tag-process::before { background: var(--green); }
         .tag-docker { background: var(--blue-bg); color: var(--blue); }
         .tag-docker::before { background: var(--blue); }
+        .tag-heuristic { background: var(--green-bg); color: var(--green); }
+        .tag-heuristic::before { background: var(--green); }
+        .tag-llm { background: var(--accent-glow); color: var(--accent); }
+        .tag-llm::before { background: var(--accent); }
+        .tag-manual { background: rgba(90, 88, 80, 0.1); color: var(--text-secondary); }
+        .tag-manual::before { background: var(--text-secondary); }
+        .tag-picked { background: rgba(90, 88, 80, 0.1); color: var(--text-secondary); }
+        .tag-picked::before { background: var(--accent-dim); }
+        .pick-link { margin-left: 6px; font-family: var(--mono); font-size: 11px; color: var(--accent-dim); }
+        .wait-toggle { margin-left: 6px; font-family: var(--mono); font-size: 11px; color: var(--text-muted); text-decoration: none; }
+        .wait-toggle.on { color: var(--accent); }
         .tag-info { background: var(--green-bg); color: var(--green); }
         .tag-info::before { background: var(--green); }
         .tag-warn { background: rgba(212, 168, 67, 0.1); color: var(--accent); }
@@ -677,6 +1067,43 @@ func (m *LLMResolver) handleDebugHTML(w http.ResponseWriter, r *http.Request) er
             box-shadow: 0 0 0 2px rgba(212, 168, 67, 0.15);
         }
 
+        .resolution-row { cursor: pointer; }
+        .resolution-detail td { padding: 0 20px 16px; background: rgba(0,0,0,0.2); }
+        .exchange {
+            border-top: 1px solid var(--border-subtle);
+            padding-top: 12px;
+            margin-top: 12px;
+        }
+        .exchange-head {
+            font-family: var(--mono);
+            font-size: 11px;
+            color: var(--text-secondary);
+            margin-bottom: 6px;
+        }
+        .exchange-label {
+            font-family: var(--mono);
+            font-size: 10px;
+            font-weight: 600;
+            color: var(--text-muted);
+            text-transform: uppercase;
+            letter-spacing: 0.08em;
+            margin-top: 8px;
+        }
+        .tail summary { cursor: pointer; color: var(--text-secondary); }
+        .tail pre, .exchange pre {
+            font-family: var(--mono);
+            font-size: 11px;
+            color: var(--text-secondary);
+            background: var(--surface);
+            border: 1px solid var(--border-subtle);
+            border-radius: 6px;
+            padding: 8px 10px;
+            max-height: 240px;
+            overflow: auto;
+            white-space: pre-wrap;
+            word-break: break-word;
+        }
+
         .empty {
             padding: 36px 20px;
             text-align: center;
@@ -719,6 +1146,11 @@ func (m *LLMResolver) handleDebugHTML(w http.ResponseWriter, r *http.Request) er
     </div>
 
     <div class="config-strip">
+        <div class="config-pair">
+            <span class="config-key">Provider</span>
+            <span class="config-val">` + m.Provider + `</span>
+        </div>
+        <div class="config-sep"></div>
         <div class="config-pair">
             <span class="config-key">Model</span>
             <span class="config-val">` + m.Model + `</span>
@@ -728,6 +1160,11 @@ func (m *LLMResolver) handleDebugHTML(w http.ResponseWriter, r *http.Request) er
             <span class="config-key">Cache</span>
             <span class="config-val">` + m.CacheFile + `</span>
         </div>
+        <div class="config-sep"></div>
+        <div class="config-pair">
+            <span class="config-key">Today</span>
+            <span class="config-val">` + fmt.Sprintf("%d req · %d tok · $%.4f", usageToday.Requests, usageToday.InputTokens+usageToday.OutputTokens, usageToday.Cost) + `</span>
+        </div>
     </div>
 
     <div class="stats">
@@ -749,6 +1186,7 @@ func (m *LLMResolver) handleDebugHTML(w http.ResponseWriter, r *http.Request) er
         </div>
     </div>
 
+` + dashboardPicks(picks) + dashboardLaunched(launched) + `
     <div class="section">
         <div class="section-head">
             <span class="section-title">Route Mappings</span>
@@ -762,7 +1200,7 @@ func (m *LLMResolver) handleDebugHTML(w http.ResponseWriter, r *http.Request) er
 	} else {
 		html += `
             <table>
-                <thead><tr><th>Hostname</th><th>Type</th><th>Target</th><th>Port</th><th>Reason</th><th></th></tr></thead>
+                <thead><tr><th>Hostname</th><th>Type</th><th>Target</th><th>Port</th><th>Tier</th><th>Reason</th><th></th></tr></thead>
                 <tbody>`
 
 		for hostname, mapping := range mappings {
@@ -770,6 +1208,31 @@ func (m *LLMResolver) handleDebugHTML(w http.ResponseWriter, r *http.Request) er
 			if mapping.Type == "docker" {
 				tagClass = "tag-docker"
 			}
+			tier := mapping.Tier
+			if tier == "" {
+				tier = TierLLM
+			}
+			pickLink := ""
+			if len(mapping.Choices) > 1 {
+				pickLink = fmt.Sprintf(`<a class="pick-link" href="https://%s%s" target="_blank" title="Choose another candidate">choose</a>`, hostname, pickPath)
+			}
+			waitClass := "wait-toggle"
+			if mapping.Wait {
+				waitClass += " on"
+			}
+			waitToggle := fmt.Sprintf(`<a class="%s" href="#" onclick="setWait('%s', %t); return false" title="Hold requests while the target restarts">wait</a>`, waitClass, hostname, !mapping.Wait)
+			launchTag := ""
+			if mapping.Launch != nil {
+				launchTag = fmt.Sprintf(`<span class="pick-link" title="%s">launch</span>`, htmlEscape(mapping.Launch.Command))
+			}
+			staleTag := ""
+			if mapping.StaleSince != "" {
+				staleTag = fmt.Sprintf(` <span class="tag tag-error" title="%s since %s">down</span>`, htmlEscape(mapping.StaleReason), mapping.StaleSince)
+			}
+			reasonOnClick := ""
+			if mapping.ResolutionID != "" {
+				reasonOnClick = fmt.Sprintf(`onclick="showResolution('%s')" style="cursor: pointer"`, mapping.ResolutionID)
+			}
 			portEditableClass := ""
 			portOnClick := ""
 			if mapping.Type != "process" {
@@ -782,9 +1245,58 @@ func (m *LLMResolver) handleDebugHTML(w http.ResponseWriter, r *http.Request) er
                     <td><span class="tag %s">%s</span></td>
                     <td class="cell-mono cell-editable" onclick="editTarget(this)">%s</td>
                     <td class="cell-dim`+portEditableClass+`" `+portOnClick+`>%d</td>
-                    <td class="cell-reason" title="%s">%s</td>
+                    <td><span class="tag tag-%s">%s</span>`+staleTag+pickLink+waitToggle+launchTag+`</td>
+                    <td class="cell-reason" title="%s" `+reasonOnClick+`>%s</td>
                     <td><button class="btn-del" onclick="deleteMapping('%s')" title="Remove"><svg viewBox="0 0 16 16" fill="none" stroke="currentColor" stroke-width="1.5"><line x1="4" y1="4" x2="12" y2="12"/><line x1="12" y1="4" x2="4" y2="12"/></svg></button></td>
-                </tr>`, hostname, mapping.Type, mapping.Target, mapping.Port, hostname, hostname, tagClass, mapping.Type, mapping.Target, mapping.Port, mapping.LLMReason, mapping.LLMReason, hostname)
+                </tr>`, hostname, mapping.Type, mapping.Target, mapping.Port, hostname, hostname, tagClass, mapping.Type, mapping.Target, mapping.Port, tier, tier, mapping.LLMReason, mapping.LLMReason, hostname)
+		}
+
+		html += `
+                </tbody>
+            </table>`
+	}
+
+	html += `
+        </div>
+    </div>
+
+    <div class="section">
+        <div class="section-head">
+            <span class="section-title">Resolutions</span>
+            <span class="section-count">` + fmt.Sprintf("%d", len(resolutions)) + `</span>
+            <div class="section-line"></div>
+        </div>
+        <div class="table-container">`
+
+	if len(resolutions) == 0 {
+		html += `<div class="empty">No resolutions recorded yet.</div>`
+	} else {
+		html += `
+            <table>
+                <thead><tr><th>Time</th><th>Hostname</th><th>Tier</th><th>Result</th><th>Calls</th><th>Tokens</th><th>Duration</th></tr></thead>
+                <tbody>`
+
+		for _, res := range resolutions {
+			host := res.Hostname
+			if res.Service != "" {
+				host += " → " + res.Service
+			}
+			tier := `<span class="tag tag-error">failed</span>`
+			result := htmlEscape(res.Error)
+			if res.Error == "" {
+				tier = fmt.Sprintf(`<span class="tag tag-%s">%s</span>`, res.Tier, res.Tier)
+				result = htmlEscape(res.Result)
+			}
+			html += fmt.Sprintf(`
+                <tr class="resolution-row" id="resolution-%s" onclick="toggleResolution('%s')">
+                    <td class="cell-dim">%s</td>
+                    <td class="cell-hostname">%s</td>
+                    <td>%s</td>
+                    <td class="cell-details" title="%s">%s</td>
+                    <td class="cell-dim">%d</td>
+                    <td class="cell-dim">%d</td>
+                    <td class="cell-dim">%dms</td>
+                </tr>`, res.ID, res.ID, res.StartedAt, host, tier, result, result, res.Calls, res.Usage.Total(), res.DurationMs)
 		}
 
 		html += `
@@ -1038,6 +1550,83 @@ func (m *LLMResolver) handleDebugHTML(w http.ResponseWriter, r *http.Request) er
         else { row.style.opacity = '1'; alert('Failed to update mapping'); }
     }
 
+    function showResolution(id) {
+        const row = document.getElementById('resolution-' + id);
+        if (!row) { alert('Resolution is no longer in the journal'); return; }
+        row.scrollIntoView({behavior: 'smooth', block: 'center'});
+        if (!row.nextElementSibling || !row.nextElementSibling.classList.contains('resolution-detail')) toggleResolution(id);
+    }
+
+    async function toggleResolution(id) {
+        const row = document.getElementById('resolution-' + id);
+        const next = row.nextElementSibling;
+        if (next && next.classList.contains('resolution-detail')) { next.remove(); return; }
+
+        const resp = await fetch('/_api/resolutions/' + encodeURIComponent(id));
+        if (!resp.ok) { alert('Failed to load resolution'); return; }
+        const res = await resp.json();
+
+        const detail = document.createElement('tr');
+        detail.className = 'resolution-detail';
+        const td = document.createElement('td');
+        td.colSpan = row.children.length;
+        detail.appendChild(td);
+
+        const block = (label, text) => {
+            if (!text) return;
+            const l = document.createElement('div');
+            l.className = 'exchange-label';
+            l.textContent = label;
+            const pre = document.createElement('pre');
+            pre.textContent = text;
+            td.lastChild.append(l, pre);
+        };
+
+        if (!res.exchanges || res.exchanges.length === 0) {
+            const div = document.createElement('div');
+            div.className = 'exchange';
+            div.textContent = 'No LLM calls (resolved by the ' + (res.tier || 'heuristic') + ' tier).';
+            td.appendChild(div);
+        }
+        (res.exchanges || []).forEach((ex, i) => {
+            const div = document.createElement('div');
+            div.className = 'exchange';
+            const head = document.createElement('div');
+            head.className = 'exchange-head';
+            head.textContent = '#' + (i + 1) + '  ' + ex.provider + '/' + ex.model + '  attempt ' + ex.attempt + '  ' +
+                ex.latencyMs + 'ms  ' + ex.usage.inputTokens + ' in / ' + ex.usage.outputTokens + ' out';
+            div.appendChild(head);
+            td.appendChild(div);
+            block('System prompt', ex.systemPrompt);
+            block('User prompt', ex.userPrompt);
+            block('Tool turns', ex.messages ? JSON.stringify(ex.messages, null, 2) : '');
+            block('Response', ex.response);
+            block('Error', ex.error);
+        });
+
+        row.after(detail);
+    }
+
+    async function setWait(hostname, wait) {
+        const resp = await fetch('/_api/mappings/' + encodeURIComponent(hostname), {
+            method: 'PATCH',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify({wait: wait}),
+        });
+        if (resp.ok) location.reload();
+        else alert('Failed to update mapping');
+    }
+
+    async function stopLaunched(hostname) {
+        if (!confirm('Stop the process launched for ' + hostname + '?')) return;
+        const resp = await fetch('/_api/launched/' + encodeURIComponent(hostname), {
+            method: 'DELETE',
+            headers: {'Content-Type': 'application/json'},
+        });
+        if (resp.ok) location.reload();
+        else alert('Failed to stop process');
+    }
+
     async function deleteMapping(hostname) {
         if (!confirm('Remove route mapping for ' + hostname + '?')) return;
         const row = event.target.closest('tr');
@@ -1055,6 +1644,98 @@ func (m *LLMResolver) handleDebugHTML(w http.ResponseWriter, r *http.Request) er
 	return nil
 }
 
+// dashboardPicks renders the section of hostnames waiting for a choice, or
+// nothing when there are none
+func dashboardPicks(picks []*Pick) string {
+	if len(picks) == 0 {
+		return ""
+	}
+
+	html := `
+    <div class="section">
+        <div class="section-head">
+            <span class="section-title">Waiting for a Choice</span>
+            <span class="section-count">` + fmt.Sprintf("%d", len(picks)) + `</span>
+            <div class="section-line"></div>
+        </div>
+        <div class="table-container">
+            <table>
+                <thead><tr><th>Time</th><th>Hostname</th><th>Candidates</th></tr></thead>
+                <tbody>`
+	for _, pick := range picks {
+		labels := make([]string, len(pick.Choices))
+		for i, choice := range pick.Choices {
+			labels[i] = choiceLabel(choice)
+		}
+		candidates := htmlEscape(strings.Join(labels, ", "))
+		html += fmt.Sprintf(`
+                <tr>
+                    <td class="cell-dim">%s</td>
+                    <td class="cell-hostname"><a href="https://%s%s" target="_blank">%s</a></td>
+                    <td class="cell-details" title="%s">%s</td>
+                </tr>`, pick.CreatedAt, htmlEscape(pick.Hostname), pickPath, htmlEscape(pick.Hostname), candidates, candidates)
+	}
+	return html + `
+                </tbody>
+            </table>
+        </div>
+    </div>
+`
+}
+
+// dashboardLaunched renders the section of processes started from launch
+// commands with their output tails, or nothing when there are none
+func dashboardLaunched(launched []LaunchedStatus) string {
+	if len(launched) == 0 {
+		return ""
+	}
+
+	html := `
+    <div class="section">
+        <div class="section-head">
+            <span class="section-title">Launched Processes</span>
+            <span class="section-count">` + fmt.Sprintf("%d", len(launched)) + `</span>
+            <div class="section-line"></div>
+        </div>
+        <div class="table-container">
+            <table>
+                <thead><tr><th>Hostname</th><th>Command</th><th>PID</th><th>Port</th><th>Status</th><th>Output</th><th></th></tr></thead>
+                <tbody>`
+	for _, p := range launched {
+		status := fmt.Sprintf(`<span class="tag tag-info" title="started %d times">running</span> <span class="cell-dim">since %s</span>`, p.Starts, p.StartedAt)
+		if p.ExitedAt != "" {
+			status = fmt.Sprintf(`<span class="tag tag-error" title="%s">exited</span> <span class="cell-dim">%s</span>`, htmlEscape(p.Exit), p.ExitedAt)
+		}
+		output := ""
+		for _, stream := range []struct {
+			name  string
+			lines []string
+		}{{"stdout", p.Stdout}, {"stderr", p.Stderr}} {
+			if len(stream.lines) > 0 {
+				output += fmt.Sprintf(`<details class="tail"><summary>%s (%d)</summary><pre>%s</pre></details>`,
+					stream.name, len(stream.lines), htmlEscape(strings.Join(stream.lines, "\n")))
+			}
+		}
+		html += fmt.Sprintf(`
+                <tr>
+                    <td class="cell-hostname"><a href="https://%s" target="_blank">%s</a></td>
+                    <td class="cell-details" title="%s in %s">%s</td>
+                    <td class="cell-dim">%d</td>
+                    <td class="cell-dim">%d</td>
+                    <td>%s</td>
+                    <td>%s</td>
+                    <td><button class="btn-del" onclick="stopLaunched('%s')" title="Stop"><svg viewBox="0 0 16 16" fill="none" stroke="currentColor" stroke-width="1.5"><rect x="4" y="4" width="8" height="8"/></svg></button></td>
+                </tr>`, htmlEscape(p.Hostname), htmlEscape(p.Hostname), htmlEscape(p.Command), htmlEscape(p.Workdir), htmlEscape(p.Command),
+			p.PID, p.Port, status, output, htmlEscape(p.Hostname))
+	}
+	return html + `
+                </tbody>
+            </table>
+        </div>
+    </div>
+`
+}
+
 // handleMappingsAPI handles CRUD operations for mappings
 func (m *LLMResolver) handleMappingsAPI(w http.ResponseWriter, r *http.Request) error {
 	hostname := strings.TrimPrefix(r.URL.Path, "/_api/mappings/")
@@ -1078,9 +1759,12 @@ func (m *LLMResolver) handleMappingsAPI(w http.ResponseWriter, r *http.Request)
 
 	case http.MethodPut:
 		var body struct {
-			Type   string `json:"type"`
-			Target string `json:"target"`
-			Port   int    `json:"port"`
+			Type    string        `json:"type"`
+			Target  string        `json:"target"`
+			Port    int           `json:"port"`
+			Workdir string        `json:"workdir,omitempty"` // follows a process to other ports
+			Wait    *bool         `json:"wait,omitempty"`    // kept from the current mapping if omitted
+			Launch  *LaunchConfig `json:"launch,omitempty"`  // likewise
 		}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 			http.Error(w, "Invalid JSON", http.StatusBadRequest)
@@ -1096,18 +1780,91 @@ func (m *LLMResolver) handleMappingsAPI(w http.ResponseWriter, r *http.Request)
 			Port:      body.Port,
 			CreatedAt: timeNow(),
 			LLMReason: "Manually edited",
+			Tier:      TierManual,
+		}
+		if body.Type == "process" && body.Workdir != "" {
+			mapping.ProcessIdentifier = &ProcessIdentifier{Workdir: body.Workdir}
+		}
+		original := m.cache.Get(hostname)
+		if body.Wait != nil {
+			mapping.Wait = *body.Wait
+		} else if original != nil {
+			mapping.Wait = original.Wait
+		}
+		if body.Launch != nil {
+			if reason := launchEditError(r); reason != "" {
+				http.Error(w, reason, http.StatusForbidden)
+				return nil
+			}
+			if err := applyLaunch(mapping, body.Launch); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return nil
+			}
+		} else if original != nil && original.Launch != nil && (body.Workdir == "" || body.Workdir == launchWorkdir(original)) {
+			// Dropped if the mapping is no longer a process, or now follows
+			// a process in another workdir
+			applyLaunch(mapping, original.Launch)
 		}
 		m.cache.Set(hostname, mapping)
+		m.hostFilter.Forget(hostname)
+		m.picks.Delete(hostname)
 		if err := m.cache.Save(); err != nil {
 			http.Error(w, "Failed to save", http.StatusInternalServerError)
 			return nil
 		}
+		go m.recordCorrection(context.WithoutCancel(r.Context()), hostname, original, mapping)
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Updated"))
 		return nil
 
+	case http.MethodPatch:
+		// Only settings can be patched; the target is changed with PUT
+		var body struct {
+			Wait   *bool         `json:"wait"`
+			Launch *LaunchConfig `json:"launch"` // an empty command removes it
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || (body.Wait == nil && body.Launch == nil) {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return nil
+		}
+		mapping := m.cache.Get(hostname)
+		if mapping == nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return nil
+		}
+		updated := *mapping
+		if body.Wait != nil {
+			updated.Wait = *body.Wait
+		}
+		if body.Launch != nil {
+			if reason := launchEditError(r); reason != "" {
+				http.Error(w, reason, http.StatusForbidden)
+				return nil
+			}
+			if err := applyLaunch(&updated, body.Launch); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return nil
+			}
+		}
+		if !m.cache.Update(hostname, mapping, func(mapping *RouteMapping) {
+			*mapping = updated
+		}) {
+			http.Error(w, "Mapping changed meanwhile, try again", http.StatusConflict)
+			return nil
+		}
+		if body.Launch != nil && updated.Launch == nil {
+			go m.launcher.Stop(hostname)
+		}
+		if err := m.cache.Save(); err != nil {
+			http.Error(w, "Failed to save", http.StatusInternalServerError)
+			return nil
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(m.cache.Get(hostname))
+
 	case http.MethodDelete:
 		m.cache.Delete(hostname)
+		go m.launcher.Stop(hostname)
 		if err := m.cache.Save(); err != nil {
 			http.Error(w, "Failed to save", http.StatusInternalServerError)
 			return nil
@@ -1122,6 +1879,120 @@ func (m *LLMResolver) handleMappingsAPI(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// recordCorrection stores a manual edit of a mapping along with a fresh
+// discovery snapshot. Discovery can take a while, so it runs after the
+// response has been sent.
+func (m *LLMResolver) recordCorrection(ctx context.Context, hostname string, original, corrected *RouteMapping) {
+	processes, containers := m.resolver.discover(ctx)
+	if err := m.corrections.Record(hostname, original, corrected, processes, containers); err != nil {
+		m.logger.Warn("failed to save correction", zap.String("hostname", hostname), zap.Error(err))
+	}
+}
+
+// handleCorrectionsAPI lists manual corrections, newest first
+func (m *LLMResolver) handleCorrectionsAPI(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(m.corrections.List())
+}
+
+// handleResolutionsAPI lists journal entries (optionally ?host=) or returns
+// one entry with its LLM exchanges
+func (m *LLMResolver) handleResolutionsAPI(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/_api/resolutions")
+	id = strings.Trim(id, "/")
+	if id == "" {
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(m.journal.List(r.URL.Query().Get("host")))
+	}
+
+	res := m.journal.Get(id)
+	if res == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res)
+}
+
+// handleResolveAPI handles /_api/resolve?host=...&prompt=...&dry_run=1. It
+// runs a fresh resolution and returns its explanation; unless dry_run is set,
+// the resulting mapping is cached as with ?force.
+func (m *LLMResolver) handleResolveAPI(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	query := r.URL.Query()
+	hostname := strings.ToLower(query.Get("host"))
+	if hostname == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return nil
+	}
+	dryRun := query.Get("dry_run") != "" && query.Get("dry_run") != "0" && query.Get("dry_run") != "false"
+	return m.serveExplanation(w, r, hostname, query.Get("prompt"), dryRun)
+}
+
+// serveExplanation resolves a hostname and writes the explanation as JSON.
+// Failed resolutions still return the explanation, with an error status;
+// hostnames the host filter rejects get a 404 without one.
+func (m *LLMResolver) serveExplanation(w http.ResponseWriter, r *http.Request, hostname, userPrompt string, dryRun bool) error {
+	// Junk and denied names are not worth an LLM call here either; like
+	// ?force, a remembered failure does not count
+	var rejected *RejectedError
+	if err := m.hostFilter.Check(hostname, true); errors.As(err, &rejected) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		return json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":    "rejected",
+			"message":  rejected.Error(),
+			"hostname": hostname,
+			"reason":   rejected.Reason,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(m.ResolveTimeout))
+	defer cancel()
+
+	status := http.StatusOK
+	exp, err := m.resolver.Explain(ctx, hostname, userPrompt, m.cache.GetAll(), dryRun)
+	if err != nil {
+		status = resolveErrorStatus(err)
+		var limitErr *LimitError
+		var ambiguous *AmbiguousError
+		switch {
+		case errors.As(err, &limitErr):
+			status = http.StatusTooManyRequests
+		case errors.As(err, &ambiguous):
+			status = http.StatusMultipleChoices
+			if !dryRun {
+				m.picks.Set(hostname, ambiguous.Choices)
+			}
+		}
+	} else if !dryRun {
+		m.picks.Delete(hostname)
+		m.cache.Replace(hostname, exp.Mapping)
+		if err := m.cache.Save(); err != nil {
+			m.logger.Warn("failed to save cache", zap.Error(err))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(exp)
+}
+
 // buildUpstreamURL creates the upstream URL for the reverse proxy
 func (m *LLMResolver) buildUpstreamURL(mapping *RouteMapping) (string, error) {
 	if mapping.Type == "process" {
@@ -1158,12 +2029,15 @@ func (m *LLMResolver) buildUpstreamURL(mapping *RouteMapping) (string, error) {
 	return fmt.Sprintf("%s:%d", ip, mapping.Port), nil
 }
 
-// extractHostname extracts the hostname from the request, removing the port
+// extractHostname extracts the hostname from the request, removing the port.
+// Hostnames are case-insensitive, so it is lowercased here once and every
+// cache and flight key derived from it agrees.
 func extractHostname(r *http.Request) string {
 	host := r.Host
 	if host == "" {
 		host = r.Header.Get("Host")
 	}
+	host = strings.ToLower(host)
 	// Handle IPv6 addresses: [::1]:port or [2001:db8::1]:8080
 	if strings.HasPrefix(host, "[") {
 		// IPv6 address in brackets
@@ -1183,6 +2057,11 @@ func extractHostname(r *http.Request) string {
 	return host
 }
 
+// htmlEscape escapes text for embedding in dashboard HTML
+func htmlEscape(s string) string {
+	return html.EscapeString(s)
+}
+
 // timeNow returns the current time as ISO string
 func timeNow() string {
 	return time.Now().UTC().Format(time.RFC3339)
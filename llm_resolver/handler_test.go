@@ -0,0 +1,122 @@
+package llm_resolver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap"
+)
+
+func TestExtractHostname(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"blog.localhost", "blog.localhost"},
+		{"Blog.Localhost:8080", "blog.localhost"},
+		{"[::1]:443", "::1"},
+		{"[2001:DB8::1]", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "http://example/", nil)
+		r.Host = tt.host
+		if got := extractHostname(r); got != tt.want {
+			t.Errorf("extractHostname(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestCanResend(t *testing.T) {
+	tests := []struct {
+		method string
+		body   string
+		want   bool
+	}{
+		{http.MethodGet, "", true},
+		{http.MethodHead, "", true},
+		{http.MethodPut, "", true},
+		{http.MethodDelete, "", true},
+		{http.MethodPut, `{"a":1}`, false},
+		{http.MethodPost, "", false},
+		{http.MethodPatch, "", false},
+	}
+	for _, tt := range tests {
+		var body io.Reader
+		if tt.body != "" {
+			body = strings.NewReader(tt.body)
+		}
+		if got := canResend(httptest.NewRequest(tt.method, "http://blog.localhost/", body)); got != tt.want {
+			t.Errorf("canResend(%s with %q) = %v, want %v", tt.method, tt.body, got, tt.want)
+		}
+	}
+
+	// A body of unknown length cannot be sent again either
+	r := httptest.NewRequest(http.MethodGet, "http://blog.localhost/", nil)
+	r.ContentLength = -1
+	if canResend(r) {
+		t.Error("canResend with unknown content length = true")
+	}
+}
+
+func TestProxyKeepsOtherErrors(t *testing.T) {
+	m := &LLMResolver{logger: zap.NewNop()}
+	upstreamErr := caddyhttp.Error(http.StatusBadGateway, errors.New("connection reset"))
+	calls := 0
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		calls++
+		if got := caddyhttp.GetVar(r.Context(), "upstream"); got != "127.0.0.1:3000" {
+			t.Errorf("upstream = %v", got)
+		}
+		return upstreamErr
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "http://blog.localhost/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), caddyhttp.VarsCtxKey, map[string]interface{}{}))
+	mapping := &RouteMapping{Type: "process", Target: "localhost", Port: 3000}
+	if err := m.proxy(httptest.NewRecorder(), r, next, "blog.localhost", mapping, "127.0.0.1:3000"); !errors.Is(err, upstreamErr) {
+		t.Errorf("proxy = %v, want the upstream error", err)
+	}
+	if calls != 1 {
+		t.Errorf("next called %d times, want 1", calls)
+	}
+}
+
+func TestMappingsAPIPatch(t *testing.T) {
+	m := &LLMResolver{
+		cache:  NewCache(filepath.Join(t.TempDir(), "mappings.json"), zap.NewNop()),
+		logger: zap.NewNop(),
+	}
+	m.cache.Set("blog.localhost", &RouteMapping{Type: "process", Target: "localhost", Port: 3000})
+
+	patch := func(hostname, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPatch, "/_api/mappings/"+hostname, strings.NewReader(body))
+		if err := m.handleMappingsAPI(w, r); err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+
+	if w := patch("blog.localhost", `{"wait":true}`); w.Code != http.StatusOK {
+		t.Fatalf("PATCH = %d %s", w.Code, w.Body)
+	}
+	if got := m.cache.Get("blog.localhost"); !got.Wait || got.Port != 3000 {
+		t.Errorf("patched mapping = %+v", got)
+	}
+	if w := patch("blog.localhost", `{"port":4000}`); w.Code != http.StatusBadRequest {
+		t.Errorf("PATCH without a setting = %d, want 400", w.Code)
+	}
+	if w := patch("shop.localhost", `{"wait":true}`); w.Code != http.StatusNotFound {
+		t.Errorf("PATCH of an unknown hostname = %d, want 404", w.Code)
+	}
+	if w := patch("blog.localhost", `{"launch":{"command":"npm run dev","workdir":"/srv/blog"}}`); w.Code != http.StatusForbidden {
+		t.Errorf("PATCH of a launch command off the dashboard = %d, want 403", w.Code)
+	}
+}
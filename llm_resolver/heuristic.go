@@ -0,0 +1,237 @@
+package llm_resolver
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Resolution tiers recorded on RouteMapping.Tier
+const (
+	TierHeuristic = "heuristic"
+	TierLLM       = "llm"
+	TierManual    = "manual"
+	TierPicked    = "picked" // chosen by the user on the picker page
+)
+
+// Token weights used by the heuristic matcher
+const (
+	weightExactName    = 10 // container name or workdir basename equals a hostname label
+	weightComposeLabel = 6  // compose project or service label
+	weightParentDir    = 5  // parent directory of the workdir (monorepo layouts)
+	weightCommand      = 3  // process command name
+	weightNamePart     = 2  // dash/underscore separated part of a name, args binary, image name
+
+	// heuristicMargin is the minimum score lead the winner needs over the
+	// runner-up when both cover every hostname label.
+	heuristicMargin = 3
+)
+
+// Ports preferred when a container exposes more than one port
+var preferredHTTPPorts = []int{80, 8080, 3000, 8000, 5173, 4200, 5000}
+
+// heuristicCandidate is a discovered target with its weighted name tokens
+type heuristicCandidate struct {
+	mapping *RouteMapping
+	tokens  map[string]int // normalized token -> weight
+	label   string         // human-readable description for the reason
+}
+
+// ResolveHeuristic tries to match a hostname to a discovered target without
+// calling the LLM. It returns nil when no candidate wins clearly.
+func ResolveHeuristic(hostname string, processes []LocalProcess, containers []DockerContainer) *RouteMapping {
+	labels := hostnameLabels(hostname)
+	if len(labels) == 0 {
+		return nil
+	}
+
+	var candidates []heuristicCandidate
+	for _, proc := range processes {
+		if c, ok := processCandidate(proc); ok {
+			candidates = append(candidates, c)
+		}
+	}
+	for _, container := range containers {
+		if c, ok := containerCandidate(container); ok {
+			candidates = append(candidates, c)
+		}
+	}
+
+	type scored struct {
+		candidate heuristicCandidate
+		score     int
+	}
+
+	// Only candidates matching every hostname label are considered
+	var matches []scored
+	for _, c := range candidates {
+		score, covered := scoreCandidate(labels, c.tokens)
+		if covered {
+			matches = append(matches, scored{candidate: c, score: score})
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	if len(matches) > 1 && matches[0].score-matches[1].score < heuristicMargin {
+		return nil
+	}
+
+	best := matches[0].candidate
+	mapping := *best.mapping
+	mapping.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	mapping.LLMReason = "Matched hostname to " + best.label
+	mapping.Tier = TierHeuristic
+	return &mapping
+}
+
+// hostnameLabels returns normalized hostname labels without the .localhost suffix
+func hostnameLabels(hostname string) []string {
+	hostname = strings.TrimSuffix(strings.ToLower(hostname), ".")
+	hostname = strings.TrimSuffix(hostname, ".localhost")
+	if hostname == "" || hostname == "localhost" {
+		return nil
+	}
+
+	var labels []string
+	for _, label := range strings.Split(hostname, ".") {
+		if label = normalizeToken(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// scoreCandidate sums the best token weight for each label and reports
+// whether every label was matched
+func scoreCandidate(labels []string, tokens map[string]int) (int, bool) {
+	score := 0
+	for _, label := range labels {
+		weight, ok := tokens[label]
+		if !ok {
+			return 0, false
+		}
+		score += weight
+	}
+	return score, true
+}
+
+// processCandidate builds a heuristic candidate from a local process
+func processCandidate(proc LocalProcess) (heuristicCandidate, bool) {
+	if proc.Workdir == "" || proc.Port == 0 {
+		return heuristicCandidate{}, false
+	}
+
+	tokens := make(map[string]int)
+	workdir := strings.TrimSuffix(proc.Workdir, "/")
+	base := filepath.Base(workdir)
+	addToken(tokens, base, weightExactName)
+	addNameParts(tokens, base)
+	addToken(tokens, filepath.Base(filepath.Dir(workdir)), weightParentDir)
+	addToken(tokens, proc.Command, weightCommand)
+	for _, arg := range strings.Fields(proc.Args) {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		addToken(tokens, filepath.Base(arg), weightNamePart)
+	}
+
+	return heuristicCandidate{
+		mapping: processMapping(proc),
+		tokens:  tokens,
+		label:   "process in " + proc.Workdir,
+	}, true
+}
+
+// containerCandidate builds a heuristic candidate from a Docker container
+func containerCandidate(container DockerContainer) (heuristicCandidate, bool) {
+	port := pickContainerPort(container.Ports)
+	if port == 0 {
+		return heuristicCandidate{}, false
+	}
+
+	tokens := make(map[string]int)
+	addToken(tokens, container.Name, weightExactName)
+	addNameParts(tokens, container.Name)
+	addToken(tokens, container.Labels["com.docker.compose.project"], weightComposeLabel)
+	addToken(tokens, container.Labels["com.docker.compose.service"], weightComposeLabel)
+
+	image := container.Image
+	if idx := strings.LastIndex(image, "/"); idx >= 0 {
+		image = image[idx+1:]
+	}
+	if idx := strings.Index(image, ":"); idx >= 0 {
+		image = image[:idx]
+	}
+	addToken(tokens, image, weightNamePart)
+
+	return heuristicCandidate{
+		mapping: &RouteMapping{
+			Type:   "docker",
+			Target: container.Name,
+			Port:   port,
+		},
+		tokens: tokens,
+		label:  "container " + container.Name,
+	}, true
+}
+
+// pickContainerPort returns the only exposed port, or a well-known HTTP port
+// when there are several. Returns 0 when the choice is ambiguous.
+func pickContainerPort(ports []int) int {
+	if len(ports) == 1 {
+		return ports[0]
+	}
+	for _, preferred := range preferredHTTPPorts {
+		for _, p := range ports {
+			if p == preferred {
+				return p
+			}
+		}
+	}
+	return 0
+}
+
+// addToken records a normalized token, keeping the highest weight
+func addToken(tokens map[string]int, value string, weight int) {
+	token := normalizeToken(value)
+	if token == "" {
+		return
+	}
+	if weight > tokens[token] {
+		tokens[token] = weight
+	}
+}
+
+// addNameParts records dash/underscore separated parts of a name
+func addNameParts(tokens map[string]int, name string) {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	})
+	if len(parts) < 2 {
+		return
+	}
+	for _, part := range parts {
+		addToken(tokens, part, weightNamePart)
+	}
+}
+
+// normalizeToken lowercases and strips separators so "my-app", "my_app"
+// and "myapp" compare equal
+func normalizeToken(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if r == '-' || r == '_' || r == '.' || r == ' ' {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package llm_resolver
+
+import "testing"
+
+func TestResolveHeuristic(t *testing.T) {
+	processes := []LocalProcess{
+		{Port: 5173, Command: "node", Args: "vite", Workdir: "/home/dev/projects/shop/frontend"},
+		{Port: 8000, Command: "php", Args: "-S 0.0.0.0:8000", Workdir: "/home/dev/projects/shop/backend"},
+		{Port: 3000, Command: "node", Workdir: "/home/dev/projects/blog"},
+		{Port: 4000, Command: "ruby", Workdir: ""}, // no workdir, never a candidate
+	}
+	containers := []DockerContainer{
+		{Name: "shop-postgres-1", Image: "postgres:16", Ports: []int{5432},
+			Labels: map[string]string{"com.docker.compose.project": "shop", "com.docker.compose.service": "postgres"}},
+		{Name: "mailpit", Image: "axllent/mailpit:latest", Ports: []int{1025, 8025}},
+		{Name: "web", Image: "nginx", Ports: []int{443, 80}},
+	}
+
+	tests := []struct {
+		hostname   string
+		wantType   string // "" when no clear winner is expected
+		wantTarget string
+		wantPort   int
+	}{
+		{"blog.localhost", "process", "localhost", 3000},
+		{"Blog.localhost.", "process", "localhost", 3000},
+		{"frontend.shop.localhost", "process", "localhost", 5173},
+		{"backend.shop.localhost", "process", "localhost", 8000},
+		{"postgres.shop.localhost", "docker", "shop-postgres-1", 5432},
+		{"web.localhost", "docker", "web", 80},
+
+		// Both shop processes match "shop" equally well
+		{"shop.localhost", "", "", 0},
+		// Two exposed ports without a well-known HTTP one
+		{"mailpit.localhost", "", "", 0},
+		// Labels that match nothing
+		{"unknown.localhost", "", "", 0},
+		{"blog.unknown.localhost", "", "", 0},
+		{"localhost", "", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hostname, func(t *testing.T) {
+			mapping := ResolveHeuristic(tt.hostname, processes, containers)
+			if tt.wantType == "" {
+				if mapping != nil {
+					t.Fatalf("got %s %s:%d, want no match", mapping.Type, mapping.Target, mapping.Port)
+				}
+				return
+			}
+			if mapping == nil {
+				t.Fatal("got no match")
+			}
+			if mapping.Type != tt.wantType || mapping.Target != tt.wantTarget || mapping.Port != tt.wantPort {
+				t.Errorf("got %s %s:%d, want %s %s:%d", mapping.Type, mapping.Target, mapping.Port, tt.wantType, tt.wantTarget, tt.wantPort)
+			}
+			if mapping.Tier != TierHeuristic {
+				t.Errorf("tier = %q, want %q", mapping.Tier, TierHeuristic)
+			}
+			if mapping.Type == "process" && (mapping.ProcessIdentifier == nil || mapping.ProcessIdentifier.Workdir == "") {
+				t.Error("process mapping has no workdir identifier")
+			}
+		})
+	}
+}
+
+func TestNormalizeToken(t *testing.T) {
+	for _, s := range []string{"my-app", "my_app", "My.App", "myapp"} {
+		if got := normalizeToken(s); got != "myapp" {
+			t.Errorf("normalizeToken(%q) = %q, want myapp", s, got)
+		}
+	}
+}
+
+func TestPickContainerPort(t *testing.T) {
+	tests := []struct {
+		ports []int
+		want  int
+	}{
+		{[]int{5432}, 5432},
+		{[]int{443, 80}, 80},
+		{[]int{9000, 3000, 8080}, 8080},
+		{[]int{1025, 8025}, 0},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		if got := pickContainerPort(tt.ports); got != tt.want {
+			t.Errorf("pickContainerPort(%v) = %d, want %d", tt.ports, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package llm_resolver
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultNegativeTTL is how long a failed resolution is remembered
+const defaultNegativeTTL = time.Minute
+
+// defaultDenyPatterns match names that clients probe on their own
+// (proxy auto-discovery, mail autodiscovery) rather than services
+var defaultDenyPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(?:wpad|isatap|autodiscover|autoconfig)\.`),
+}
+
+// RejectedError explains why a hostname is not resolved
+type RejectedError struct {
+	Hostname string
+	Reason   string
+	Until    time.Time // when a remembered failure expires; zero for rejected names
+	Err      error     // the remembered failure; nil for rejected names
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("%s is not resolved: %s", e.Hostname, e.Reason)
+}
+
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// HostFilter decides which hostnames are not worth an LLM call: malformed
+// names, names matching a deny pattern and names whose resolution failed
+// recently (the negative cache)
+type HostFilter struct {
+	deny []*regexp.Regexp
+	ttl  time.Duration
+
+	mu       sync.Mutex
+	failures map[string]hostFailure
+}
+
+// hostFailure is a remembered failed resolution
+type hostFailure struct {
+	err   error
+	until time.Time
+}
+
+// NewHostFilter creates a filter with the built-in deny patterns plus
+// user-defined regexes. Failures are remembered for ttl.
+func NewHostFilter(denyPatterns []string, ttl time.Duration) (*HostFilter, error) {
+	deny := append([]*regexp.Regexp{}, defaultDenyPatterns...)
+	for _, expr := range denyPatterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deny_host %q: %w", expr, err)
+		}
+		deny = append(deny, re)
+	}
+	return &HostFilter{
+		deny:     deny,
+		ttl:      ttl,
+		failures: make(map[string]hostFailure),
+	}, nil
+}
+
+// Check returns a *RejectedError if hostname should not be resolved. With
+// force set, a remembered failure does not count.
+func (f *HostFilter) Check(hostname string, force bool) error {
+	if reason := hostnameSyntaxError(hostname); reason != "" {
+		return &RejectedError{Hostname: hostname, Reason: reason}
+	}
+	for _, re := range f.deny {
+		if re.MatchString(hostname) {
+			return &RejectedError{Hostname: hostname, Reason: fmt.Sprintf("matches deny pattern %s", re)}
+		}
+	}
+	if force {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	failure, ok := f.failures[hostname]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(failure.until) {
+		delete(f.failures, hostname)
+		return nil
+	}
+	return &RejectedError{
+		Hostname: hostname,
+		Reason:   fmt.Sprintf("resolution failed: %v", failure.err),
+		Until:    failure.until,
+		Err:      failure.err,
+	}
+}
+
+// Fail remembers a failed resolution of hostname
+func (f *HostFilter) Fail(hostname string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	for host, failure := range f.failures {
+		if now.After(failure.until) {
+			delete(f.failures, host)
+		}
+	}
+	f.failures[hostname] = hostFailure{err: err, until: now.Add(f.ttl)}
+}
+
+// Forget drops a remembered failure, e.g. once the hostname is mapped
+func (f *HostFilter) Forget(hostname string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.failures, hostname)
+}
+
+// hostnameSyntaxError describes why hostname is not a routable name, or
+// returns "" if it is
+func hostnameSyntaxError(hostname string) string {
+	if hostname == "" {
+		return "empty hostname"
+	}
+	if net.ParseIP(hostname) != nil {
+		return "IP addresses are not resolved"
+	}
+	if len(hostname) > 253 {
+		return "hostname is longer than 253 characters"
+	}
+	if hostname == "localhost" {
+		return "bare localhost has no service name"
+	}
+	for _, label := range strings.Split(hostname, ".") {
+		switch {
+		case label == "":
+			return "empty label"
+		case len(label) > 63:
+			return "label is longer than 63 characters"
+		case strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-"):
+			return fmt.Sprintf("label %q starts or ends with a hyphen", label)
+		}
+		for _, c := range strings.ToLower(label) {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return fmt.Sprintf("label %q contains %q", label, c)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package llm_resolver
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHostnameSyntaxError(t *testing.T) {
+	tests := []struct {
+		hostname string
+		want     string // "" when the name is routable
+	}{
+		{"blog.localhost", ""},
+		{"api.my-shop.localhost", ""},
+		{"x1.localhost", ""},
+
+		{"", "empty hostname"},
+		{"127.0.0.1", "IP addresses"},
+		{"::1", "IP addresses"},
+		{"localhost", "bare localhost"},
+		{"blog..localhost", "empty label"},
+		{"blog.localhost.", "empty label"},
+		{strings.Repeat("a", 64) + ".localhost", "longer than 63"},
+		{strings.Repeat("a.", 127) + "localhost", "longer than 253"},
+		{"-blog.localhost", "starts or ends with a hyphen"},
+		{"blog_api.localhost", `contains '_'`},
+	}
+	for _, tt := range tests {
+		got := hostnameSyntaxError(tt.hostname)
+		if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+			t.Errorf("hostnameSyntaxError(%q) = %q, want %q", tt.hostname, got, tt.want)
+		}
+	}
+}
+
+func TestHostFilterDeny(t *testing.T) {
+	f, err := NewHostFilter([]string{`^test\.`}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hostname := range []string{"wpad.localhost", "autodiscover.shop.localhost", "test.localhost"} {
+		var rejected *RejectedError
+		if err := f.Check(hostname, true); !errors.As(err, &rejected) || !strings.Contains(rejected.Reason, "deny pattern") {
+			t.Errorf("Check(%q) = %v, want a deny pattern rejection", hostname, err)
+		}
+	}
+	if err := f.Check("blog.localhost", false); err != nil {
+		t.Errorf("Check(blog.localhost) = %v", err)
+	}
+
+	if _, err := NewHostFilter([]string{"("}, time.Minute); err == nil {
+		t.Error("invalid deny pattern: got no error")
+	}
+}
+
+func TestHostFilterNegativeCache(t *testing.T) {
+	f, err := NewHostFilter(nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Fail("blog.localhost", errors.New("no match"))
+	var rejected *RejectedError
+	if err := f.Check("blog.localhost", false); !errors.As(err, &rejected) {
+		t.Fatalf("Check after failure = %v, want a rejection", err)
+	}
+	if !strings.Contains(rejected.Reason, "no match") || rejected.Until.IsZero() {
+		t.Errorf("rejection = %+v", rejected)
+	}
+	if err := f.Check("blog.localhost", true); err != nil {
+		t.Errorf("forced Check after failure = %v", err)
+	}
+
+	// The remembered failure stays visible through the rejection
+	f.Fail("shop.localhost", &NoMatchError{Reason: "nothing like it"})
+	if err := f.Check("shop.localhost", false); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("Check after no match = %v, want it to match ErrNoMatch", err)
+	}
+
+	f.Forget("blog.localhost")
+	if err := f.Check("blog.localhost", false); err != nil {
+		t.Errorf("Check after Forget = %v", err)
+	}
+
+	expiring, err := NewHostFilter(nil, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiring.Fail("shop.localhost", errors.New("no match"))
+	time.Sleep(5 * time.Millisecond)
+	if err := expiring.Check("shop.localhost", false); err != nil {
+		t.Errorf("Check after the TTL = %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+package llm_resolver
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// journalSize is the number of resolutions kept in the journal
+const journalSize = 100
+
+// Exchange is a single LLM attempt made during a resolution
+type Exchange struct {
+	Time         string    `json:"time"`
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	Attempt      int       `json:"attempt"`
+	SystemPrompt string    `json:"systemPrompt"`
+	UserPrompt   string    `json:"userPrompt"`
+	Messages     []Message `json:"messages,omitempty"` // tool-calling turns after the user prompt
+	Response     string    `json:"response,omitempty"` // raw API response body
+	Error        string    `json:"error,omitempty"`
+	LatencyMs    int64     `json:"latencyMs"`
+	Usage        Usage     `json:"usage"`
+}
+
+// Resolution records how one hostname was resolved
+type Resolution struct {
+	ID          string       `json:"id"`
+	Hostname    string       `json:"hostname"`
+	Service     string       `json:"service,omitempty"` // related service name for /_proxy/ resolutions
+	StartedAt   string       `json:"startedAt"`
+	DurationMs  int64        `json:"durationMs"`
+	Tier        string       `json:"tier,omitempty"`
+	Result      string       `json:"result,omitempty"` // type:target:port of the resolved mapping
+	Error       string       `json:"error,omitempty"`
+	Calls       int          `json:"calls"` // number of LLM attempts
+	Usage       Usage        `json:"usage"`
+	DryRun      bool         `json:"dryRun,omitempty"` // explained only, not cached
+	Validations []Validation `json:"validations,omitempty"`
+	Exchanges   []Exchange   `json:"exchanges,omitempty"`
+
+	started time.Time
+	prompt  *promptContext // candidates shown to the model, for explanations
+}
+
+// Validation is the check of one model answer against the discovery snapshot
+type Validation struct {
+	Answer   string `json:"answer"`
+	Accepted bool   `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// newResolution starts recording a resolution
+func newResolution(hostname, service string) *Resolution {
+	id := make([]byte, 6)
+	rand.Read(id)
+	now := time.Now()
+	return &Resolution{
+		ID:        hex.EncodeToString(id),
+		Hostname:  hostname,
+		Service:   service,
+		StartedAt: now.UTC().Format(time.RFC3339),
+		started:   now,
+	}
+}
+
+// record appends an LLM attempt. Safe to call on a nil resolution.
+func (res *Resolution) record(ex Exchange) {
+	if res == nil {
+		return
+	}
+	res.Calls++
+	res.Usage.InputTokens += ex.Usage.InputTokens
+	res.Usage.OutputTokens += ex.Usage.OutputTokens
+	res.Usage.Cost += ex.Usage.Cost
+	res.Exchanges = append(res.Exchanges, ex)
+}
+
+// check records the validation of an answer. Safe to call on a nil resolution.
+func (res *Resolution) check(answer string, err error) {
+	if res == nil {
+		return
+	}
+	v := Validation{Answer: answer, Accepted: err == nil}
+	if err != nil {
+		v.Error = err.Error()
+	}
+	res.Validations = append(res.Validations, v)
+}
+
+// finish stores the outcome of the resolution
+func (res *Resolution) finish(mapping *RouteMapping, err error) {
+	res.DurationMs = time.Since(res.started).Milliseconds()
+	if err != nil {
+		res.Error = err.Error()
+		return
+	}
+	res.Tier = mapping.Tier
+	res.Result = fmt.Sprintf("%s:%s:%d", mapping.Type, mapping.Target, mapping.Port)
+}
+
+// summary returns a copy without the exchanges
+func (res *Resolution) summary() *Resolution {
+	copy := *res
+	copy.Exchanges = nil
+	return &copy
+}
+
+// Journal is a bounded on-disk log of resolutions, stored as JSON lines
+type Journal struct {
+	mu       sync.RWMutex
+	entries  []*Resolution
+	filePath string
+	logger   *zap.Logger
+}
+
+// NewJournal creates a journal stored at filePath
+func NewJournal(filePath string, logger *zap.Logger) *Journal {
+	return &Journal{
+		filePath: filePath,
+		logger:   logger,
+	}
+}
+
+// Load reads resolutions from the journal file, skipping malformed lines
+func (j *Journal) Load() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	data, err := os.ReadFile(j.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	j.entries = nil
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var res Resolution
+		if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			continue
+		}
+		j.entries = append(j.entries, &res)
+	}
+	if len(j.entries) > journalSize {
+		j.entries = j.entries[len(j.entries)-journalSize:]
+	}
+	return scanner.Err()
+}
+
+// Add appends a resolution. Once the journal is full, the oldest entry is
+// dropped and the file is rewritten.
+func (j *Journal) Add(res *Resolution) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.entries = append(j.entries, res)
+	if len(j.entries) > journalSize {
+		j.entries = j.entries[len(j.entries)-journalSize:]
+		return j.rewrite()
+	}
+
+	line, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.filePath), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// rewrite writes all entries to the journal file atomically
+func (j *Journal) rewrite() error {
+	var buf bytes.Buffer
+	for _, res := range j.entries {
+		line, err := json.Marshal(res)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	tmpFile := j.filePath + ".tmp"
+	if err := os.WriteFile(tmpFile, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, j.filePath)
+}
+
+// List returns resolution summaries, newest first, optionally filtered by hostname
+func (j *Journal) List(hostname string) []*Resolution {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	result := make([]*Resolution, 0, len(j.entries))
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		if hostname != "" && j.entries[i].Hostname != hostname {
+			continue
+		}
+		result = append(result, j.entries[i].summary())
+	}
+	return result
+}
+
+// Get returns a resolution with its exchanges by ID
+func (j *Journal) Get(id string) *Resolution {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	for _, res := range j.entries {
+		if res.ID == id {
+			return res
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package llm_resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolutions.jsonl")
+	journal := NewJournal(path, zap.NewNop())
+
+	res := newResolution("blog.localhost", "")
+	res.record(Exchange{Provider: "openai", Attempt: 1, Usage: Usage{InputTokens: 100, OutputTokens: 10}})
+	res.record(Exchange{Provider: "openai", Attempt: 2, Usage: Usage{InputTokens: 120, OutputTokens: 12}})
+	res.finish(&RouteMapping{Type: "process", Target: "localhost", Port: 3000, Tier: TierLLM}, nil)
+	if err := journal.Add(res); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := newResolution("shop.localhost", "")
+	failed.finish(nil, errors.New("no candidates"))
+	if err := journal.Add(failed); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reloaded journal sees the same entries
+	reloaded := NewJournal(path, zap.NewNop())
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	list := reloaded.List("")
+	if len(list) != 2 || list[0].Hostname != "shop.localhost" {
+		t.Fatalf("List = %+v, want 2 entries, newest first", list)
+	}
+	if list[1].Exchanges != nil {
+		t.Error("List returned exchanges")
+	}
+	if list[0].Error != "no candidates" {
+		t.Errorf("error = %q", list[0].Error)
+	}
+
+	got := reloaded.Get(res.ID)
+	if got == nil || got.Calls != 2 || got.Usage.InputTokens != 220 || len(got.Exchanges) != 2 {
+		t.Fatalf("Get = %+v", got)
+	}
+	if got.Result != "process:localhost:3000" || got.Tier != TierLLM {
+		t.Errorf("result = %q, tier = %q", got.Result, got.Tier)
+	}
+	if len(reloaded.List("blog.localhost")) != 1 {
+		t.Error("List does not filter by hostname")
+	}
+	if reloaded.Get("missing") != nil {
+		t.Error("Get returned an entry for an unknown ID")
+	}
+}
+
+func TestJournalBounded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolutions.jsonl")
+	journal := NewJournal(path, zap.NewNop())
+
+	for i := 0; i < journalSize+5; i++ {
+		res := newResolution(fmt.Sprintf("app%d.localhost", i), "")
+		res.finish(nil, errors.New("failed"))
+		if err := journal.Add(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloaded := NewJournal(path, zap.NewNop())
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	list := reloaded.List("")
+	if len(list) != journalSize || list[len(list)-1].Hostname != "app5.localhost" {
+		t.Errorf("got %d entries starting at %s, want %d starting at app5.localhost", len(list), list[len(list)-1].Hostname, journalSize)
+	}
+
+	// Malformed lines are skipped
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("not json\n")
+	f.Close()
+	if err := reloaded.Load(); err != nil || len(reloaded.List("")) != journalSize {
+		t.Errorf("Load with a malformed line: %v, %d entries", err, len(reloaded.List("")))
+	}
+}
+
+func TestCompleteRecordsExchanges(t *testing.T) {
+	primary := &scriptedProvider{name: "primary", errs: []error{&APIError{StatusCode: 401}}}
+	fallback := &scriptedProvider{name: "fallback"}
+	r := NewResolver([]Endpoint{{Provider: primary}, {Provider: fallback}}, ResolverOptions{}, zap.NewNop())
+
+	res := newResolution("blog.localhost", "")
+	if _, err := r.complete(context.Background(), res, &CompletionRequest{SystemPrompt: "system", UserPrompt: "user"}); err != nil {
+		t.Fatal(err)
+	}
+	if res.Calls != 2 || len(res.Exchanges) != 2 {
+		t.Fatalf("recorded %d calls, want 2", res.Calls)
+	}
+	if ex := res.Exchanges[0]; ex.Provider != "primary" || ex.Error == "" || ex.UserPrompt != "user" {
+		t.Errorf("first exchange = %+v", ex)
+	}
+	if ex := res.Exchanges[1]; ex.Provider != "fallback" || ex.Error != "" {
+		t.Errorf("second exchange = %+v", ex)
+	}
+}
@@ -0,0 +1,485 @@
+package llm_resolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultLaunchTimeout is how long a request waits for a launched
+	// process to listen
+	defaultLaunchTimeout = time.Minute
+
+	// launchTailLines is the number of output lines kept per stream
+	launchTailLines = 50
+
+	// launchBackoff is how long a process that exited is not started again,
+	// so a crashing command is not restarted by every request
+	launchBackoff = 5 * time.Second
+
+	// launchStopGrace is how long a process has to exit before it is killed
+	launchStopGrace = 5 * time.Second
+
+	// maxOutputLine is the length at which an unterminated line is cut
+	maxOutputLine = 4096
+)
+
+// dashboardHost serves the dashboard, the only origin launch commands are
+// accepted from
+const dashboardHost = "proxy.localhost"
+
+// ansiEscape matches terminal color and cursor sequences in process output
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// LaunchConfig starts the target of a process mapping on demand
+type LaunchConfig struct {
+	Command string `json:"command"`           // run with sh -c
+	Workdir string `json:"workdir,omitempty"` // defaults to the mapping's process workdir
+}
+
+// launchWorkdir returns where the launch command of a mapping runs
+func launchWorkdir(mapping *RouteMapping) string {
+	if mapping.Launch.Workdir != "" {
+		return mapping.Launch.Workdir
+	}
+	if mapping.ProcessIdentifier != nil {
+		return mapping.ProcessIdentifier.Workdir
+	}
+	return ""
+}
+
+// LaunchedProcess is a launch command started for a hostname and supervised
+// until it exits
+type LaunchedProcess struct {
+	hostname  string
+	command   string
+	workdir   string
+	port      int
+	starts    int
+	startedAt time.Time
+	cmd       *exec.Cmd
+	stdout    *outputTail
+	stderr    *outputTail
+
+	// done is closed once the process has exited; exitedAt and exitErr are
+	// set before
+	done     chan struct{}
+	exitedAt time.Time
+	exitErr  error
+}
+
+// exited reports whether the process has exited
+func (p *LaunchedProcess) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// exitError describes how the process exited, with its last line of output
+func (p *LaunchedProcess) exitError() error {
+	status := "exited"
+	if p.exitErr != nil {
+		status = p.exitErr.Error()
+	}
+	last := p.stderr.last()
+	if last == "" {
+		last = p.stdout.last()
+	}
+	if last != "" {
+		return fmt.Errorf("%q %s: %s", p.command, status, last)
+	}
+	return fmt.Errorf("%q %s", p.command, status)
+}
+
+// stop terminates the process group and kills it if it does not exit in time
+func (p *LaunchedProcess) stop() {
+	if p.exited() {
+		return
+	}
+	terminateProcess(p.cmd)
+	select {
+	case <-p.done:
+	case <-time.After(launchStopGrace):
+		killProcess(p.cmd)
+		<-p.done
+	}
+}
+
+// LaunchedStatus is a snapshot of a launched process for the dashboard and API
+type LaunchedStatus struct {
+	Hostname  string   `json:"hostname"`
+	Command   string   `json:"command"`
+	Workdir   string   `json:"workdir"`
+	Port      int      `json:"port"`
+	PID       int      `json:"pid"`
+	Starts    int      `json:"starts"`
+	StartedAt string   `json:"startedAt"`
+	ExitedAt  string   `json:"exitedAt,omitempty"`
+	Exit      string   `json:"exit,omitempty"`
+	Stdout    []string `json:"stdout"`
+	Stderr    []string `json:"stderr"`
+}
+
+// status takes a snapshot of the process
+func (p *LaunchedProcess) status() LaunchedStatus {
+	s := LaunchedStatus{
+		Hostname:  p.hostname,
+		Command:   p.command,
+		Workdir:   p.workdir,
+		Port:      p.port,
+		PID:       p.cmd.Process.Pid,
+		Starts:    p.starts,
+		StartedAt: p.startedAt.UTC().Format(time.RFC3339),
+		Stdout:    p.stdout.lines(),
+		Stderr:    p.stderr.lines(),
+	}
+	if p.exited() {
+		s.ExitedAt = p.exitedAt.UTC().Format(time.RFC3339)
+		s.Exit = "exited"
+		if p.exitErr != nil {
+			s.Exit = p.exitErr.Error()
+		}
+	}
+	return s
+}
+
+// Launcher starts the launch commands of mappings and supervises them, one
+// process per hostname. Output goes to per-process tails and the log buffer.
+type Launcher struct {
+	mu        sync.Mutex
+	processes map[string]*LaunchedProcess
+	logger    *zap.Logger
+	logBuffer *LogBuffer
+}
+
+// NewLauncher creates a launcher without processes
+func NewLauncher(logger *zap.Logger, logBuffer *LogBuffer) *Launcher {
+	return &Launcher{
+		processes: make(map[string]*LaunchedProcess),
+		logger:    logger,
+		logBuffer: logBuffer,
+	}
+}
+
+// Start runs the launch command of a mapping with PORT set to the mapping's
+// port, unless a process started for the hostname is still running. A
+// process that exited within launchBackoff is not started again; its exit
+// is returned instead.
+func (l *Launcher) Start(hostname string, mapping *RouteMapping) (*LaunchedProcess, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	starts := 0
+	if p := l.processes[hostname]; p != nil {
+		if !p.exited() {
+			return p, nil
+		}
+		if time.Since(p.exitedAt) < launchBackoff {
+			return nil, p.exitError()
+		}
+		starts = p.starts
+	}
+
+	workdir := launchWorkdir(mapping)
+	cmd := exec.Command("sh", "-c", mapping.Launch.Command)
+	cmd.Dir = workdir
+	cmd.Env = append(os.Environ(), fmt.Sprintf("PORT=%d", mapping.Port))
+	setProcessGroup(cmd)
+
+	p := &LaunchedProcess{
+		hostname:  hostname,
+		command:   mapping.Launch.Command,
+		workdir:   workdir,
+		port:      mapping.Port,
+		starts:    starts + 1,
+		startedAt: time.Now(),
+		cmd:       cmd,
+		stdout:    &outputTail{hostname: hostname, stream: "stdout", logBuffer: l.logBuffer},
+		stderr:    &outputTail{hostname: hostname, stream: "stderr", logBuffer: l.logBuffer},
+		done:      make(chan struct{}),
+	}
+	cmd.Stdout = p.stdout
+	cmd.Stderr = p.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %q in %s: %w", p.command, workdir, err)
+	}
+	l.processes[hostname] = p
+
+	l.logger.Info("launched process",
+		zap.String("hostname", hostname),
+		zap.String("command", p.command),
+		zap.String("workdir", workdir),
+		zap.Int("port", p.port),
+		zap.Int("pid", cmd.Process.Pid),
+	)
+
+	go func() {
+		err := cmd.Wait()
+		p.exitedAt = time.Now()
+		p.exitErr = err
+		close(p.done)
+		l.logger.Info("launched process exited",
+			zap.String("hostname", hostname),
+			zap.Int("pid", cmd.Process.Pid),
+			zap.Error(err),
+		)
+	}()
+	return p, nil
+}
+
+// Stop stops the process launched for a hostname, if any, and forgets it
+func (l *Launcher) Stop(hostname string) bool {
+	l.mu.Lock()
+	p := l.processes[hostname]
+	delete(l.processes, hostname)
+	l.mu.Unlock()
+
+	if p == nil {
+		return false
+	}
+	p.stop()
+	return true
+}
+
+// StopAll stops every launched process
+func (l *Launcher) StopAll() {
+	l.mu.Lock()
+	processes := l.processes
+	l.processes = make(map[string]*LaunchedProcess)
+	l.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, p := range processes {
+		wg.Add(1)
+		go func(p *LaunchedProcess) {
+			defer wg.Done()
+			p.stop()
+		}(p)
+	}
+	wg.Wait()
+}
+
+// List returns snapshots of the launched processes ordered by hostname
+func (l *Launcher) List() []LaunchedStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := make([]LaunchedStatus, 0, len(l.processes))
+	for _, p := range l.processes {
+		result = append(result, p.status())
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Hostname < result[j].Hostname })
+	return result
+}
+
+// outputTail keeps the last lines a launched process wrote to one stream
+// and copies every line into the log buffer
+type outputTail struct {
+	hostname  string
+	stream    string
+	logBuffer *LogBuffer
+
+	mu      sync.Mutex
+	tail    []string
+	partial []byte
+}
+
+// Write splits output into lines; an unterminated line is kept until the
+// rest arrives
+func (t *outputTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.partial = append(t.partial, p...)
+	for {
+		i := bytes.IndexByte(t.partial, '\n')
+		if i < 0 {
+			break
+		}
+		t.add(string(t.partial[:i]))
+		t.partial = t.partial[i+1:]
+	}
+	if len(t.partial) > maxOutputLine {
+		t.add(string(t.partial))
+		t.partial = nil
+	}
+	return len(p), nil
+}
+
+// add stores a line without terminal escapes; the caller holds the lock
+func (t *outputTail) add(line string) {
+	line = strings.TrimRight(ansiEscape.ReplaceAllString(line, ""), "\r")
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if len(t.tail) >= launchTailLines {
+		t.tail = t.tail[1:]
+	}
+	t.tail = append(t.tail, line)
+
+	if t.logBuffer != nil {
+		t.logBuffer.Add(LogEntry{
+			Time:    time.Now(),
+			Level:   "info",
+			Message: line,
+			Fields:  map[string]interface{}{"hostname": t.hostname, "stream": t.stream},
+		})
+	}
+}
+
+// lines returns a copy of the tail
+func (t *outputTail) lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string{}, t.tail...)
+}
+
+// last returns the last line, or ""
+func (t *outputTail) last() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.tail) == 0 {
+		return ""
+	}
+	return t.tail[len(t.tail)-1]
+}
+
+// launchUpstream starts the launch command of a mapping whose target is not
+// running and waits until the target accepts connections, for up to
+// launch_timeout. Commands that ignore PORT are found by their workdir.
+func (m *LLMResolver) launchUpstream(ctx context.Context, hostname string, mapping *RouteMapping) (string, error) {
+	p, err := m.launcher.Start(hostname, mapping)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(m.LaunchTimeout))
+	defer cancel()
+
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("%q is not listening after %s", p.command, time.Since(p.startedAt).Round(time.Second))
+		case <-p.done:
+			return "", p.exitError()
+		case <-ticker.C:
+		}
+
+		m.processCache.GetFresh(waitPollInterval)
+		upstream, err := m.liveUpstream(mapping)
+		if err == nil && dialUpstream(ctx, upstream) == nil {
+			return upstream, nil
+		}
+	}
+}
+
+// handleLaunchedAPI lists launched processes with their output tails
+// (GET /_api/launched) or stops one (DELETE /_api/launched/{hostname})
+func (m *LLMResolver) handleLaunchedAPI(w http.ResponseWriter, r *http.Request) error {
+	hostname := strings.TrimPrefix(r.URL.Path, "/_api/launched")
+	hostname = strings.Trim(hostname, "/")
+
+	switch {
+	case r.Method == http.MethodGet && hostname == "":
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(m.launcher.List())
+
+	case r.Method == http.MethodDelete && hostname != "":
+		if reason := launchEditError(r); reason != "" {
+			http.Error(w, reason, http.StatusForbidden)
+			return nil
+		}
+		if !m.launcher.Stop(hostname) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return nil
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Stopped"))
+		return nil
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+}
+
+// launchEditError returns why a request may not set a launch command or stop
+// a launched process, or "" if it may. A launch command is run by a shell, so
+// it is only accepted on the dashboard host, from the dashboard itself or from
+// a client that sends no origin such as curl, never from the scripts of a
+// proxied app. Requiring a JSON content type keeps cross-origin forms from
+// getting past a preflight.
+func launchEditError(r *http.Request) string {
+	if !strings.EqualFold(extractHostname(r), dashboardHost) {
+		return fmt.Sprintf("launch commands can only be set on %s", dashboardHost)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		return "launch commands need an application/json body"
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || !strings.EqualFold(u.Hostname(), dashboardHost) {
+			return fmt.Sprintf("launch commands are not accepted from %s", origin)
+		}
+	}
+	switch site := r.Header.Get("Sec-Fetch-Site"); site {
+	case "", "same-origin", "none":
+	default:
+		return fmt.Sprintf("launch commands are not accepted from %s requests", site)
+	}
+	return ""
+}
+
+// applyLaunch sets the launch command of a process mapping, or removes it
+// when the command is empty. The mapping follows the launched process by
+// its workdir, in case the command ignores PORT.
+func applyLaunch(mapping *RouteMapping, launch *LaunchConfig) error {
+	if launch.Command == "" {
+		mapping.Launch = nil
+		return nil
+	}
+	if mapping.Type != "process" {
+		return fmt.Errorf("launch commands need a process mapping")
+	}
+
+	// The workdir is stored resolved, so the command keeps running where it
+	// was set up even if the mapping later points elsewhere
+	config := *launch
+	if config.Workdir == "" && mapping.ProcessIdentifier != nil {
+		config.Workdir = mapping.ProcessIdentifier.Workdir
+	}
+	if !filepath.IsAbs(config.Workdir) {
+		return fmt.Errorf("launch commands need an absolute workdir")
+	}
+	mapping.Launch = &config
+	if mapping.ProcessIdentifier == nil {
+		mapping.ProcessIdentifier = &ProcessIdentifier{Workdir: config.Workdir}
+	}
+	return nil
+}
+
+// launchApplies reports whether a launch command set on another mapping
+// starts the target of mapping: a process in the launch workdir
+func launchApplies(mapping *RouteMapping, previous *RouteMapping) bool {
+	return previous.Launch != nil && mapping.Type == "process" &&
+		mapping.ProcessIdentifier != nil && mapping.ProcessIdentifier.Workdir == launchWorkdir(previous)
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package llm_resolver
+
+import "os/exec"
+
+// setProcessGroup is a no-op on non-Unix platforms
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcess kills the process; there is no SIGTERM on non-Unix platforms
+func terminateProcess(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+// killProcess kills the process
+func killProcess(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
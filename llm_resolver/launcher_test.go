@@ -0,0 +1,121 @@
+package llm_resolver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestLaunchEditError(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		headers map[string]string
+		allowed bool
+	}{
+		{"dashboard", "proxy.localhost", map[string]string{"Origin": "https://proxy.localhost", "Sec-Fetch-Site": "same-origin"}, true},
+		{"client without an origin", "proxy.localhost", nil, true},
+		{"proxied app host", "blog.localhost", nil, false},
+		{"other origin", "proxy.localhost", map[string]string{"Origin": "https://blog.localhost"}, false},
+		{"cross-site fetch", "proxy.localhost", map[string]string{"Sec-Fetch-Site": "same-site"}, false},
+		{"form body", "proxy.localhost", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "http://"+tt.host+"/_api/mappings/blog.localhost", nil)
+			r.Header.Set("Content-Type", "application/json")
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			if reason := launchEditError(r); (reason == "") != tt.allowed {
+				t.Errorf("launchEditError = %q, want allowed %v", reason, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestApplyLaunch(t *testing.T) {
+	mapping := &RouteMapping{Type: "process", Target: "localhost", Port: 3000, ProcessIdentifier: &ProcessIdentifier{Workdir: "/srv/blog"}}
+	if err := applyLaunch(mapping, &LaunchConfig{Command: "npm run dev"}); err != nil {
+		t.Fatal(err)
+	}
+	if mapping.Launch == nil || mapping.Launch.Workdir != "/srv/blog" {
+		t.Errorf("launch = %+v, want the mapping's workdir", mapping.Launch)
+	}
+	if err := applyLaunch(mapping, &LaunchConfig{}); err != nil || mapping.Launch != nil {
+		t.Errorf("empty command left %+v (%v)", mapping.Launch, err)
+	}
+
+	if err := applyLaunch(&RouteMapping{Type: "docker", Target: "db", Port: 5432}, &LaunchConfig{Command: "npm run dev"}); err == nil {
+		t.Error("launch command accepted on a docker mapping")
+	}
+	if err := applyLaunch(&RouteMapping{Type: "process", Target: "localhost", Port: 3000}, &LaunchConfig{Command: "npm run dev", Workdir: "srv"}); err == nil {
+		t.Error("relative workdir accepted")
+	}
+
+	bare := &RouteMapping{Type: "process", Target: "localhost", Port: 3000}
+	if err := applyLaunch(bare, &LaunchConfig{Command: "npm run dev", Workdir: "/srv/shop"}); err != nil {
+		t.Fatal(err)
+	}
+	if bare.ProcessIdentifier == nil || bare.ProcessIdentifier.Workdir != "/srv/shop" {
+		t.Errorf("identifier = %+v, want the launch workdir", bare.ProcessIdentifier)
+	}
+}
+
+func TestCacheReplaceKeepsLaunch(t *testing.T) {
+	launch := &LaunchConfig{Command: "npm run dev", Workdir: "/srv/blog"}
+	process := func(port int, workdir string) *RouteMapping {
+		return &RouteMapping{Type: "process", Target: "localhost", Port: port, ProcessIdentifier: &ProcessIdentifier{Workdir: workdir}}
+	}
+	cache := NewCache(filepath.Join(t.TempDir(), "mappings.json"), zap.NewNop())
+
+	previous := process(3000, "/srv/blog")
+	previous.Launch = launch
+	cache.Set("blog.localhost", previous)
+	cache.Replace("blog.localhost", process(3001, "/srv/blog"))
+	if got := cache.Get("blog.localhost"); got.Launch != launch {
+		t.Errorf("launch = %+v, want it kept for a process in the same workdir", got.Launch)
+	}
+
+	cache.Replace("blog.localhost", process(3002, "/srv/other"))
+	if got := cache.Get("blog.localhost"); got.Launch != nil {
+		t.Errorf("launch = %+v, want it dropped for a process in another workdir", got.Launch)
+	}
+}
+
+func TestHandleLaunchedAPI(t *testing.T) {
+	m := &LLMResolver{launcher: NewLauncher(zap.NewNop(), nil)}
+	stop := func(host string, headers map[string]string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodDelete, "http://"+host+"/_api/launched/blog.localhost", nil)
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		if err := m.handleLaunchedAPI(w, r); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code
+	}
+
+	if code := stop("blog.localhost", map[string]string{"Content-Type": "application/json"}); code != http.StatusForbidden {
+		t.Errorf("DELETE from a proxied app = %d, want 403", code)
+	}
+	if code := stop("proxy.localhost", map[string]string{"Content-Type": "application/json", "Origin": "https://blog.localhost"}); code != http.StatusForbidden {
+		t.Errorf("DELETE from another origin = %d, want 403", code)
+	}
+	if code := stop("proxy.localhost", map[string]string{"Content-Type": "application/json"}); code != http.StatusNotFound {
+		t.Errorf("DELETE without a launched process = %d, want 404", code)
+	}
+
+	w := httptest.NewRecorder()
+	if err := m.handleLaunchedAPI(w, httptest.NewRequest(http.MethodGet, "http://proxy.localhost/_api/launched", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("GET = %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,389 @@
+package llm_resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultVerifyInterval is how often persisted mappings are checked
+	// against a fresh discovery
+	defaultVerifyInterval = time.Minute
+
+	// defaultWaitTimeout is how long a request to a mapping in wait mode is
+	// held while its target restarts
+	defaultWaitTimeout = 10 * time.Second
+
+	// waitPollInterval is how often discovery is refreshed while waiting
+	waitPollInterval = 500 * time.Millisecond
+
+	// downPollInterval is how often the "is down" page checks whether the
+	// target is back; the page may stay open for a long time
+	downPollInterval = 2 * time.Second
+
+	// dialTimeout bounds the connection attempt that confirms a target
+	// missing from discovery is gone
+	dialTimeout = 500 * time.Millisecond
+)
+
+// Sighting is when, and as what, the target of a mapping was last found
+type Sighting struct {
+	Time        time.Time `json:"time"`
+	Description string    `json:"description,omitempty"`
+}
+
+// Sightings remembers in memory when the targets of mappings were last seen
+// running, for the "is down" page
+type Sightings struct {
+	mu   sync.Mutex
+	seen map[string]Sighting
+}
+
+// NewSightings creates an empty sighting store
+func NewSightings() *Sightings {
+	return &Sightings{seen: make(map[string]Sighting)}
+}
+
+// Seen records that the target of a mapping is running now. An empty
+// description keeps the previous one.
+func (s *Sightings) Seen(key, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if description == "" {
+		description = s.seen[key].Description
+	}
+	s.seen[key] = Sighting{Time: time.Now(), Description: description}
+}
+
+// Get returns the last sighting of the target of a mapping, if any
+func (s *Sightings) Get(key string) (Sighting, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sighting, ok := s.seen[key]
+	return sighting, ok
+}
+
+// verifyStats counts the outcome of a verification pass
+type verifyStats struct {
+	verified, stale, reresolved, failed int
+}
+
+// keepsTarget reports whether a mapping must not be replaced by a
+// re-resolution: the user chose its target, put it in wait mode expecting
+// the target to come back, or gave it a command to start the target
+func keepsTarget(mapping *RouteMapping) bool {
+	return mapping.Tier == TierManual || mapping.Tier == TierPicked || mapping.Wait || mapping.Launch != nil
+}
+
+// staleFor returns how long the target of a mapping has been missing, or 0
+// while it is live
+func staleFor(mapping *RouteMapping) time.Duration {
+	since, err := time.Parse(time.RFC3339, mapping.StaleSince)
+	if err != nil {
+		return 0
+	}
+	return time.Since(since)
+}
+
+// liveUpstream returns the upstream address of a mapping, or an error when
+// its target is no longer running. Process mappings are checked against the
+// process cache; a failed discovery does not make them stale.
+func (m *LLMResolver) liveUpstream(mapping *RouteMapping) (string, error) {
+	if mapping.Type == "process" && m.processCache != nil {
+		if processes, err := m.processCache.Get(); err == nil {
+			if err := verifyMapping(mapping, processes, nil); err != nil {
+				if err := m.confirmGone(mapping, processes, err); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+	return m.buildUpstreamURL(mapping)
+}
+
+// confirmGone double-checks a mapping that verifyMapping did not find in
+// discovery. Discovery leaves out system commands, the proxy's own compose
+// project and more, so absence alone does not prove the target is gone: it
+// is gone only when another process now holds its port, its container cannot
+// be found, or its address refuses connections. It returns nil when the
+// target still answers, and staleErr with the reason otherwise.
+func (m *LLMResolver) confirmGone(mapping *RouteMapping, processes []LocalProcess, staleErr error) error {
+	address := fmt.Sprintf("127.0.0.1:%d", mapping.Port)
+	if mapping.Type == "process" {
+		for _, proc := range processes {
+			if proc.Port == mapping.Port {
+				return staleErr
+			}
+		}
+	} else {
+		var err error
+		if address, err = m.buildUpstreamURL(mapping); err != nil {
+			return fmt.Errorf("%w: %v", staleErr, err)
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("%w: %v", staleErr, err)
+	}
+	conn.Close()
+	return nil
+}
+
+// waitForUpstream polls discovery until the target of a mapping in wait
+// mode is back, for up to wait_timeout. It returns the upstream address, or
+// lastErr when the target does not come back in time.
+func (m *LLMResolver) waitForUpstream(ctx context.Context, hostname string, mapping *RouteMapping, lastErr error) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(m.WaitTimeout))
+	defer cancel()
+
+	m.logger.Info("waiting for upstream to come back",
+		zap.String("hostname", hostname),
+		zap.String("target", mapping.Target),
+		zap.Int("port", mapping.Port),
+		zap.Error(lastErr),
+	)
+
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", lastErr
+		case <-ticker.C:
+		}
+
+		// Waiting requests share one discovery per poll
+		if mapping.Type == "process" {
+			m.processCache.GetFresh(waitPollInterval)
+		}
+		upstream, err := m.liveUpstream(mapping)
+		if err == nil {
+			return upstream, nil
+		}
+		lastErr = err
+	}
+}
+
+// markSeen records that the target of a mapping was found running, taking
+// its description from the process cache
+func (m *LLMResolver) markSeen(key string, mapping *RouteMapping) {
+	description := ""
+	if mapping.Type == "process" {
+		if processes, err := m.processCache.Get(); err == nil {
+			description = describeTarget(mapping, processes, nil)
+		}
+	}
+	m.sightings.Seen(key, description)
+	m.markLive(key, mapping)
+}
+
+// dialUpstream checks that an upstream accepts connections
+func dialUpstream(ctx context.Context, upstream string) error {
+	dialer := net.Dialer{Timeout: time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", upstream)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// markStale records that the target of mapping has vanished, keeping the
+// time it was first missed. It reports whether the mapping was live before.
+func (m *LLMResolver) markStale(hostname string, mapping *RouteMapping, staleErr error) bool {
+	wasLive := mapping.StaleSince == ""
+	updated := m.cache.Update(hostname, mapping, func(mapping *RouteMapping) {
+		if mapping.StaleSince == "" {
+			mapping.StaleSince = timeNow()
+		}
+		mapping.StaleReason = staleErr.Error()
+	})
+	if !updated || !wasLive {
+		return false
+	}
+
+	m.logger.Warn("target of mapping is gone",
+		zap.String("hostname", hostname),
+		zap.String("type", mapping.Type),
+		zap.String("target", mapping.Target),
+		zap.Int("port", mapping.Port),
+		zap.String("stale", staleErr.Error()),
+	)
+	if err := m.cache.Save(); err != nil {
+		m.logger.Warn("failed to save cache", zap.Error(err))
+	}
+	return true
+}
+
+// markLive clears the stale state of a mapping whose target is back
+func (m *LLMResolver) markLive(hostname string, mapping *RouteMapping) {
+	if mapping.StaleSince == "" {
+		return
+	}
+	updated := m.cache.Update(hostname, mapping, func(mapping *RouteMapping) {
+		mapping.StaleSince = ""
+		mapping.StaleReason = ""
+	})
+	if !updated {
+		return
+	}
+
+	m.logger.Info("target of mapping is back",
+		zap.String("hostname", hostname),
+		zap.String("target", mapping.Target),
+		zap.Int("port", mapping.Port),
+	)
+	if err := m.cache.Save(); err != nil {
+		m.logger.Warn("failed to save cache", zap.Error(err))
+	}
+}
+
+// reresolveStale replaces a mapping whose target has vanished. It returns the
+// new mapping, or an error if the hostname could not be resolved again; the
+// stale mapping is kept in that case.
+func (m *LLMResolver) reresolveStale(ctx context.Context, key string) (*RouteMapping, error) {
+	// Related service mappings are keyed "origin:service"
+	fn := m.resolveHostname(key, true, "")
+	if origin, service, ok := strings.Cut(key, ":"); ok {
+		fn = m.resolveRelated(origin, service, true, "")
+	}
+	result, err, _ := m.resolveGroup.Do(ctx, flightKey(key, true, ""), time.Duration(m.ResolveTimeout), fn)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*RouteMapping), nil
+}
+
+// discoverySnapshot is one process and container discovery. A failed half
+// says nothing about the targets of its type.
+type discoverySnapshot struct {
+	processes    []LocalProcess
+	processErr   error
+	containers   []DockerContainer
+	containerErr error
+}
+
+// verifyMappings checks every persisted mapping against a fresh discovery.
+// See verifySnapshot.
+func (m *LLMResolver) verifyMappings(ctx context.Context, retry bool) (verifyStats, error) {
+	if len(m.cache.GetAll()) == 0 {
+		return verifyStats{}, nil
+	}
+
+	var snapshot discoverySnapshot
+	discoveryCtx, cancel := context.WithTimeout(ctx, time.Duration(m.DiscoveryTimeout))
+	snapshot.processes, snapshot.processErr = DiscoverLocalProcesses(discoveryCtx)
+	if snapshot.processErr != nil {
+		m.logger.Warn("verify: failed to discover processes", zap.Error(snapshot.processErr))
+	}
+	snapshot.containers, snapshot.containerErr = DiscoverDockerContainers(discoveryCtx, m.ComposeProject)
+	if snapshot.containerErr != nil {
+		m.logger.Warn("verify: failed to discover containers", zap.Error(snapshot.containerErr))
+	}
+	cancel()
+
+	return m.verifySnapshot(ctx, retry, snapshot)
+}
+
+// verifySnapshot checks every persisted mapping against a discovery snapshot.
+// Mappings whose target vanished (see confirmGone) are marked stale and,
+// unless they keep their target, re-resolved once; with retry set, mappings
+// that were already stale are re-resolved again. Mappings whose target is back are marked live,
+// and mappings of a type whose discovery failed are skipped rather than
+// marked stale. It stops early at the daily limit or when ctx is done.
+func (m *LLMResolver) verifySnapshot(ctx context.Context, retry bool, snapshot discoverySnapshot) (verifyStats, error) {
+	var stats verifyStats
+	mappings := m.cache.GetAll()
+	processes, containers := snapshot.processes, snapshot.containers
+
+	keys := make([]string, 0, len(mappings))
+	for key := range mappings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		mapping := m.cache.Get(key)
+		if mapping == nil {
+			continue
+		}
+		if (mapping.Type == "process" && snapshot.processErr != nil) || (mapping.Type != "process" && snapshot.containerErr != nil) {
+			continue
+		}
+
+		staleErr := verifyMapping(mapping, processes, containers)
+		if staleErr != nil {
+			staleErr = m.confirmGone(mapping, processes, staleErr)
+		}
+		if staleErr == nil {
+			m.sightings.Seen(key, describeTarget(mapping, processes, containers))
+			m.markLive(key, mapping)
+			stats.verified++
+			continue
+		}
+		if mapping.Launch != nil {
+			// Not running is expected; the next request starts it
+			continue
+		}
+
+		wasLive := m.markStale(key, mapping, staleErr)
+		if keepsTarget(mapping) || !(wasLive || retry) {
+			stats.stale++
+			continue
+		}
+
+		m.logger.Info("verify: re-resolving stale mapping",
+			zap.String("key", key),
+			zap.String("target", mapping.Target),
+			zap.Int("port", mapping.Port),
+		)
+		if _, err := m.reresolveStale(ctx, key); err != nil {
+			var limitErr *LimitError
+			if ctx.Err() != nil || errors.As(err, &limitErr) {
+				return stats, err
+			}
+			m.logger.Warn("verify: re-resolution failed, keeping stale mapping", zap.String("key", key), zap.Error(err))
+			stats.failed++
+			continue
+		}
+		stats.reresolved++
+	}
+	return stats, nil
+}
+
+// verifyLoop runs a verification pass every interval until ctx is done.
+// Already stale mappings are not re-resolved again, so a stopped dev server
+// costs one LLM call rather than one per pass.
+func (m *LLMResolver) verifyLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stats, err := m.verifyMappings(ctx, false)
+		if err != nil && ctx.Err() == nil {
+			m.logger.Warn("verify: pass stopped", zap.Error(err))
+		}
+		if stats.reresolved > 0 || stats.failed > 0 {
+			m.logger.Info("verify: pass finished",
+				zap.Int("verified", stats.verified),
+				zap.Int("stale", stats.stale),
+				zap.Int("reresolved", stats.reresolved),
+				zap.Int("failed", stats.failed),
+			)
+		}
+	}
+}
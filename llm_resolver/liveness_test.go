@@ -0,0 +1,389 @@
+package llm_resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap"
+)
+
+// failingProvider counts completions and fails every one of them, so a
+// re-resolution shows up as a failed attempt
+type failingProvider struct {
+	calls atomic.Int32
+}
+
+func (p *failingProvider) Name() string  { return "test" }
+func (p *failingProvider) Model() string { return "test" }
+
+func (p *failingProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	p.calls.Add(1)
+	return nil, errors.New("provider unavailable")
+}
+
+// listeningPort returns a local port that accepts connections until the test ends
+func listeningPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// closedPort returns a local port that refuses connections
+func closedPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+// newVerifyTestResolver returns a resolver with hostname mapped to mapping.
+// Re-resolutions see one unrelated process and ask provider.
+func newVerifyTestResolver(t *testing.T, hostname string, mapping *RouteMapping, provider Provider) *LLMResolver {
+	t.Helper()
+	logger := zap.NewNop()
+	hostFilter, err := NewHostFilter(nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &LLMResolver{
+		ResolveTimeout: caddy.Duration(5 * time.Second),
+		logger:         logger,
+		cache:          NewCache(filepath.Join(t.TempDir(), "mappings.json"), logger),
+		resolveGroup:   newFlightGroup(),
+		hostFilter:     hostFilter,
+		picks:          NewPicks(),
+		sightings:      NewSightings(),
+	}
+	m.resolver = NewResolver([]Endpoint{{Provider: provider}}, ResolverOptions{
+		DisableHeuristics: true,
+		Discover: func(ctx context.Context) ([]LocalProcess, []DockerContainer) {
+			return []LocalProcess{{Port: 4000, Command: "node", Workdir: "/srv/other"}}, nil
+		},
+	}, logger)
+	m.cache.Set(hostname, mapping)
+	return m
+}
+
+func TestVerifySnapshot(t *testing.T) {
+	const staleSince = "2024-01-01T00:00:00Z"
+	port, livePort := closedPort(t), listeningPort(t)
+	running := []LocalProcess{{Port: port, Command: "node", Workdir: "/srv/app"}}
+	containers := []DockerContainer{{Name: "db", Ports: []int{5432}}}
+	discoveryErr := errors.New("discovery failed")
+
+	process := func(tier, staleSince string) *RouteMapping {
+		return &RouteMapping{
+			Type:              "process",
+			Target:            "localhost",
+			Port:              port,
+			Tier:              tier,
+			StaleSince:        staleSince,
+			ProcessIdentifier: &ProcessIdentifier{Workdir: "/srv/app"},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		mapping   *RouteMapping
+		snapshot  discoverySnapshot
+		retry     bool
+		want      verifyStats
+		wantStale bool // whether the mapping is stale afterwards
+		wantCalls bool // whether the model was asked to re-resolve
+	}{
+		{
+			name:     "live process",
+			mapping:  process(TierLLM, ""),
+			snapshot: discoverySnapshot{processes: running},
+			want:     verifyStats{verified: 1},
+		},
+		{
+			name:     "stale process is back",
+			mapping:  process(TierLLM, staleSince),
+			snapshot: discoverySnapshot{processes: running},
+			want:     verifyStats{verified: 1},
+		},
+		{
+			name: "process missing from discovery with a live port",
+			mapping: &RouteMapping{
+				Type:              "process",
+				Target:            "localhost",
+				Port:              livePort,
+				Tier:              TierLLM,
+				ProcessIdentifier: &ProcessIdentifier{Workdir: "/srv/app"},
+			},
+			snapshot: discoverySnapshot{},
+			want:     verifyStats{verified: 1},
+		},
+		{
+			name: "live port taken over by another process",
+			mapping: &RouteMapping{
+				Type:              "process",
+				Target:            "localhost",
+				Port:              livePort,
+				Tier:              TierManual,
+				ProcessIdentifier: &ProcessIdentifier{Workdir: "/srv/app"},
+			},
+			snapshot:  discoverySnapshot{processes: []LocalProcess{{Port: livePort, Command: "node", Workdir: "/srv/other"}}},
+			want:      verifyStats{stale: 1},
+			wantStale: true,
+		},
+		{
+			name:      "vanished process is re-resolved once",
+			mapping:   process(TierLLM, ""),
+			snapshot:  discoverySnapshot{},
+			want:      verifyStats{failed: 1},
+			wantStale: true,
+			wantCalls: true,
+		},
+		{
+			name:      "already stale process is not re-resolved again",
+			mapping:   process(TierLLM, staleSince),
+			snapshot:  discoverySnapshot{},
+			want:      verifyStats{stale: 1},
+			wantStale: true,
+		},
+		{
+			name:      "already stale process is re-resolved with retry",
+			mapping:   process(TierLLM, staleSince),
+			snapshot:  discoverySnapshot{},
+			retry:     true,
+			want:      verifyStats{failed: 1},
+			wantStale: true,
+			wantCalls: true,
+		},
+		{
+			name:      "manual mapping keeps its target",
+			mapping:   process(TierManual, ""),
+			snapshot:  discoverySnapshot{},
+			retry:     true,
+			want:      verifyStats{stale: 1},
+			wantStale: true,
+		},
+		{
+			name:      "picked mapping keeps its target",
+			mapping:   process(TierPicked, ""),
+			snapshot:  discoverySnapshot{},
+			want:      verifyStats{stale: 1},
+			wantStale: true,
+		},
+		{
+			name: "mapping in wait mode keeps its target",
+			mapping: func() *RouteMapping {
+				mapping := process(TierLLM, "")
+				mapping.Wait = true
+				return mapping
+			}(),
+			snapshot:  discoverySnapshot{},
+			want:      verifyStats{stale: 1},
+			wantStale: true,
+		},
+		{
+			name: "mapping with a launch command is not marked stale",
+			mapping: func() *RouteMapping {
+				mapping := process(TierLLM, "")
+				mapping.Launch = &LaunchConfig{Command: "npm run dev", Workdir: "/srv/app"}
+				return mapping
+			}(),
+			snapshot: discoverySnapshot{},
+			want:     verifyStats{},
+		},
+		{
+			name:     "failed process discovery skips process mappings",
+			mapping:  process(TierLLM, ""),
+			snapshot: discoverySnapshot{processErr: discoveryErr},
+			want:     verifyStats{},
+		},
+		{
+			name:     "failed container discovery does not skip process mappings",
+			mapping:  process(TierLLM, ""),
+			snapshot: discoverySnapshot{processes: running, containerErr: discoveryErr},
+			want:     verifyStats{verified: 1},
+		},
+		{
+			name:     "live container",
+			mapping:  &RouteMapping{Type: "docker", Target: "db", Port: 5432, Tier: TierLLM},
+			snapshot: discoverySnapshot{containers: containers},
+			want:     verifyStats{verified: 1},
+		},
+		{
+			name:     "failed container discovery skips container mappings",
+			mapping:  &RouteMapping{Type: "docker", Target: "db", Port: 5432, Tier: TierLLM},
+			snapshot: discoverySnapshot{containerErr: discoveryErr},
+			want:     verifyStats{},
+		},
+		{
+			name:      "vanished container is re-resolved once",
+			mapping:   &RouteMapping{Type: "docker", Target: "tudy-test-vanished", Port: 5432, Tier: TierLLM},
+			snapshot:  discoverySnapshot{processes: running},
+			want:      verifyStats{failed: 1},
+			wantStale: true,
+			wantCalls: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &failingProvider{}
+			m := newVerifyTestResolver(t, "app.localhost", tt.mapping, provider)
+
+			stats, err := m.verifySnapshot(context.Background(), tt.retry, tt.snapshot)
+			if err != nil {
+				t.Fatalf("verifySnapshot: %v", err)
+			}
+			if stats != tt.want {
+				t.Errorf("stats = %+v, want %+v", stats, tt.want)
+			}
+
+			mapping := m.cache.Get("app.localhost")
+			if mapping == nil {
+				t.Fatal("mapping was removed")
+			}
+			if stale := mapping.StaleSince != ""; stale != tt.wantStale {
+				t.Errorf("stale = %v (since %q), want %v", stale, mapping.StaleSince, tt.wantStale)
+			}
+			if called := provider.calls.Load() > 0; called != tt.wantCalls {
+				t.Errorf("re-resolved = %v, want %v", called, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSightings(t *testing.T) {
+	s := NewSightings()
+	if _, ok := s.Get("blog.localhost"); ok {
+		t.Fatal("sighting before the target was seen")
+	}
+	s.Seen("blog.localhost", "node in /srv/blog")
+	first, _ := s.Get("blog.localhost")
+	s.Seen("blog.localhost", "")
+	second, ok := s.Get("blog.localhost")
+	if !ok || second.Description != "node in /srv/blog" || second.Time.Before(first.Time) {
+		t.Errorf("sighting = %+v, want the time refreshed and the description kept", second)
+	}
+}
+
+func TestVerifySnapshotKeepsFirstStaleTime(t *testing.T) {
+	const staleSince = "2024-01-01T00:00:00Z"
+	mapping := &RouteMapping{Type: "process", Target: "localhost", Port: closedPort(t), Tier: TierManual, StaleSince: staleSince}
+	m := newVerifyTestResolver(t, "app.localhost", mapping, &failingProvider{})
+
+	if _, err := m.verifySnapshot(context.Background(), false, discoverySnapshot{}); err != nil {
+		t.Fatal(err)
+	}
+	got := m.cache.Get("app.localhost")
+	if got.StaleSince != staleSince {
+		t.Errorf("StaleSince = %q, want %q", got.StaleSince, staleSince)
+	}
+	if got.StaleReason == "" {
+		t.Error("StaleReason is empty")
+	}
+	if mapping.StaleReason != "" {
+		t.Error("the cached mapping was modified in place")
+	}
+}
+
+func TestMarkStale(t *testing.T) {
+	mapping := &RouteMapping{Type: "process", Target: "localhost", Port: 3000}
+	m := newVerifyTestResolver(t, "app.localhost", mapping, &failingProvider{})
+	staleErr := errors.New("gone")
+
+	if !m.markStale("app.localhost", mapping, staleErr) {
+		t.Error("first markStale reported the mapping as already stale")
+	}
+	stale := m.cache.Get("app.localhost")
+	if m.markStale("app.localhost", stale, staleErr) {
+		t.Error("second markStale reported the mapping as live")
+	}
+
+	// A mapping replaced meanwhile is left alone
+	if m.markStale("app.localhost", mapping, staleErr) {
+		t.Error("markStale updated a replaced mapping")
+	}
+
+	m.markLive("app.localhost", m.cache.Get("app.localhost"))
+	if live := m.cache.Get("app.localhost"); live.StaleSince != "" || live.StaleReason != "" {
+		t.Errorf("markLive left %q, %q", live.StaleSince, live.StaleReason)
+	}
+}
+
+func TestLiveUpstream(t *testing.T) {
+	livePort, deadPort := listeningPort(t), closedPort(t)
+	other := LocalProcess{Port: 4000, Command: "node", Workdir: "/srv/other"}
+	process := func(port int) *RouteMapping {
+		return &RouteMapping{Type: "process", Target: "localhost", Port: port, ProcessIdentifier: &ProcessIdentifier{Workdir: "/srv/app"}}
+	}
+
+	tests := []struct {
+		name      string
+		mapping   *RouteMapping
+		processes []LocalProcess
+		wantErr   bool
+	}{
+		{"discovered", process(deadPort), []LocalProcess{{Port: deadPort, Workdir: "/srv/app"}}, false},
+		{"live port absent from discovery", process(livePort), []LocalProcess{other}, false},
+		{"dead port absent from discovery", process(deadPort), []LocalProcess{other}, true},
+		{"port held by another process", process(livePort), []LocalProcess{{Port: livePort, Workdir: "/srv/other"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newVerifyTestResolver(t, "app.localhost", tt.mapping, &failingProvider{})
+			m.processCache = &ProcessCache{processes: tt.processes, lastUpdate: time.Now(), ttl: time.Hour}
+
+			upstream, err := m.liveUpstream(tt.mapping)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("liveUpstream = %q, %v, want error %v", upstream, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWaitForUpstream(t *testing.T) {
+	port := closedPort(t)
+	mapping := &RouteMapping{Type: "process", Target: "localhost", Port: port, Wait: true}
+	m := newVerifyTestResolver(t, "app.localhost", mapping, &failingProvider{})
+	m.processCache = &ProcessCache{processes: []LocalProcess{}, lastUpdate: time.Now(), ttl: time.Hour}
+	lastErr := errors.New("gone")
+
+	m.WaitTimeout = caddy.Duration(3 * waitPollInterval)
+	start := time.Now()
+	if _, err := m.waitForUpstream(context.Background(), "app.localhost", mapping, lastErr); err == nil {
+		t.Fatal("waited for a target that never came back: got no error")
+	}
+	if elapsed := time.Since(start); elapsed < time.Duration(m.WaitTimeout) {
+		t.Errorf("gave up after %v, want %v", elapsed, time.Duration(m.WaitTimeout))
+	}
+
+	// The dev server comes back on its port while the request waits
+	m.WaitTimeout = caddy.Duration(10 * time.Second)
+	go func() {
+		time.Sleep(waitPollInterval)
+		ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			return
+		}
+		t.Cleanup(func() { ln.Close() })
+	}()
+	upstream, err := m.waitForUpstream(context.Background(), "app.localhost", mapping, lastErr)
+	if err != nil {
+		t.Fatalf("waitForUpstream: %v", err)
+	}
+	if want := fmt.Sprintf("127.0.0.1:%d", port); upstream != want {
+		t.Errorf("upstream = %q, want %q", upstream, want)
+	}
+}
@@ -1,10 +1,15 @@
 package llm_resolver
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"text/template"
+	"time"
 
 	"github.com/caddyserver/caddy/v2"
 	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
@@ -12,7 +17,6 @@ import (
 	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"golang.org/x/sync/singleflight"
 )
 
 func init() {
@@ -21,30 +25,146 @@ func init() {
 	httpcaddyfile.RegisterDirectiveOrder("llm_resolver", httpcaddyfile.Before, "reverse_proxy")
 }
 
+// Default deadlines for resolutions and the work they do
+const (
+	defaultResolveTimeout   = 2 * time.Minute
+	defaultDiscoveryTimeout = 15 * time.Second
+	defaultLLMTimeout       = 30 * time.Second
+)
+
 // LLMResolver is a Caddy HTTP handler module that resolves hostnames
-// to upstream targets using an LLM (via OpenRouter API).
+// to upstream targets using an LLM (OpenRouter by default).
 type LLMResolver struct {
+	// Provider selects the LLM API adapter: "openai" (default, any OpenAI-compatible API),
+	// "anthropic" (native Messages API) or "ollama" (native /api/chat)
+	Provider string `json:"provider,omitempty"`
+
 	// APIKey is the API key for the LLM API
 	APIKey string `json:"api_key,omitempty"`
 
-	// APIURL is the URL for the LLM API (default: https://openrouter.ai/api/v1/chat/completions)
+	// APIURL is the URL for the LLM API (default depends on provider,
+	// for openai: https://openrouter.ai/api/v1/chat/completions)
 	APIURL string `json:"api_url,omitempty"`
 
-	// Model is the LLM model to use (default: anthropic/claude-haiku-4.5)
+	// Model is the LLM model to use (default depends on provider,
+	// for openai: anthropic/claude-haiku-4.5)
 	Model string `json:"model,omitempty"`
 
+	// Retries is the number of retries for the primary endpoint (default: 2)
+	Retries *int `json:"retries,omitempty"`
+
+	// StructuredOutput enables schema-constrained answers for the primary endpoint (default: true)
+	StructuredOutput *bool `json:"structured_output,omitempty"`
+
+	// Redact scrubs secrets from prompts for the primary endpoint (default: true)
+	Redact *bool `json:"redact,omitempty"`
+
+	// Privacy pseudonymizes home directory paths (and the usernames in them) for
+	// the primary endpoint (default: false)
+	Privacy *bool `json:"privacy,omitempty"`
+
+	// RedactPatterns are extra regexes whose matches are scrubbed from prompts
+	RedactPatterns []string `json:"redact_patterns,omitempty"`
+
+	// Fallbacks are additional endpoints tried in order when the primary fails
+	Fallbacks []EndpointConfig `json:"fallbacks,omitempty"`
+
 	// CacheFile is the path to store hostname mappings (default: /data/mappings.json)
 	CacheFile string `json:"cache_file,omitempty"`
 
 	// ComposeProject is the name of our own compose project to filter out
 	ComposeProject string `json:"compose_project,omitempty"`
 
+	// DisableHeuristics skips the local name matcher and always asks the LLM
+	DisableHeuristics bool `json:"disable_heuristics,omitempty"`
+
+	// ContextBudget is the approximate number of tokens the discovery and
+	// mappings sections of a prompt may use; the least relevant entries are
+	// left out beyond it (default: 0, unlimited)
+	ContextBudget int `json:"context_budget,omitempty"`
+
+	// SystemPromptFile is a text/template file replacing the built-in
+	// hostname resolution prompt (see PromptData for the template fields)
+	SystemPromptFile string `json:"system_prompt_file,omitempty"`
+
+	// RelatedPromptFile is a text/template file replacing the built-in
+	// related service (/_proxy/) prompt
+	RelatedPromptFile string `json:"related_prompt_file,omitempty"`
+
+	// MaxDailyRequests refuses new LLM resolutions once this many LLM
+	// requests were made today (default: 0, unlimited)
+	MaxDailyRequests int `json:"max_daily_requests,omitempty"`
+
+	// MaxDailyCost refuses new LLM resolutions once today's reported cost
+	// reaches this amount in USD (default: 0, unlimited). Only providers that
+	// report cost count toward it.
+	MaxDailyCost float64 `json:"max_daily_cost,omitempty"`
+
+	// ResolveTimeout bounds a whole resolution, including discovery and every
+	// LLM attempt. It runs detached from the request that started it and is
+	// only cancelled early once every waiting client has gone away (default: 2m)
+	ResolveTimeout caddy.Duration `json:"resolve_timeout,omitempty"`
+
+	// DiscoveryTimeout bounds process and container discovery (default: 15s)
+	DiscoveryTimeout caddy.Duration `json:"discovery_timeout,omitempty"`
+
+	// LLMTimeout bounds a single LLM HTTP request (default: 30s)
+	LLMTimeout caddy.Duration `json:"llm_timeout,omitempty"`
+
+	// MinConfidence is the confidence below which an LLM answer is treated as
+	// no match and not cached (default: 0.5, 0 = accept any answer)
+	MinConfidence *float64 `json:"min_confidence,omitempty"`
+
+	// PickMargin shows a picker page instead of routing when the model rates
+	// an alternative within this margin of its choice (default: 0.15,
+	// negative = never ask)
+	PickMargin *float64 `json:"pick_margin,omitempty"`
+
+	// DenyHosts are extra regexes for hostnames that are never resolved
+	DenyHosts []string `json:"deny_hosts,omitempty"`
+
+	// NegativeTTL is how long a failed resolution is remembered before the
+	// hostname is resolved again (default: 1m)
+	NegativeTTL caddy.Duration `json:"negative_ttl,omitempty"`
+
+	// Warmup re-verifies every persisted mapping at startup and re-resolves
+	// the ones whose target is gone (default: false)
+	Warmup bool `json:"warmup,omitempty"`
+
+	// VerifyInterval is how often mappings are checked against discovery;
+	// vanished targets are marked stale and re-resolved (default: 1m,
+	// negative = off)
+	VerifyInterval caddy.Duration `json:"verify_interval,omitempty"`
+
+	// WaitTimeout is how long requests to a mapping in wait mode are held
+	// while its target restarts (default: 10s)
+	WaitTimeout caddy.Duration `json:"wait_timeout,omitempty"`
+
+	// LaunchTimeout is how long a request waits for the launch command of a
+	// mapping to start listening (default: 1m)
+	LaunchTimeout caddy.Duration `json:"launch_timeout,omitempty"`
+
+	// Agent lets the LLM call read-only inspection tools before answering (default: disabled)
+	Agent *AgentConfig `json:"agent,omitempty"`
+
+	// stateKey identifies the shared state in statePool
+	stateKey string
+
 	// logger is the Caddy logger
 	logger *zap.Logger
 
 	// cache is the mapping cache
 	cache *Cache
 
+	// journal records resolutions and their LLM exchanges next to the cache file
+	journal *Journal
+
+	// corrections stores manual fixes of resolved mappings next to the cache file
+	corrections *Corrections
+
+	// usage keeps per-day and per-hostname LLM usage totals
+	usage *UsageTracker
+
 	// processCache is short-lived cache for process discovery
 	processCache *ProcessCache
 
@@ -52,10 +172,19 @@ type LLMResolver struct {
 	resolver *Resolver
 
 	// resolveGroup deduplicates concurrent LLM requests for the same hostname
-	resolveGroup singleflight.Group
+	resolveGroup *flightGroup
+
+	// hostFilter rejects junk hostnames and remembers failed resolutions
+	hostFilter *HostFilter
+
+	// picks holds ambiguous resolutions waiting for the user to choose
+	picks *Picks
 
-	// networkTunnel manages WireGuard tunnel to Docker VM on macOS
-	networkTunnel *NetworkTunnel
+	// sightings remembers when mapping targets were last seen running
+	sightings *Sightings
+
+	// launcher starts and supervises the launch commands of mappings
+	launcher *Launcher
 
 	// logBuffer captures recent log entries for the debug dashboard
 	logBuffer *LogBuffer
@@ -71,46 +200,222 @@ func (LLMResolver) CaddyModule() caddy.ModuleInfo {
 
 // Provision sets up the module.
 func (m *LLMResolver) Provision(ctx caddy.Context) error {
-	m.logBuffer = NewLogBuffer(200)
-	m.logger = ctx.Logger().WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
-		return zapcore.NewTee(c, NewBufferCore(m.logBuffer))
-	}))
-
 	// Set defaults
+	if m.Provider == "" {
+		m.Provider = ProviderOpenAI
+	}
 	if m.Model == "" {
-		m.Model = "anthropic/claude-haiku-4.5"
+		m.Model = defaultModelFor(m.Provider)
 	}
 	if m.CacheFile == "" {
 		m.CacheFile = "/data/mappings.json"
 	}
+	if m.ResolveTimeout == 0 {
+		m.ResolveTimeout = caddy.Duration(defaultResolveTimeout)
+	}
+	if m.DiscoveryTimeout == 0 {
+		m.DiscoveryTimeout = caddy.Duration(defaultDiscoveryTimeout)
+	}
+	if m.LLMTimeout == 0 {
+		m.LLMTimeout = caddy.Duration(defaultLLMTimeout)
+	}
+	if m.NegativeTTL == 0 {
+		m.NegativeTTL = caddy.Duration(defaultNegativeTTL)
+	}
+	if m.LaunchTimeout == 0 {
+		m.LaunchTimeout = caddy.Duration(defaultLaunchTimeout)
+	}
+	if m.WaitTimeout == 0 {
+		m.WaitTimeout = caddy.Duration(defaultWaitTimeout)
+	}
+	if m.VerifyInterval == 0 {
+		m.VerifyInterval = caddy.Duration(defaultVerifyInterval)
+	}
+	if m.MinConfidence == nil {
+		minConfidence := defaultMinConfidence
+		m.MinConfidence = &minConfidence
+	}
+	if m.PickMargin == nil {
+		pickMargin := defaultPickMargin
+		m.PickMargin = &pickMargin
+	}
+	if m.Agent != nil {
+		if m.Agent.MaxSteps == 0 {
+			m.Agent.MaxSteps = defaultAgentMaxSteps
+		}
+		if m.Agent.MaxTokens == 0 {
+			m.Agent.MaxTokens = defaultAgentMaxTokens
+		}
+	}
 
-	// Initialize cache
-	m.cache = NewCache(m.CacheFile, m.logger)
-	if err := m.cache.Load(); err != nil {
-		m.logger.Warn("failed to load cache, starting fresh", zap.Error(err))
+	// Instances with the same configuration (e.g. the on-demand TLS ask
+	// handler and the HTTPS handler) share one state
+	key, err := stateKey(m)
+	if err != nil {
+		return err
+	}
+	val, loaded, err := statePool.LoadOrNew(key, func() (caddy.Destructor, error) {
+		return m.newState(ctx)
+	})
+	if err != nil {
+		return err
 	}
+	state := val.(*sharedState)
+	m.stateKey = key
+	m.useState(state)
 
-	// Initialize process cache for dynamic port resolution
-	m.processCache = NewProcessCache()
+	if !loaded && m.Warmup {
+		go m.warmup(ctx)
+	}
 
-	// Initialize resolver
-	m.resolver = NewResolver(m.APIKey, m.APIURL, m.Model, m.ComposeProject, m.logger)
+	// The verifier belongs to the shared state, which outlives this config
+	// when a reload keeps the block unchanged
+	if !loaded && m.VerifyInterval > 0 {
+		verifyCtx, cancel := context.WithCancel(context.Background())
+		state.stopVerify = cancel
+		go m.verifyLoop(verifyCtx, time.Duration(m.VerifyInterval))
+	}
 
-	// Initialize network tunnel for Docker VM access on macOS
-	m.networkTunnel = NewNetworkTunnel(m.logger)
-	if err := m.networkTunnel.Start(); err != nil {
-		m.logger.Warn("failed to start network tunnel", zap.Error(err))
-		// Non-fatal: proxy will still work with published ports
+	// Calls to endpoints that report no cost never count toward the limit
+	if !loaded && m.MaxDailyCost > 0 {
+		for i, cfg := range append([]EndpointConfig{{Provider: m.Provider}}, m.Fallbacks...) {
+			if !reportsCost(cfg.Provider) {
+				m.logger.Warn("max_daily_cost does not limit an endpoint whose provider reports no cost; use max_daily_requests",
+					zap.Int("endpoint", i),
+					zap.String("provider", cfg.Provider),
+				)
+			}
+		}
 	}
 
 	m.logger.Info("LLM resolver provisioned",
+		zap.String("provider", m.Provider),
 		zap.String("model", m.Model),
+		zap.Int("fallbacks", len(m.Fallbacks)),
+		zap.Bool("agent", m.Agent != nil),
 		zap.String("cache_file", m.CacheFile),
+		zap.Bool("shared", loaded),
 	)
 
 	return nil
 }
 
+// newState initializes the cache, journal, usage totals, resolver and
+// network tunnel for the configuration
+func (m *LLMResolver) newState(ctx caddy.Context) (*sharedState, error) {
+	s := &sharedState{logBuffer: NewLogBuffer(200)}
+	s.logger = ctx.Logger().WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, NewBufferCore(s.logBuffer))
+	}))
+
+	// Initialize cache
+	s.cache = NewCache(m.CacheFile, s.logger)
+	if err := s.cache.Load(); err != nil {
+		s.logger.Warn("failed to load cache, starting fresh", zap.Error(err))
+	}
+
+	// Initialize resolution journal
+	s.journal = NewJournal(filepath.Join(filepath.Dir(m.CacheFile), "resolutions.jsonl"), s.logger)
+	if err := s.journal.Load(); err != nil {
+		s.logger.Warn("failed to load resolution journal", zap.Error(err))
+	}
+
+	// Initialize manual corrections used as prompt examples
+	s.corrections = NewCorrections(filepath.Join(filepath.Dir(m.CacheFile), "corrections.json"), s.logger)
+	if err := s.corrections.Load(); err != nil {
+		s.logger.Warn("failed to load corrections", zap.Error(err))
+	}
+
+	// Initialize usage totals
+	s.usage = NewUsageTracker(filepath.Join(filepath.Dir(m.CacheFile), "usage.json"), m.MaxDailyRequests, m.MaxDailyCost, s.logger)
+	if err := s.usage.Load(); err != nil {
+		s.logger.Warn("failed to load usage totals", zap.Error(err))
+	}
+
+	// Initialize process cache for dynamic port resolution
+	s.processCache = NewProcessCache()
+
+	s.resolveGroup = newFlightGroup()
+	s.picks = NewPicks()
+	s.sightings = NewSightings()
+	s.launcher = NewLauncher(s.logger, s.logBuffer)
+
+	hostFilter, err := NewHostFilter(m.DenyHosts, time.Duration(m.NegativeTTL))
+	if err != nil {
+		return nil, err
+	}
+	s.hostFilter = hostFilter
+
+	// Load prompt templates; a broken template fails the config (re)load
+	var systemTemplate, relatedTemplate *template.Template
+	if m.SystemPromptFile != "" {
+		tmpl, err := LoadPromptTemplate(m.SystemPromptFile)
+		if err != nil {
+			return nil, fmt.Errorf("system_prompt_file: %w", err)
+		}
+		systemTemplate = tmpl
+	}
+	if m.RelatedPromptFile != "" {
+		tmpl, err := LoadPromptTemplate(m.RelatedPromptFile)
+		if err != nil {
+			return nil, fmt.Errorf("related_prompt_file: %w", err)
+		}
+		relatedTemplate = tmpl
+	}
+
+	redactor, err := NewRedactor(m.RedactPatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize LLM endpoint chain and resolver
+	httpClient := &http.Client{
+		Timeout: time.Duration(m.LLMTimeout),
+	}
+	configs := append([]EndpointConfig{{
+		Provider:         m.Provider,
+		APIURL:           m.APIURL,
+		APIKey:           m.APIKey,
+		Model:            m.Model,
+		Retries:          m.Retries,
+		StructuredOutput: m.StructuredOutput,
+		Redact:           m.Redact,
+		Privacy:          m.Privacy,
+	}}, m.Fallbacks...)
+	endpoints := make([]Endpoint, 0, len(configs))
+	for i, cfg := range configs {
+		endpoint, err := NewEndpoint(cfg, httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("endpoint %d: %w", i, err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	s.resolver = NewResolver(endpoints, ResolverOptions{
+		ComposeProject:        m.ComposeProject,
+		DisableHeuristics:     m.DisableHeuristics,
+		MinConfidence:         *m.MinConfidence,
+		PickMargin:            *m.PickMargin,
+		Agent:                 m.Agent,
+		Journal:               s.journal,
+		Corrections:           s.corrections,
+		Usage:                 s.usage,
+		Redactor:              redactor,
+		DiscoveryTimeout:      time.Duration(m.DiscoveryTimeout),
+		ContextBudget:         m.ContextBudget,
+		SystemPromptTemplate:  systemTemplate,
+		RelatedPromptTemplate: relatedTemplate,
+	}, s.logger)
+
+	// Initialize network tunnel for Docker VM access on macOS
+	s.networkTunnel = NewNetworkTunnel(s.logger)
+	if err := s.networkTunnel.Start(); err != nil {
+		s.logger.Warn("failed to start network tunnel", zap.Error(err))
+		// Non-fatal: proxy will still work with published ports
+	}
+
+	return s, nil
+}
+
 // Validate validates the module configuration.
 func (m *LLMResolver) Validate() error {
 	// Validate API URL if set
@@ -138,8 +443,8 @@ func (m *LLMResolver) Validate() error {
 
 // Cleanup is called when the module is being unloaded.
 func (m *LLMResolver) Cleanup() error {
-	if m.networkTunnel != nil {
-		m.networkTunnel.Stop()
+	if m.stateKey != "" {
+		statePool.Delete(m.stateKey)
 	}
 	return nil
 }
@@ -149,21 +454,67 @@ func (m *LLMResolver) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 	for d.Next() {
 		for d.NextBlock(0) {
 			switch d.Val() {
+			case "provider":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				m.Provider = d.Val()
 			case "api_key":
 				if !d.NextArg() {
 					return d.ArgErr()
 				}
 				m.APIKey = d.Val()
 			case "api_url":
-				if !d.NextArg() {
-					return d.ArgErr()
+				// Optional so an empty env placeholder falls back to the provider default
+				if d.NextArg() {
+					m.APIURL = d.Val()
 				}
-				m.APIURL = d.Val()
 			case "model":
-				if !d.NextArg() {
+				if d.NextArg() {
+					m.Model = d.Val()
+				}
+			case "retries":
+				retries, err := parseRetries(d)
+				if err != nil {
+					return err
+				}
+				m.Retries = &retries
+			case "structured_output":
+				enabled, err := parseOnOff(d)
+				if err != nil {
+					return err
+				}
+				m.StructuredOutput = &enabled
+			case "redact":
+				enabled, err := parseOnOff(d)
+				if err != nil {
+					return err
+				}
+				m.Redact = &enabled
+			case "privacy":
+				enabled, err := parseOnOff(d)
+				if err != nil {
+					return err
+				}
+				m.Privacy = &enabled
+			case "redact_pattern":
+				args := d.RemainingArgs()
+				if len(args) == 0 {
 					return d.ArgErr()
 				}
-				m.Model = d.Val()
+				m.RedactPatterns = append(m.RedactPatterns, args...)
+			case "deny_host":
+				args := d.RemainingArgs()
+				if len(args) == 0 {
+					return d.ArgErr()
+				}
+				m.DenyHosts = append(m.DenyHosts, args...)
+			case "fallback":
+				cfg, err := parseEndpointBlock(d)
+				if err != nil {
+					return err
+				}
+				m.Fallbacks = append(m.Fallbacks, cfg)
 			case "cache_file":
 				if !d.NextArg() {
 					return d.ArgErr()
@@ -173,6 +524,111 @@ func (m *LLMResolver) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 				if d.NextArg() {
 					m.ComposeProject = d.Val()
 				}
+			case "disable_heuristics":
+				m.DisableHeuristics = true
+			case "warmup":
+				m.Warmup = true
+			case "context_budget":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				n, err := strconv.Atoi(d.Val())
+				if err != nil || n < 0 {
+					return d.Errf("invalid context_budget '%s'", d.Val())
+				}
+				m.ContextBudget = n
+			case "system_prompt_file":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				m.SystemPromptFile = d.Val()
+			case "related_prompt_file":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				m.RelatedPromptFile = d.Val()
+			case "max_daily_requests":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				n, err := strconv.Atoi(d.Val())
+				if err != nil || n < 0 {
+					return d.Errf("invalid max_daily_requests '%s'", d.Val())
+				}
+				m.MaxDailyRequests = n
+			case "max_daily_cost":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				cost, err := strconv.ParseFloat(d.Val(), 64)
+				if err != nil || cost < 0 {
+					return d.Errf("invalid max_daily_cost '%s'", d.Val())
+				}
+				m.MaxDailyCost = cost
+			case "min_confidence":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				minConfidence, err := strconv.ParseFloat(d.Val(), 64)
+				if err != nil || minConfidence < 0 || minConfidence > 1 {
+					return d.Errf("invalid min_confidence '%s'", d.Val())
+				}
+				m.MinConfidence = &minConfidence
+			case "pick_margin":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				pickMargin := -1.0
+				if d.Val() != "off" {
+					var err error
+					pickMargin, err = strconv.ParseFloat(d.Val(), 64)
+					if err != nil || pickMargin < 0 || pickMargin > 1 {
+						return d.Errf("invalid pick_margin '%s'", d.Val())
+					}
+				}
+				m.PickMargin = &pickMargin
+			case "verify_interval":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				if d.Val() == "off" {
+					m.VerifyInterval = -1
+					break
+				}
+				dur, err := caddy.ParseDuration(d.Val())
+				if err != nil || dur <= 0 {
+					return d.Errf("invalid verify_interval '%s'", d.Val())
+				}
+				m.VerifyInterval = caddy.Duration(dur)
+			case "resolve_timeout", "discovery_timeout", "llm_timeout", "negative_ttl", "wait_timeout", "launch_timeout":
+				name := d.Val()
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := caddy.ParseDuration(d.Val())
+				if err != nil || dur <= 0 {
+					return d.Errf("invalid %s '%s'", name, d.Val())
+				}
+				switch name {
+				case "resolve_timeout":
+					m.ResolveTimeout = caddy.Duration(dur)
+				case "discovery_timeout":
+					m.DiscoveryTimeout = caddy.Duration(dur)
+				case "negative_ttl":
+					m.NegativeTTL = caddy.Duration(dur)
+				case "wait_timeout":
+					m.WaitTimeout = caddy.Duration(dur)
+				case "launch_timeout":
+					m.LaunchTimeout = caddy.Duration(dur)
+				default:
+					m.LLMTimeout = caddy.Duration(dur)
+				}
+			case "agent":
+				cfg, err := parseAgentBlock(d)
+				if err != nil {
+					return err
+				}
+				m.Agent = cfg
 			default:
 				return d.Errf("unknown subdirective '%s'", d.Val())
 			}
@@ -181,6 +637,126 @@ func (m *LLMResolver) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 	return nil
 }
 
+// parseEndpointBlock parses a fallback endpoint block:
+//
+//	fallback {
+//	    provider ollama
+//	    model llama3.2
+//	    api_url http://localhost:11434/api/chat
+//	    api_key ...
+//	    retries 1
+//	    structured_output off
+//	    redact on
+//	    privacy on
+//	}
+func parseEndpointBlock(d *caddyfile.Dispenser) (EndpointConfig, error) {
+	var cfg EndpointConfig
+	for nesting := d.Nesting(); d.NextBlock(nesting); {
+		switch d.Val() {
+		case "provider":
+			if !d.NextArg() {
+				return cfg, d.ArgErr()
+			}
+			cfg.Provider = d.Val()
+		case "api_key":
+			if d.NextArg() {
+				cfg.APIKey = d.Val()
+			}
+		case "api_url":
+			if d.NextArg() {
+				cfg.APIURL = d.Val()
+			}
+		case "model":
+			if d.NextArg() {
+				cfg.Model = d.Val()
+			}
+		case "retries":
+			retries, err := parseRetries(d)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Retries = &retries
+		case "structured_output":
+			enabled, err := parseOnOff(d)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.StructuredOutput = &enabled
+		case "redact":
+			enabled, err := parseOnOff(d)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Redact = &enabled
+		case "privacy":
+			enabled, err := parseOnOff(d)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Privacy = &enabled
+		default:
+			return cfg, d.Errf("unknown fallback subdirective '%s'", d.Val())
+		}
+	}
+	return cfg, nil
+}
+
+// parseAgentBlock parses the agent directive with an optional block:
+//
+//	agent {
+//	    max_steps 6
+//	    max_tokens 20000
+//	}
+func parseAgentBlock(d *caddyfile.Dispenser) (*AgentConfig, error) {
+	cfg := &AgentConfig{}
+	for nesting := d.Nesting(); d.NextBlock(nesting); {
+		name := d.Val()
+		if !d.NextArg() {
+			return nil, d.ArgErr()
+		}
+		n, err := strconv.Atoi(d.Val())
+		if err != nil || n < 1 {
+			return nil, d.Errf("invalid %s '%s'", name, d.Val())
+		}
+		switch name {
+		case "max_steps":
+			cfg.MaxSteps = n
+		case "max_tokens":
+			cfg.MaxTokens = n
+		default:
+			return nil, d.Errf("unknown agent subdirective '%s'", name)
+		}
+	}
+	return cfg, nil
+}
+
+// parseRetries parses a non-negative retry count argument
+func parseRetries(d *caddyfile.Dispenser) (int, error) {
+	if !d.NextArg() {
+		return 0, d.ArgErr()
+	}
+	retries, err := strconv.Atoi(d.Val())
+	if err != nil || retries < 0 {
+		return 0, d.Errf("invalid retries '%s'", d.Val())
+	}
+	return retries, nil
+}
+
+// parseOnOff parses an "on"/"off" argument
+func parseOnOff(d *caddyfile.Dispenser) (bool, error) {
+	if !d.NextArg() {
+		return false, d.ArgErr()
+	}
+	switch d.Val() {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	default:
+		return false, d.Errf("expected 'on' or 'off', got '%s'", d.Val())
+	}
+}
+
 // parseCaddyfile sets up the handler from Caddyfile tokens.
 func parseCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
 	var m LLMResolver
@@ -0,0 +1,291 @@
+package llm_resolver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+)
+
+// parseTestCaddyfile unmarshals an llm_resolver block
+func parseTestCaddyfile(input string) (*LLMResolver, error) {
+	m := new(LLMResolver)
+	err := m.UnmarshalCaddyfile(caddyfile.NewTestDispenser(input))
+	return m, err
+}
+
+func TestUnmarshalCaddyfile(t *testing.T) {
+	m, err := parseTestCaddyfile(`llm_resolver {
+		provider anthropic
+		api_key secret
+		api_url
+		model claude-haiku-4-5
+		cache_file /tmp/mappings.json
+		compose_project tudy
+		disable_heuristics
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Provider != ProviderAnthropic || m.APIKey != "secret" || m.APIURL != "" || m.Model != "claude-haiku-4-5" {
+		t.Errorf("endpoint = %q %q %q %q", m.Provider, m.APIKey, m.APIURL, m.Model)
+	}
+	if m.CacheFile != "/tmp/mappings.json" || m.ComposeProject != "tudy" || !m.DisableHeuristics {
+		t.Errorf("options = %q %q %v", m.CacheFile, m.ComposeProject, m.DisableHeuristics)
+	}
+}
+
+func TestUnmarshalCaddyfileErrors(t *testing.T) {
+	for _, input := range []string{
+		"llm_resolver {\n provider\n }",
+		"llm_resolver {\n api_key\n }",
+		"llm_resolver {\n unknown_option 1\n }",
+	} {
+		if _, err := parseTestCaddyfile(input); err == nil {
+			t.Errorf("%q: got no error", input)
+		}
+	}
+}
+
+func TestUnmarshalCaddyfileFallbacks(t *testing.T) {
+	m, err := parseTestCaddyfile(`llm_resolver {
+		provider anthropic
+		retries 1
+		fallback {
+			provider ollama
+			model llama3.2
+			retries 0
+		}
+		fallback {
+			provider openai
+			api_key secret
+		}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Retries == nil || *m.Retries != 1 {
+		t.Errorf("retries = %v, want 1", m.Retries)
+	}
+	if len(m.Fallbacks) != 2 {
+		t.Fatalf("got %d fallbacks, want 2", len(m.Fallbacks))
+	}
+	if fb := m.Fallbacks[0]; fb.Provider != ProviderOllama || fb.Model != "llama3.2" || fb.Retries == nil || *fb.Retries != 0 {
+		t.Errorf("fallback 0 = %+v", fb)
+	}
+	if fb := m.Fallbacks[1]; fb.Provider != ProviderOpenAI || fb.APIKey != "secret" || fb.Retries != nil {
+		t.Errorf("fallback 1 = %+v", fb)
+	}
+
+	for _, input := range []string{
+		"llm_resolver {\n retries -1\n }",
+		"llm_resolver {\n retries many\n }",
+		"llm_resolver {\n fallback {\n color red\n }\n }",
+	} {
+		if _, err := parseTestCaddyfile(input); err == nil {
+			t.Errorf("%q: got no error", input)
+		}
+	}
+}
+
+func TestUnmarshalCaddyfileStructuredOutput(t *testing.T) {
+	m, err := parseTestCaddyfile(`llm_resolver {
+		structured_output off
+		fallback {
+			provider ollama
+			structured_output on
+		}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.StructuredOutput == nil || *m.StructuredOutput {
+		t.Errorf("structured_output = %v, want off", m.StructuredOutput)
+	}
+	if so := m.Fallbacks[0].StructuredOutput; so == nil || !*so {
+		t.Errorf("fallback structured_output = %v, want on", so)
+	}
+
+	if _, err := parseTestCaddyfile("llm_resolver {\n structured_output maybe\n }"); err == nil {
+		t.Error("structured_output maybe: got no error")
+	}
+}
+
+func TestUnmarshalCaddyfileAgent(t *testing.T) {
+	m, err := parseTestCaddyfile(`llm_resolver {
+		agent {
+			max_steps 4
+		}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Agent == nil || m.Agent.MaxSteps != 4 || m.Agent.MaxTokens != 0 {
+		t.Errorf("agent = %+v", m.Agent)
+	}
+
+	for _, input := range []string{
+		"llm_resolver {\n agent {\n max_steps 0\n }\n }",
+		"llm_resolver {\n agent {\n max_depth 2\n }\n }",
+	} {
+		if _, err := parseTestCaddyfile(input); err == nil {
+			t.Errorf("%q: got no error", input)
+		}
+	}
+}
+
+func TestUnmarshalCaddyfileLimits(t *testing.T) {
+	m, err := parseTestCaddyfile(`llm_resolver {
+		max_daily_requests 200
+		max_daily_cost 1.50
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MaxDailyRequests != 200 || m.MaxDailyCost != 1.5 {
+		t.Errorf("limits = %d, %v", m.MaxDailyRequests, m.MaxDailyCost)
+	}
+
+	for _, input := range []string{
+		"llm_resolver {\n max_daily_requests -1\n }",
+		"llm_resolver {\n max_daily_cost free\n }",
+	} {
+		if _, err := parseTestCaddyfile(input); err == nil {
+			t.Errorf("%q: got no error", input)
+		}
+	}
+}
+
+func TestUnmarshalCaddyfilePromptFiles(t *testing.T) {
+	m, err := parseTestCaddyfile(`llm_resolver {
+		system_prompt_file /etc/tudy/system.tmpl
+		related_prompt_file /etc/tudy/related.tmpl
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.SystemPromptFile != "/etc/tudy/system.tmpl" || m.RelatedPromptFile != "/etc/tudy/related.tmpl" {
+		t.Errorf("prompt files = %q, %q", m.SystemPromptFile, m.RelatedPromptFile)
+	}
+	if _, err := parseTestCaddyfile("llm_resolver {\n system_prompt_file\n }"); err == nil {
+		t.Error("system_prompt_file without a path: got no error")
+	}
+}
+
+func TestUnmarshalCaddyfileContextBudget(t *testing.T) {
+	m, err := parseTestCaddyfile("llm_resolver {\n context_budget 4000\n }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ContextBudget != 4000 {
+		t.Errorf("context_budget = %d, want 4000", m.ContextBudget)
+	}
+	if _, err := parseTestCaddyfile("llm_resolver {\n context_budget -5\n }"); err == nil {
+		t.Error("negative context_budget: got no error")
+	}
+}
+
+func TestUnmarshalCaddyfileRedaction(t *testing.T) {
+	m, err := parseTestCaddyfile(`llm_resolver {
+		privacy on
+		redact_pattern "ACME-[0-9A-F]{32}"
+		redact_pattern "corp-[a-z]+" "int-[0-9]+"
+		fallback {
+			provider ollama
+			redact off
+		}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Redact != nil || m.Privacy == nil || !*m.Privacy {
+		t.Errorf("redact, privacy = %v, %v", m.Redact, m.Privacy)
+	}
+	if len(m.RedactPatterns) != 3 || m.RedactPatterns[0] != "ACME-[0-9A-F]{32}" {
+		t.Errorf("redact_pattern = %q", m.RedactPatterns)
+	}
+	if r := m.Fallbacks[0].Redact; r == nil || *r {
+		t.Errorf("fallback redact = %v, want off", r)
+	}
+}
+
+func TestUnmarshalCaddyfileTimeouts(t *testing.T) {
+	m, err := parseTestCaddyfile(`llm_resolver {
+		resolve_timeout 2m
+		discovery_timeout 15s
+		llm_timeout 30s
+		wait_timeout 20s
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(m.ResolveTimeout) != 2*time.Minute || time.Duration(m.DiscoveryTimeout) != 15*time.Second || time.Duration(m.LLMTimeout) != 30*time.Second {
+		t.Errorf("timeouts = %v, %v, %v", m.ResolveTimeout, m.DiscoveryTimeout, m.LLMTimeout)
+	}
+	if time.Duration(m.WaitTimeout) != 20*time.Second {
+		t.Errorf("wait_timeout = %v, want 20s", m.WaitTimeout)
+	}
+	if _, err := parseTestCaddyfile("llm_resolver {\n llm_timeout soon\n }"); err == nil {
+		t.Error("invalid duration: got no error")
+	}
+}
+
+func TestUnmarshalCaddyfileWarmup(t *testing.T) {
+	m, err := parseTestCaddyfile("llm_resolver {\n warmup\n }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Warmup {
+		t.Error("warmup not set")
+	}
+}
+
+func TestUnmarshalCaddyfileHostFilter(t *testing.T) {
+	m, err := parseTestCaddyfile(`llm_resolver {
+		deny_host ^test\. \.internal$
+		deny_host ^tmp-
+		negative_ttl 5m
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.DenyHosts) != 3 || m.DenyHosts[0] != `^test\.` || m.DenyHosts[2] != "^tmp-" {
+		t.Errorf("deny_hosts = %q", m.DenyHosts)
+	}
+	if time.Duration(m.NegativeTTL) != 5*time.Minute {
+		t.Errorf("negative_ttl = %v, want 5m", m.NegativeTTL)
+	}
+	if _, err := parseTestCaddyfile("llm_resolver {\n deny_host\n }"); err == nil {
+		t.Error("deny_host without patterns: got no error")
+	}
+}
+
+func TestUnmarshalCaddyfileMinConfidence(t *testing.T) {
+	m, err := parseTestCaddyfile("llm_resolver {\n min_confidence 0\n }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MinConfidence == nil || *m.MinConfidence != 0 {
+		t.Errorf("min_confidence = %v, want 0", m.MinConfidence)
+	}
+	for _, value := range []string{"1.5", "-0.1", "high"} {
+		if _, err := parseTestCaddyfile("llm_resolver {\n min_confidence " + value + "\n }"); err == nil {
+			t.Errorf("min_confidence %s: got no error", value)
+		}
+	}
+}
+
+func TestUnmarshalCaddyfilePickMargin(t *testing.T) {
+	for input, want := range map[string]float64{"0.2": 0.2, "off": -1} {
+		m, err := parseTestCaddyfile("llm_resolver {\n pick_margin " + input + "\n }")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.PickMargin == nil || *m.PickMargin != want {
+			t.Errorf("pick_margin %s = %v, want %v", input, m.PickMargin, want)
+		}
+	}
+	if _, err := parseTestCaddyfile("llm_resolver {\n pick_margin -0.1\n }"); err == nil {
+		t.Error("negative pick_margin: got no error")
+	}
+}
@@ -0,0 +1,528 @@
+package llm_resolver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// resolveEventsPath streams resolution progress to the resolving page
+	resolveEventsPath = "/_resolving/events"
+
+	// upstreamEventsPath tells the "is down" page when the target is back
+	upstreamEventsPath = "/_upstream/events"
+)
+
+// pageCSS is the shared style of the standalone pages served instead of a
+// proxied response. It follows the dashboard palette.
+const pageCSS = `
+        :root {
+            --bg: #0a0a08;
+            --surface: #111110;
+            --border: #222220;
+            --text: #d4d0c8;
+            --text-secondary: #8a8780;
+            --text-muted: #5a5850;
+            --accent: #d4a843;
+            --accent-dim: #a07e30;
+            --accent-glow: rgba(212, 168, 67, 0.08);
+            --red: #c47e7e;
+            --mono: 'JetBrains Mono', 'SF Mono', 'Cascadia Code', monospace;
+            --sans: 'DM Sans', system-ui, sans-serif;
+        }
+        *, *::before, *::after { box-sizing: border-box; margin: 0; padding: 0; }
+        body {
+            font-family: var(--sans);
+            background: var(--bg);
+            color: var(--text);
+            line-height: 1.6;
+            -webkit-font-smoothing: antialiased;
+            min-height: 100vh;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+        }
+        .page { max-width: 640px; width: 100%; padding: 48px 32px; }
+        .wordmark {
+            font-family: var(--mono);
+            font-size: 13px;
+            font-weight: 700;
+            color: var(--accent);
+            margin-bottom: 24px;
+        }
+        .wordmark span { color: var(--text-muted); font-weight: 400; }
+        h1 { font-size: 22px; font-weight: 600; color: #fff; margin-bottom: 8px; }
+        p { color: var(--text-secondary); margin-bottom: 16px; }
+        code, .mono { font-family: var(--mono); font-size: 12px; }
+        .error {
+            font-family: var(--mono);
+            font-size: 12px;
+            color: var(--red);
+            background: var(--surface);
+            border: 1px solid var(--border);
+            border-radius: 6px;
+            padding: 10px 12px;
+            margin-bottom: 16px;
+            white-space: pre-wrap;
+            word-break: break-word;
+        }
+        a { color: var(--accent); }
+        .btn {
+            display: inline-flex; align-items: center;
+            padding: 6px 12px;
+            font-family: var(--mono);
+            font-size: 11px;
+            font-weight: 500;
+            border: 1px solid var(--border);
+            border-radius: 6px;
+            background: var(--surface);
+            color: var(--text-secondary);
+            cursor: pointer;
+            text-decoration: none;
+            text-transform: uppercase;
+        }
+        .btn:hover { border-color: var(--accent-dim); color: var(--accent); background: var(--accent-glow); }
+        .steps { list-style: none; margin-bottom: 16px; font-family: var(--mono); font-size: 12px; }
+        .steps li { color: var(--text-muted); padding: 2px 0; }
+        .steps li::before { content: '\2713  '; color: var(--accent-dim); }
+        .steps li:last-child { color: var(--text); }
+        .steps li:last-child::before { content: '\2026  '; color: var(--accent); }
+        .steps.finished li:last-child::before { content: '\2713  '; color: var(--accent-dim); }
+        .reason { color: var(--text); }
+        .services { list-style: none; margin-bottom: 16px; font-family: var(--mono); font-size: 12px; }
+        .services li { padding: 3px 0; color: var(--text-muted); }
+        .services a { text-decoration: none; margin-right: 8px; }
+        .choices li { padding: 8px 0; border-bottom: 1px solid var(--border); }
+        .choices .reason { margin: 4px 0 0; font-family: var(--sans); font-size: 13px; color: var(--text-secondary); }
+        .confidence { color: var(--accent); margin-left: 8px; }
+        .details { list-style: none; margin-bottom: 16px; font-size: 13px; }
+        .details li { padding: 2px 0; color: var(--text-secondary); word-break: break-word; }
+        .details .label { display: inline-block; width: 96px; font-family: var(--mono); font-size: 11px; color: var(--text-muted); text-transform: uppercase; }`
+
+// writePage writes a standalone HTML page with the given title and body markup
+func writePage(w http.ResponseWriter, status int, title, body string) {
+	page := `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>` + html.EscapeString(title) + ` · tudy</title>
+    <style>` + pageCSS + `
+    </style>
+</head>
+<body>
+<div class="page">
+    <div class="wordmark">tudy <span>//</span> proxy</div>
+` + body + `
+</div>
+</body>
+</html>`
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(page))
+}
+
+// wantsHTML reports whether the client prefers an HTML page over plain text
+func wantsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// isNavigation reports whether r is a browser loading a page (as opposed
+// to an API call, asset or iframe)
+func isNavigation(r *http.Request) bool {
+	if r.Method != http.MethodGet || !wantsHTML(r) {
+		return false
+	}
+	dest := r.Header.Get("Sec-Fetch-Dest")
+	return dest == "" || dest == "document"
+}
+
+// resolvedURL is the URL to load once the resolution is done. The force and
+// prompt parameters are dropped so the redirect does not resolve again.
+func resolvedURL(r *http.Request) string {
+	query := r.URL.Query()
+	query.Del("force")
+	query.Del("prompt")
+	target := r.URL.EscapedPath()
+	if encoded := query.Encode(); encoded != "" {
+		target += "?" + encoded
+	}
+	return target
+}
+
+// resolveEventsURL returns the events stream for the flight a resolving page
+// waits on. Forced and prompted resolutions run in flights of their own, so
+// the stream carries the same force and prompt parameters.
+func resolveEventsURL(force bool, userPrompt string) string {
+	query := url.Values{}
+	if force {
+		query.Set("force", "")
+	}
+	if userPrompt != "" {
+		query.Set("prompt", userPrompt)
+	}
+	if len(query) == 0 {
+		return resolveEventsPath
+	}
+	return resolveEventsPath + "?" + query.Encode()
+}
+
+// serveResolvingPage shows the progress of an in-flight resolution, read from
+// the events URL, and redirects to target once the mapping is ready
+func serveResolvingPage(w http.ResponseWriter, hostname, target, events string) {
+	targetJSON, _ := json.Marshal(target)
+	eventsJSON, _ := json.Marshal(events)
+
+	w.Header().Set("Cache-Control", "no-store")
+	writePage(w, http.StatusOK, "Resolving "+hostname, fmt.Sprintf(`
+    <h1>Resolving <span class="mono">%s</span></h1>
+    <p>This hostname is not mapped yet. Looking for the service it belongs to&hellip;</p>
+    <ul class="steps" id="steps"></ul>
+    <p class="reason" id="reason" hidden></p>
+    <div class="error" id="error" hidden></div>
+    <p id="actions" hidden><a class="btn" href="">Try again</a> <a class="btn" href="https://proxy.localhost">Dashboard</a></p>
+    <script>
+    (function() {
+        var target = %s;
+        var steps = document.getElementById('steps');
+        var source = new EventSource(%s);
+        function step(text) {
+            var li = document.createElement('li');
+            li.textContent = text;
+            steps.appendChild(li);
+        }
+        source.addEventListener('progress', function(e) {
+            step(JSON.parse(e.data).message);
+        });
+        source.addEventListener('done', function(e) {
+            source.close();
+            var mapping = JSON.parse(e.data);
+            step('Routing to ' + mapping.target + ':' + mapping.port);
+            steps.className = 'steps finished';
+            if (mapping.llmReason) {
+                var reason = document.getElementById('reason');
+                reason.textContent = mapping.llmReason;
+                reason.hidden = false;
+            }
+            setTimeout(function() { location.replace(target); }, 800);
+        });
+        source.addEventListener('failed', function(e) {
+            source.close();
+            var data = JSON.parse(e.data);
+            if (data.noMatch || data.pick) {
+                location.replace(target);
+                return;
+            }
+            var error = document.getElementById('error');
+            error.textContent = data.message;
+            error.hidden = false;
+            document.getElementById('actions').hidden = false;
+            document.querySelector('#actions a').href = target;
+        });
+    })();
+    </script>`, html.EscapeString(hostname), targetJSON, eventsJSON))
+}
+
+// serveLimitPage explains that a daily LLM limit stopped the resolution
+func serveLimitPage(w http.ResponseWriter, r *http.Request, hostname string, limitErr *LimitError) {
+	if !wantsHTML(r) {
+		http.Error(w, fmt.Sprintf("Cannot resolve %s: %v", hostname, limitErr), http.StatusTooManyRequests)
+		return
+	}
+
+	setting := "max_daily_requests"
+	if limitErr.Limit == "cost" {
+		setting = "max_daily_cost"
+	}
+
+	writePage(w, http.StatusTooManyRequests, "Daily LLM limit reached", fmt.Sprintf(`
+    <h1>Daily LLM limit reached</h1>
+    <p><span class="mono">%s</span> is not mapped yet, and resolving it would need an LLM call.</p>
+    <div class="error">%s</div>
+    <p>Existing mappings keep working. The limit resets at midnight; raise <code>%s</code> in the Caddyfile, or map the hostname by hand on the <a href="https://proxy.localhost">dashboard</a>.</p>`,
+		html.EscapeString(hostname), html.EscapeString(limitErr.Error()), setting))
+}
+
+// serveNotFoundPage explains why a hostname is not resolved and lists the
+// mapped hostnames. Candidates, when given, are listed with buttons that map
+// the hostname to them through the mappings API.
+func serveNotFoundPage(w http.ResponseWriter, r *http.Request, hostname string, err error, mappings Mappings, candidates Candidates) {
+	hosts := make([]string, 0, len(mappings))
+	for host := range mappings {
+		// Related service mappings are keyed "origin:service"
+		if !strings.Contains(host, ":") {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+
+	reason := err.Error()
+	var rejected *RejectedError
+	if errors.As(err, &rejected) {
+		reason = rejected.Reason
+	}
+
+	if !wantsHTML(r) {
+		msg := fmt.Sprintf("%s is not resolved: %s", hostname, reason)
+		if len(hosts) > 0 {
+			msg += "\nAvailable: " + strings.Join(hosts, ", ")
+		}
+		http.Error(w, msg, http.StatusNotFound)
+		return
+	}
+
+	var body strings.Builder
+
+	// Rejected names cannot be retried; failures can
+	if rejected == nil || rejected.Err != nil {
+		query := r.URL.Query()
+		query.Set("force", "")
+		retryURL := (&url.URL{Path: r.URL.Path, RawQuery: query.Encode()}).String()
+		body.WriteString("<p>")
+		if rejected != nil {
+			fmt.Fprintf(&body, "The failure is remembered for %s so that stray requests do not call the LLM again. ",
+				time.Until(rejected.Until).Round(time.Second))
+		}
+		fmt.Fprintf(&body, `<a class="btn" href="%s">Retry now</a></p>`, html.EscapeString(retryURL))
+	}
+
+	if len(candidates) > 0 {
+		var guess *RouteMapping
+		var noMatch *NoMatchError
+		if errors.As(err, &noMatch) {
+			guess = noMatch.Guess
+		}
+
+		body.WriteString(`<p>Running services:</p><ul class="services">`)
+		for _, c := range candidates {
+			mapping := c.Mapping()
+			data, _ := json.Marshal(map[string]interface{}{
+				"type":    mapping.Type,
+				"target":  mapping.Target,
+				"port":    mapping.Port,
+				"workdir": candidateWorkdir(&c),
+			})
+			label := candidateLabel(c)
+			if guess != nil && guess.Type == mapping.Type && guess.Target == mapping.Target && guess.Port == mapping.Port {
+				label += fmt.Sprintf(" (best guess, confidence %.2f)", noMatch.Confidence)
+			}
+			fmt.Fprintf(&body, `<li><button class="btn" data-mapping="%s">Route here</button> %s</li>`,
+				html.EscapeString(string(data)), html.EscapeString(label))
+		}
+		body.WriteString("</ul>")
+	}
+
+	if len(hosts) == 0 {
+		body.WriteString("<p>No hostnames are mapped yet.</p>")
+	} else {
+		body.WriteString(`<p>Mapped hostnames:</p><ul class="services">`)
+		for _, host := range hosts {
+			mapping := mappings[host]
+			fmt.Fprintf(&body, `<li><a href="https://%s/">%s</a>%s:%d</li>`,
+				html.EscapeString(host), html.EscapeString(host), html.EscapeString(mapping.Target), mapping.Port)
+		}
+		body.WriteString("</ul>")
+	}
+
+	hostJSON, _ := json.Marshal(hostname)
+	targetJSON, _ := json.Marshal(resolvedURL(r))
+
+	w.Header().Set("Cache-Control", "no-store")
+	writePage(w, http.StatusNotFound, "Not resolved", fmt.Sprintf(`
+    <h1><span class="mono">%s</span> is not resolved</h1>
+    <div class="error">%s</div>
+    %s
+    <p><a class="btn" href="https://proxy.localhost">Dashboard</a></p>
+    <script>
+    (function() {
+        var hostname = %s;
+        var target = %s;
+        document.querySelectorAll('button[data-mapping]').forEach(function(button) {
+            button.addEventListener('click', async function() {
+                button.disabled = true;
+                var resp = await fetch('/_api/mappings/' + encodeURIComponent(hostname), {
+                    method: 'PUT',
+                    headers: {'Content-Type': 'application/json'},
+                    body: button.dataset.mapping
+                });
+                if (resp.ok) { location.replace(target); }
+                else { button.disabled = false; alert('Failed to save mapping'); }
+            });
+        });
+    })();
+    </script>`,
+		html.EscapeString(hostname), html.EscapeString(reason), body.String(), hostJSON, targetJSON))
+}
+
+// candidateLabel describes a discovered service for the not found page
+func candidateLabel(c Candidate) string {
+	if c.Process != nil {
+		label := fmt.Sprintf("localhost:%d %s", c.Port, c.Process.Command)
+		if c.Process.Workdir != "" {
+			label += " in " + c.Process.Workdir
+		}
+		return label
+	}
+	return fmt.Sprintf("%s:%d (%s)", c.Container.Name, c.Port, c.Container.Image)
+}
+
+// servePickerPage ranks the plausible targets of a hostname and pins the one
+// the user chooses. Nothing is proxied until then. current marks the pinned
+// choice when the page is opened again later.
+func servePickerPage(w http.ResponseWriter, r *http.Request, hostname string, choices []*RouteMapping, current *RouteMapping) {
+	w.Header().Set("Cache-Control", "no-store")
+	if !wantsHTML(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMultipleChoices)
+		json.NewEncoder(w).Encode(map[string]interface{}{"hostname": hostname, "choices": choices})
+		return
+	}
+
+	var list strings.Builder
+	for i, choice := range choices {
+		label := choiceLabel(choice)
+		if current != nil && current.Tier == TierPicked && current.Type == choice.Type && current.Target == choice.Target && current.Port == choice.Port {
+			label += " (current)"
+		}
+		fmt.Fprintf(&list, `
+        <li><button class="btn" data-choice="%d">Use this</button> <span class="mono">%s</span> <span class="confidence">%.2f</span><p class="reason">%s</p></li>`,
+			i, html.EscapeString(label), choice.Confidence, html.EscapeString(choice.LLMReason))
+	}
+
+	// Opened from the dashboard, the page continues to the site root
+	target := resolvedURL(r)
+	if r.URL.Path == pickPath {
+		target = "/"
+	}
+	hostJSON, _ := json.Marshal(hostname)
+	targetJSON, _ := json.Marshal(target)
+
+	writePage(w, http.StatusMultipleChoices, "Choose a target for "+hostname, fmt.Sprintf(`
+    <h1>Choose a target for <span class="mono">%s</span></h1>
+    <p>Several running services could serve this hostname. Pick one; it is kept as a pinned mapping, and this page stays reachable from the dashboard.</p>
+    <ul class="services choices">%s
+    </ul>
+    <p><a class="btn" href="https://proxy.localhost">Dashboard</a></p>
+    <script>
+    (function() {
+        var hostname = %s;
+        var target = %s;
+        document.querySelectorAll('button[data-choice]').forEach(function(button) {
+            button.addEventListener('click', async function() {
+                button.disabled = true;
+                var resp = await fetch('/_api/picks/' + encodeURIComponent(hostname), {
+                    method: 'POST',
+                    headers: {'Content-Type': 'application/json'},
+                    body: JSON.stringify({choice: Number(button.dataset.choice)})
+                });
+                if (resp.ok) { location.replace(target); }
+                else { button.disabled = false; alert('Failed to save choice'); }
+            });
+        });
+    })();
+    </script>`, html.EscapeString(hostname), list.String(), hostJSON, targetJSON))
+}
+
+// choiceLabel describes a picker choice
+func choiceLabel(mapping *RouteMapping) string {
+	label := fmt.Sprintf("%s:%d", mapping.Target, mapping.Port)
+	if mapping.ProcessIdentifier != nil && mapping.ProcessIdentifier.Workdir != "" {
+		label += " in " + mapping.ProcessIdentifier.Workdir
+	}
+	return label
+}
+
+// serveTargetDownPage answers for a mapping whose target is not running or
+// does not accept connections. The page follows the target over SSE and
+// reloads once it is back. A mapping the user chose is never replaced on its
+// own, so the page offers to resolve the hostname again; pickPending links
+// the picker left by a re-resolution that could not decide. Non-HTML clients
+// get the same information as JSON.
+func serveTargetDownPage(w http.ResponseWriter, r *http.Request, hostname string, mapping *RouteMapping, err error, lastSeen *Sighting, pickPending bool) {
+	w.Header().Set("Cache-Control", "no-store")
+	if !wantsHTML(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":    "upstream_down",
+			"message":  err.Error(),
+			"hostname": hostname,
+			"mapping":  mapping,
+			"lastSeen": lastSeen,
+		})
+		return
+	}
+
+	state := "does not accept connections."
+	if d := staleFor(mapping); d > 0 {
+		state = fmt.Sprintf("is no longer running. It has been missing for %s.", d.Round(time.Second))
+	}
+
+	var details strings.Builder
+	detail := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&details, `
+        <li><span class="label">%s</span>%s</li>`, label, html.EscapeString(value))
+		}
+	}
+	detail("Type", mapping.Type)
+	if mapping.ProcessIdentifier != nil {
+		detail("Workdir", mapping.ProcessIdentifier.Workdir)
+	}
+	detail("Reason", mapping.LLMReason)
+	if lastSeen != nil {
+		seen := fmt.Sprintf("%s ago", time.Since(lastSeen.Time).Round(time.Second))
+		if lastSeen.Description != "" {
+			seen += ": " + lastSeen.Description
+		}
+		detail("Last seen", seen)
+	} else {
+		detail("Last seen", "not since the proxy started")
+	}
+
+	query := r.URL.Query()
+	query.Set("force", "")
+	resolveURL := (&url.URL{Path: r.URL.Path, RawQuery: query.Encode()}).String()
+
+	var actions strings.Builder
+	fmt.Fprintf(&actions, `<a class="btn" href="%s">Retry</a> `, html.EscapeString(resolvedURL(r)))
+	if pickPending {
+		fmt.Fprintf(&actions, `<a class="btn" href="%s">Choose a target</a> `, pickPath)
+	}
+	fmt.Fprintf(&actions, `<a class="btn" href="%s">Resolve again</a> `, html.EscapeString(resolveURL))
+	actions.WriteString(`<a class="btn" href="https://proxy.localhost">Dashboard</a>`)
+
+	eventsJSON, _ := json.Marshal(upstreamEventsPath + "?" + url.Values{"key": {hostname}}.Encode())
+
+	writePage(w, http.StatusBadGateway, hostname+" is down", fmt.Sprintf(`
+    <h1><span class="mono">%s</span> is down</h1>
+    <p>It is mapped to <span class="mono">%s</span>, which %s</p>
+    <ul class="details">%s
+    </ul>
+    <div class="error" id="error">%s</div>
+    <p id="status">Waiting for it to come back; this page reloads on its own.</p>
+    <p>%s</p>
+    <script>
+    (function() {
+        var source = new EventSource(%s);
+        source.addEventListener('waiting', function(e) {
+            document.getElementById('error').textContent = JSON.parse(e.data).message;
+        });
+        source.addEventListener('up', function() {
+            source.close();
+            document.getElementById('status').textContent = 'It is back, reloading.';
+            location.reload();
+        });
+    })();
+    </script>`,
+		html.EscapeString(hostname), html.EscapeString(choiceLabel(mapping)), state, details.String(),
+		html.EscapeString(err.Error()), actions.String(), eventsJSON))
+}
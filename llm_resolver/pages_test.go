@@ -0,0 +1,145 @@
+package llm_resolver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolvedURL(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://blog.localhost/posts/a%20b?force&prompt=docker&page=2", nil)
+	if got := resolvedURL(r); got != "/posts/a%20b?page=2" {
+		t.Errorf("resolvedURL = %q", got)
+	}
+}
+
+func TestResolveEventsURL(t *testing.T) {
+	if got := resolveEventsURL(false, ""); got != resolveEventsPath {
+		t.Errorf("plain events URL = %q", got)
+	}
+
+	// The events stream selects the same flight as the navigation
+	events, err := url.Parse(resolveEventsURL(true, "use the docker one"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := events.Query()
+	if events.Path != resolveEventsPath || !query.Has("force") || query.Get("prompt") != "use the docker one" {
+		t.Errorf("events URL = %s", events)
+	}
+	if flightKey("blog.localhost", query.Has("force"), query.Get("prompt")) != flightKey("blog.localhost", true, "use the docker one") {
+		t.Error("events URL selects another flight")
+	}
+}
+
+func TestServeNotFoundPage(t *testing.T) {
+	mappings := Mappings{
+		"blog.localhost":     {Type: "process", Target: "localhost", Port: 3000},
+		"blog.localhost:api": {Type: "docker", Target: "api", Port: 8080},
+	}
+	candidates := buildCandidates(verifyProcesses, verifyContainers)
+	guess := 0.3
+	noMatch := &NoMatchError{Reason: "unsure", Confidence: guess, Guess: &RouteMapping{Type: "docker", Target: "shop-postgres-1", Port: 5432}}
+
+	t.Run("plain text", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		serveNotFoundPage(w, httptest.NewRequest("GET", "http://shop.localhost/", nil), "shop.localhost", noMatch, mappings, nil)
+		body := w.Body.String()
+		if w.Code != 404 || !strings.Contains(body, "shop.localhost is not resolved") || !strings.Contains(body, "Available: blog.localhost\n") {
+			t.Errorf("%d %q", w.Code, body)
+		}
+	})
+
+	t.Run("services to route to", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "http://shop.localhost/cart", nil)
+		r.Header.Set("Accept", "text/html")
+		w := httptest.NewRecorder()
+		serveNotFoundPage(w, r, "shop.localhost", noMatch, mappings, candidates)
+		body := w.Body.String()
+		if n := strings.Count(body, "Route here"); n != len(candidates) {
+			t.Errorf("got %d route buttons, want %d", n, len(candidates))
+		}
+		if !strings.Contains(body, "shop-postgres-1:5432 () (best guess, confidence 0.30)") {
+			t.Error("best guess not marked")
+		}
+		if !strings.Contains(body, "Retry now") {
+			t.Error("no retry link for a failed resolution")
+		}
+	})
+
+	t.Run("rejected name", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "http://wpad.localhost/", nil)
+		r.Header.Set("Accept", "text/html")
+		w := httptest.NewRecorder()
+		rejected := &RejectedError{Hostname: "wpad.localhost", Reason: "matches deny pattern"}
+		serveNotFoundPage(w, r, "wpad.localhost", rejected, mappings, nil)
+		if body := w.Body.String(); strings.Contains(body, "Retry now") || !strings.Contains(body, "matches deny pattern") {
+			t.Errorf("rejection page = %q", body)
+		}
+	})
+
+	t.Run("remembered failure", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "http://shop.localhost/", nil)
+		r.Header.Set("Accept", "text/html")
+		w := httptest.NewRecorder()
+		rejected := &RejectedError{Hostname: "shop.localhost", Reason: "resolution failed", Until: time.Now().Add(time.Minute), Err: errors.New("timeout")}
+		serveNotFoundPage(w, r, "shop.localhost", rejected, mappings, nil)
+		if body := w.Body.String(); !strings.Contains(body, "remembered for") || !strings.Contains(body, "?force=") {
+			t.Errorf("failure page = %q", body)
+		}
+	})
+}
+
+func TestServeTargetDownPage(t *testing.T) {
+	mapping := &RouteMapping{Type: "process", Target: "localhost", Port: 3000, LLMReason: "blog dev server", StaleSince: time.Now().Add(-time.Minute).Format(time.RFC3339)}
+	lastSeen := &Sighting{Time: time.Now().Add(-2 * time.Minute), Description: "node in /srv/blog"}
+	down := errors.New("connection refused")
+
+	t.Run("json", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		serveTargetDownPage(w, httptest.NewRequest("GET", "http://blog.localhost/", nil), "blog.localhost", mapping, down, lastSeen, false)
+		var body struct {
+			Error    string   `json:"error"`
+			Message  string   `json:"message"`
+			Hostname string   `json:"hostname"`
+			LastSeen Sighting `json:"lastSeen"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != 502 || body.Error != "upstream_down" || body.Message != "connection refused" || body.Hostname != "blog.localhost" || body.LastSeen.Description != lastSeen.Description {
+			t.Errorf("%d %+v", w.Code, body)
+		}
+	})
+
+	t.Run("html", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "http://blog.localhost/posts?page=2", nil)
+		r.Header.Set("Accept", "text/html")
+		w := httptest.NewRecorder()
+		serveTargetDownPage(w, r, "blog.localhost", mapping, down, lastSeen, true)
+		body := w.Body.String()
+		for _, want := range []string{"is no longer running", "node in /srv/blog", "Choose a target", "?force=&amp;page=2", "EventSource"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("page is missing %q", want)
+			}
+		}
+		if w.Header().Get("Cache-Control") != "no-store" {
+			t.Error("down page may be cached")
+		}
+	})
+
+	t.Run("never seen", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "http://blog.localhost/", nil)
+		r.Header.Set("Accept", "text/html")
+		w := httptest.NewRecorder()
+		serveTargetDownPage(w, r, "blog.localhost", &RouteMapping{Type: "process", Target: "localhost", Port: 3000}, down, nil, false)
+		body := w.Body.String()
+		if !strings.Contains(body, "does not accept connections") || !strings.Contains(body, "not since the proxy started") || strings.Contains(body, "Choose a target") {
+			t.Errorf("down page = %q", body)
+		}
+	})
+}
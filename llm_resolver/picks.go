@@ -0,0 +1,153 @@
+package llm_resolver
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// pickPath shows the picker page for the request's hostname
+const pickPath = "/_pick"
+
+// Pick is an ambiguous resolution waiting for the user to choose a target
+type Pick struct {
+	Hostname  string          `json:"hostname"`
+	Choices   []*RouteMapping `json:"choices"`
+	CreatedAt string          `json:"createdAt"`
+}
+
+// Picks holds pending picks in memory; a pick that is never made is simply
+// resolved again after a restart
+type Picks struct {
+	mu      sync.RWMutex
+	pending map[string]*Pick
+}
+
+// NewPicks creates an empty pick store
+func NewPicks() *Picks {
+	return &Picks{pending: make(map[string]*Pick)}
+}
+
+// Set stores the choices for a hostname, replacing an earlier pick
+func (p *Picks) Set(hostname string, choices []*RouteMapping) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending[hostname] = &Pick{Hostname: hostname, Choices: choices, CreatedAt: timeNow()}
+}
+
+// Get returns the pending pick for a hostname, or nil
+func (p *Picks) Get(hostname string) *Pick {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pending[hostname]
+}
+
+// Delete drops the pending pick for a hostname
+func (p *Picks) Delete(hostname string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, hostname)
+}
+
+// List returns the pending picks, newest first
+func (p *Picks) List() []*Pick {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := make([]*Pick, 0, len(p.pending))
+	for _, pick := range p.pending {
+		result = append(result, pick)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt != result[j].CreatedAt {
+			return result[i].CreatedAt > result[j].CreatedAt
+		}
+		return result[i].Hostname < result[j].Hostname
+	})
+	return result
+}
+
+// choicesFor returns the choices offered for a hostname: the pending pick,
+// or the choices kept on a picked mapping
+func (m *LLMResolver) choicesFor(hostname string) []*RouteMapping {
+	if pick := m.picks.Get(hostname); pick != nil {
+		return pick.Choices
+	}
+	if mapping := m.cache.Get(hostname); mapping != nil {
+		return mapping.Choices
+	}
+	return nil
+}
+
+// handlePicksAPI lists pending picks (/_api/picks), returns the choices for
+// a hostname (GET /_api/picks/{hostname}) or pins one of them
+// (POST /_api/picks/{hostname} with {"choice": index})
+func (m *LLMResolver) handlePicksAPI(w http.ResponseWriter, r *http.Request) error {
+	hostname := strings.TrimPrefix(r.URL.Path, "/_api/picks")
+	hostname = strings.Trim(hostname, "/")
+
+	if hostname == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return nil
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(m.picks.List())
+	}
+
+	choices := m.choicesFor(hostname)
+	if len(choices) == 0 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return nil
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(choices)
+
+	case http.MethodPost:
+		var body struct {
+			Choice int `json:"choice"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return nil
+		}
+		if body.Choice < 0 || body.Choice >= len(choices) {
+			http.Error(w, "Invalid choice", http.StatusBadRequest)
+			return nil
+		}
+
+		// Pin a copy of the choice, keeping all choices for a later change
+		mapping := *choices[body.Choice]
+		mapping.CreatedAt = timeNow()
+		mapping.Tier = TierPicked
+		mapping.Choices = choices
+		m.cache.Replace(hostname, &mapping)
+		if err := m.cache.Save(); err != nil {
+			http.Error(w, "Failed to save", http.StatusInternalServerError)
+			return nil
+		}
+		m.picks.Delete(hostname)
+		m.hostFilter.Forget(hostname)
+
+		m.logger.Info("picked target",
+			zap.String("hostname", hostname),
+			zap.String("type", mapping.Type),
+			zap.String("target", mapping.Target),
+			zap.Int("port", mapping.Port),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(&mapping)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+}
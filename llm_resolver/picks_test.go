@@ -0,0 +1,90 @@
+package llm_resolver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestPicksList(t *testing.T) {
+	picks := NewPicks()
+	picks.Set("shop.localhost", nil)
+	picks.Set("blog.localhost", nil)
+	picks.pending["old.localhost"] = &Pick{Hostname: "old.localhost", CreatedAt: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)}
+
+	var got []string
+	for _, pick := range picks.List() {
+		got = append(got, pick.Hostname)
+	}
+	if strings.Join(got, " ") != "blog.localhost shop.localhost old.localhost" {
+		t.Errorf("picks = %v", got)
+	}
+
+	picks.Delete("shop.localhost")
+	if picks.Get("shop.localhost") != nil {
+		t.Error("deleted pick still pending")
+	}
+}
+
+func TestHandlePicksAPI(t *testing.T) {
+	hostFilter, err := NewHostFilter(nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &LLMResolver{
+		cache:      NewCache(filepath.Join(t.TempDir(), "mappings.json"), zap.NewNop()),
+		picks:      NewPicks(),
+		hostFilter: hostFilter,
+		logger:     zap.NewNop(),
+	}
+	choices := []*RouteMapping{
+		{Type: "process", Target: "localhost", Port: 3001, Confidence: 0.8, Tier: TierLLM},
+		{Type: "process", Target: "localhost", Port: 3000, Confidence: 0.7, Tier: TierLLM},
+	}
+	m.picks.Set("shop.localhost", choices)
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		if err := m.handlePicksAPI(w, httptest.NewRequest(method, path, strings.NewReader(body))); err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+
+	if w := request(http.MethodGet, "/_api/picks/blog.localhost", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown pick = %d, want 404", w.Code)
+	}
+	if w := request(http.MethodPost, "/_api/picks/shop.localhost", `{"choice":2}`); w.Code != http.StatusBadRequest {
+		t.Errorf("choice out of range = %d, want 400", w.Code)
+	}
+
+	if w := request(http.MethodPost, "/_api/picks/shop.localhost", `{"choice":1}`); w.Code != http.StatusOK {
+		t.Fatalf("pick = %d %s", w.Code, w.Body)
+	}
+	mapping := m.cache.Get("shop.localhost")
+	if mapping == nil || mapping.Port != 3000 || mapping.Tier != TierPicked || len(mapping.Choices) != 2 {
+		t.Fatalf("mapping = %+v", mapping)
+	}
+	if m.picks.Get("shop.localhost") != nil {
+		t.Error("pick still pending")
+	}
+	if choices[1].Tier != TierLLM {
+		t.Error("picking changed the offered choice")
+	}
+
+	// The choices stay on the mapping, so the pick can be changed
+	w := request(http.MethodGet, "/_api/picks/shop.localhost", "")
+	var offered []*RouteMapping
+	if err := json.NewDecoder(w.Body).Decode(&offered); err != nil || len(offered) != 2 {
+		t.Errorf("choices after picking = %v, %v", offered, err)
+	}
+	if w := request(http.MethodPost, "/_api/picks/shop.localhost", `{"choice":0}`); w.Code != http.StatusOK || m.cache.Get("shop.localhost").Port != 3001 {
+		t.Errorf("changed pick = %d, %+v", w.Code, m.cache.Get("shop.localhost"))
+	}
+}
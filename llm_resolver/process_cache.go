@@ -1,6 +1,7 @@
 package llm_resolver
 
 import (
+	"context"
 	"sync"
 	"time"
 )
@@ -27,8 +28,14 @@ func NewProcessCache() *ProcessCache {
 // Get returns the cached processes, refreshing if stale.
 // Returns an error only if discovery fails and no cached data exists.
 func (c *ProcessCache) Get() ([]LocalProcess, error) {
+	return c.GetFresh(c.ttl)
+}
+
+// GetFresh returns processes discovered at most maxAge ago, refreshing
+// otherwise. Concurrent callers share one refresh.
+func (c *ProcessCache) GetFresh(maxAge time.Duration) ([]LocalProcess, error) {
 	c.mu.RLock()
-	if time.Since(c.lastUpdate) < c.ttl && c.processes != nil {
+	if time.Since(c.lastUpdate) < maxAge && c.processes != nil {
 		processes := c.processes
 		c.mu.RUnlock()
 		return processes, nil
@@ -40,11 +47,11 @@ func (c *ProcessCache) Get() ([]LocalProcess, error) {
 	defer c.mu.Unlock()
 
 	// Double-check after acquiring write lock
-	if time.Since(c.lastUpdate) < c.ttl && c.processes != nil {
+	if time.Since(c.lastUpdate) < maxAge && c.processes != nil {
 		return c.processes, nil
 	}
 
-	processes, err := DiscoverLocalProcesses()
+	processes, err := DiscoverLocalProcesses(context.Background())
 	if err != nil {
 		// If we have stale data, return it with a warning
 		if c.processes != nil {
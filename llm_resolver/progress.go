@@ -0,0 +1,72 @@
+package llm_resolver
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Progress stages reported while a hostname is resolved
+const (
+	StageDiscovering = "discovering"
+	StageHeuristic   = "heuristic"
+	StageAsking      = "asking"
+	StageInspecting  = "inspecting"
+	StageValidating  = "validating"
+)
+
+// ProgressEvent is one step of an in-flight resolution
+type ProgressEvent struct {
+	Stage   string `json:"stage"`
+	Message string `json:"message"`
+	Time    string `json:"time"`
+}
+
+// Progress collects the events of one resolution for any number of watchers
+type Progress struct {
+	mu      sync.Mutex
+	events  []ProgressEvent
+	changed chan struct{} // closed and replaced on every new event
+}
+
+func newProgress() *Progress {
+	return &Progress{changed: make(chan struct{})}
+}
+
+// report appends an event and wakes up the watchers
+func (p *Progress) report(stage, message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, ProgressEvent{
+		Stage:   stage,
+		Message: message,
+		Time:    time.Now().UTC().Format(time.RFC3339),
+	})
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// since returns the events after the first n and a channel that is closed
+// when the next event arrives
+func (p *Progress) since(n int) ([]ProgressEvent, <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n > len(p.events) {
+		n = len(p.events)
+	}
+	return append([]ProgressEvent(nil), p.events[n:]...), p.changed
+}
+
+type progressKey struct{}
+
+// withProgress attaches a progress collector to the resolution context
+func withProgress(ctx context.Context, p *Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, p)
+}
+
+// reportProgress records a stage on the progress collector of ctx, if any
+func reportProgress(ctx context.Context, stage, message string) {
+	if p, ok := ctx.Value(progressKey{}).(*Progress); ok {
+		p.report(stage, message)
+	}
+}
@@ -0,0 +1,136 @@
+package llm_resolver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// PromptData is passed to user prompt templates
+type PromptData struct {
+	// Hostname is the hostname to resolve (the origin hostname for related services)
+	Hostname string
+
+	// Service and Origin are set for related service resolution only
+	Service string
+	Origin  *RouteMapping
+
+	Candidates Candidates
+	Processes  []LocalProcess
+	Containers []DockerContainer
+	Mappings   Mappings
+	UserPrompt string
+
+	// Corrections are past manual fixes of model answers, most relevant first
+	Corrections []*Correction
+
+	// Discovery lists processes and containers as in the default prompt,
+	// labelled with the candidate IDs the model must answer with
+	Discovery string
+
+	// DefaultSystemPrompt and DefaultUserPrompt are the built-in prompts,
+	// so templates can extend rather than replace them
+	DefaultSystemPrompt string
+	DefaultUserPrompt   string
+}
+
+// LoadPromptTemplate parses a text/template prompt file and checks that it
+// renders against sample data. The template body replaces the system prompt;
+// an optional {{define "user"}} block in the same file replaces the user prompt.
+func LoadPromptTemplate(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := renderPrompts(tmpl, samplePromptData()); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// renderPrompts returns the system and user prompts, using the defaults
+// wherever the template does not provide one
+func renderPrompts(tmpl *template.Template, data *PromptData) (string, string, error) {
+	if tmpl == nil {
+		return data.DefaultSystemPrompt, data.DefaultUserPrompt, nil
+	}
+
+	systemPrompt, err := executeTemplate(tmpl, data)
+	if err != nil {
+		return "", "", fmt.Errorf("system prompt template: %w", err)
+	}
+	if strings.TrimSpace(systemPrompt) == "" {
+		systemPrompt = data.DefaultSystemPrompt
+	}
+
+	userPrompt := data.DefaultUserPrompt
+	if user := tmpl.Lookup("user"); user != nil {
+		userPrompt, err = executeTemplate(user, data)
+		if err != nil {
+			return "", "", fmt.Errorf("user prompt template: %w", err)
+		}
+	}
+
+	return systemPrompt, userPrompt, nil
+}
+
+func executeTemplate(tmpl *template.Template, data *PromptData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// samplePromptData is used to validate templates at provision time
+func samplePromptData() *PromptData {
+	processes := []LocalProcess{{
+		Port:    5173,
+		Command: "node",
+		Args:    "node vite",
+		Workdir: "/home/dev/myapp",
+	}}
+	containers := []DockerContainer{{
+		ID:      "0123456789ab",
+		Name:    "myapp-api-1",
+		Image:   "php:8.3",
+		Ports:   []int{8000},
+		IP:      "172.18.0.2",
+		Workdir: "/home/dev/myapp",
+		Labels: map[string]string{
+			"com.docker.compose.project": "myapp",
+			"com.docker.compose.service": "api",
+		},
+	}}
+	mappings := Mappings{
+		"myapp.localhost": {Type: "process", Target: "localhost", Port: 5173, Tier: TierHeuristic},
+	}
+	corrections := []*Correction{{
+		Hostname:  "admin.myapp.localhost",
+		Original:  CorrectionTarget{Type: "process", Target: "localhost", Port: 5173, Description: "node (args: node vite) [workdir: /home/dev/myapp]"},
+		Corrected: CorrectionTarget{Type: "docker", Target: "myapp-api-1", Port: 8000, Description: "image: php:8.3 [workdir: /home/dev/myapp]"},
+	}}
+	pc := newPromptContext("myapp.localhost", buildCandidates(processes, containers), containers, mappings, corrections, 0)
+
+	return &PromptData{
+		Hostname:            "myapp.localhost",
+		Service:             "api",
+		Origin:              &RouteMapping{Type: "process", Target: "localhost", Port: 5173},
+		Candidates:          pc.Candidates,
+		Processes:           processes,
+		Containers:          containers,
+		Mappings:            mappings,
+		Corrections:         pc.Corrections,
+		Discovery:           discoveryText(pc),
+		DefaultSystemPrompt: "default system prompt",
+		DefaultUserPrompt:   "default user prompt",
+	}
+}
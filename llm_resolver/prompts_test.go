@@ -0,0 +1,78 @@
+package llm_resolver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTemplate writes a prompt template file and returns its path
+func writeTemplate(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "prompt.tmpl")
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPromptTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{"system only", "{{.DefaultSystemPrompt}}\nPrefer containers.", false},
+		{"with user block", `Route {{.Hostname}}.{{define "user"}}{{.Discovery}}{{end}}`, false},
+		{"syntax error", "{{.Hostname", true},
+		{"unknown field", "{{.Host}}", true},
+		{"missing map key", "{{.Mappings.missing}}", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadPromptTemplate(writeTemplate(t, tt.text))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := LoadPromptTemplate(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Error("missing file: got no error")
+	}
+}
+
+func TestRenderPrompts(t *testing.T) {
+	data := samplePromptData()
+
+	system, user, err := renderPrompts(nil, data)
+	if err != nil || system != data.DefaultSystemPrompt || user != data.DefaultUserPrompt {
+		t.Errorf("no template: %q, %q, %v", system, user, err)
+	}
+
+	tmpl, err := LoadPromptTemplate(writeTemplate(t, `{{.DefaultSystemPrompt}} for {{.Hostname}}{{define "user"}}{{range .Candidates}}{{.ID}} {{end}}{{end}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	system, user, err = renderPrompts(tmpl, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if system != "default system prompt for myapp.localhost" {
+		t.Errorf("system = %q", system)
+	}
+	if strings.TrimSpace(user) != "p1 d1" {
+		t.Errorf("user = %q, want the candidate IDs", user)
+	}
+
+	// A template with only a user block keeps the default system prompt
+	tmpl, err = LoadPromptTemplate(writeTemplate(t, `{{define "user"}}{{.Hostname}}{{end}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	system, user, err = renderPrompts(tmpl, data)
+	if err != nil || system != data.DefaultSystemPrompt || user != "myapp.localhost" {
+		t.Errorf("user block only: %q, %q, %v", system, user, err)
+	}
+}
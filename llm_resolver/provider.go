@@ -0,0 +1,178 @@
+package llm_resolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Supported provider names for the `provider` subdirective
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+)
+
+// Provider sends a single prompt exchange to an LLM API.
+// Each adapter owns its wire format, authentication and structured-output handling.
+type Provider interface {
+	// Name returns the provider identifier (e.g. "openai")
+	Name() string
+
+	// Model returns the model the provider sends requests to
+	Model() string
+
+	// Complete sends the prompts and returns the model's raw text answer.
+	// The HTTP request is aborted when ctx is done.
+	Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error)
+}
+
+// CompletionRequest is a provider-neutral prompt exchange
+type CompletionRequest struct {
+	SystemPrompt string
+	UserPrompt   string
+
+	// Schema constrains the answer to a JSON object. Adapters enforce it with
+	// the provider's native mechanism (JSON schema response format, forced
+	// tool call or Ollama format) unless structured output is disabled.
+	Schema *ResponseSchema
+
+	// Messages are the turns following the user prompt in a tool-calling loop
+	Messages []Message
+
+	// Tools the model may call. With tools set, the model must call one of
+	// them; ToolChoice names a single tool to force instead.
+	Tools      []*ResponseSchema
+	ToolChoice string
+}
+
+// ResponseSchema describes a JSON object: the answer the model must give,
+// or the arguments of a tool it may call
+type ResponseSchema struct {
+	Name        string
+	Description string
+	Schema      map[string]interface{}
+}
+
+// Message is a conversation turn in a tool-calling loop
+type Message struct {
+	Role       string     `json:"role"`                 // "assistant" or "tool"
+	Content    string     `json:"content,omitempty"`    // assistant text or tool result
+	ToolCalls  []ToolCall `json:"toolCalls,omitempty"`  // calls made by the assistant
+	ToolCallID string     `json:"toolCallId,omitempty"` // call a tool result answers
+	ToolName   string     `json:"toolName,omitempty"`   // tool a tool result belongs to
+}
+
+// ToolCall is a tool invocation requested by the model
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// Usage is the token usage reported for a completion
+type Usage struct {
+	InputTokens  int     `json:"inputTokens"`
+	OutputTokens int     `json:"outputTokens"`
+	Cost         float64 `json:"cost,omitempty"` // USD, when the provider reports it (e.g. OpenRouter)
+}
+
+// Total returns input plus output tokens
+func (u Usage) Total() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// CompletionResponse is the provider-neutral model answer
+type CompletionResponse struct {
+	Content   string
+	ToolCalls []ToolCall
+	Usage     Usage
+
+	// Raw is the unparsed API response body, kept for the resolution journal
+	Raw string
+}
+
+// NewProvider creates a provider adapter from an endpoint configuration.
+// An empty APIURL selects the provider's default endpoint.
+func NewProvider(cfg EndpointConfig, httpClient *http.Client) (Provider, error) {
+	apiURL := cfg.APIURL
+	structured := cfg.StructuredOutput == nil || *cfg.StructuredOutput
+
+	switch cfg.Provider {
+	case "", ProviderOpenAI:
+		if apiURL == "" {
+			apiURL = defaultAPIURL
+		}
+		return &openAIProvider{apiURL: apiURL, apiKey: cfg.APIKey, model: cfg.Model, structured: structured, httpClient: httpClient}, nil
+	case ProviderAnthropic:
+		if apiURL == "" {
+			apiURL = defaultAnthropicAPIURL
+		}
+		return &anthropicProvider{apiURL: apiURL, apiKey: cfg.APIKey, model: cfg.Model, structured: structured, httpClient: httpClient}, nil
+	case ProviderOllama:
+		if apiURL == "" {
+			apiURL = defaultOllamaAPIURL
+		}
+		return &ollamaProvider{apiURL: apiURL, apiKey: cfg.APIKey, model: cfg.Model, structured: structured, httpClient: httpClient}, nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q (expected %s, %s or %s)", cfg.Provider, ProviderOpenAI, ProviderAnthropic, ProviderOllama)
+	}
+}
+
+// reportsCost reports whether a provider's adapter can fill Usage.Cost. Only
+// OpenAI-compatible APIs that include it, such as OpenRouter, do; Anthropic
+// and Ollama report tokens only.
+func reportsCost(provider string) bool {
+	return provider == "" || provider == ProviderOpenAI
+}
+
+// defaultModelFor returns the default model for a provider
+func defaultModelFor(provider string) string {
+	switch provider {
+	case ProviderAnthropic:
+		return "claude-haiku-4-5"
+	case ProviderOllama:
+		return "llama3.2"
+	default:
+		return "anthropic/claude-haiku-4.5"
+	}
+}
+
+// postJSON marshals body, POSTs it with the given headers and returns the
+// response body. Non-200 responses are returned as errors.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body interface{}) ([]byte, error) {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	return respBody, nil
+}
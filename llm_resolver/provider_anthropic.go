@@ -0,0 +1,182 @@
+package llm_resolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultAnthropicAPIURL = "https://api.anthropic.com/v1/messages"
+	anthropicVersion       = "2023-06-01"
+	anthropicMaxTokens     = 1024
+)
+
+// anthropicProvider talks to the native Anthropic Messages API
+type anthropicProvider struct {
+	apiURL     string
+	apiKey     string
+	model      string
+	structured bool
+	httpClient *http.Client
+}
+
+func (p *anthropicProvider) Name() string  { return ProviderAnthropic }
+func (p *anthropicProvider) Model() string { return p.model }
+
+// Complete sends a Messages API request. Schemas are enforced with a forced
+// tool call whose input is the answer object. Without a schema the assistant
+// turn is prefilled with "{" to force a bare JSON object, since the Messages
+// API has no JSON mode.
+func (p *anthropicProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if p.apiKey == "" {
+		return nil, errAPIKeyNotSet
+	}
+
+	tools := req.Tools
+	toolChoice := map[string]string{"type": "any"}
+	answerTool := ""
+	if len(tools) > 0 && req.ToolChoice != "" {
+		toolChoice = map[string]string{"type": "tool", "name": req.ToolChoice}
+	}
+	if len(tools) == 0 && req.Schema != nil && p.structured {
+		tools = []*ResponseSchema{req.Schema}
+		toolChoice = map[string]string{"type": "tool", "name": req.Schema.Name}
+		answerTool = req.Schema.Name
+	}
+	useTools := len(tools) > 0
+
+	messages := []map[string]interface{}{
+		{"role": "user", "content": req.UserPrompt},
+	}
+	messages = append(messages, anthropicMessages(req.Messages)...)
+	if !useTools {
+		messages = append(messages, map[string]interface{}{"role": "assistant", "content": "{"})
+	}
+
+	requestBody := map[string]interface{}{
+		"model":      p.model,
+		"max_tokens": anthropicMaxTokens,
+		"system":     req.SystemPrompt,
+		"messages":   messages,
+	}
+	if useTools {
+		specs := make([]map[string]interface{}, len(tools))
+		for i, tool := range tools {
+			specs[i] = map[string]interface{}{
+				"name":         tool.Name,
+				"description":  tool.Description,
+				"input_schema": tool.Schema,
+			}
+		}
+		requestBody["tools"] = specs
+		requestBody["tool_choice"] = toolChoice
+	}
+
+	body, err := postJSON(ctx, p.httpClient, p.apiURL, map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": anthropicVersion,
+	}, requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResponse struct {
+		Content []struct {
+			Type  string          `json:"type"`
+			Text  string          `json:"text"`
+			ID    string          `json:"id"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		} `json:"content"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse API response: %w", err)
+	}
+
+	result := &CompletionResponse{
+		Raw: string(body),
+		Usage: Usage{
+			InputTokens:  apiResponse.Usage.InputTokens,
+			OutputTokens: apiResponse.Usage.OutputTokens,
+		},
+	}
+
+	var text strings.Builder
+	for _, block := range apiResponse.Content {
+		switch block.Type {
+		case "tool_use":
+			// A forced answer tool carries the answer object as its input
+			if block.Name == answerTool {
+				result.Content = string(block.Input)
+				return result, nil
+			}
+			result.ToolCalls = append(result.ToolCalls, ToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: block.Input,
+			})
+		case "text":
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 && len(result.ToolCalls) == 0 {
+		return nil, fmt.Errorf("no response from LLM")
+	}
+
+	result.Content = text.String()
+	if !useTools {
+		result.Content = "{" + result.Content
+	}
+	return result, nil
+}
+
+// anthropicMessages converts loop turns to Messages API content blocks.
+// Consecutive tool results are merged into a single user turn.
+func anthropicMessages(msgs []Message) []map[string]interface{} {
+	var out []map[string]interface{}
+	var results []map[string]interface{}
+
+	flush := func() {
+		if len(results) > 0 {
+			out = append(out, map[string]interface{}{"role": "user", "content": results})
+			results = nil
+		}
+	}
+
+	for _, msg := range msgs {
+		if msg.Role == "tool" {
+			results = append(results, map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": msg.ToolCallID,
+				"content":     msg.Content,
+			})
+			continue
+		}
+		flush()
+
+		var blocks []map[string]interface{}
+		if msg.Content != "" {
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": msg.Content})
+		}
+		for _, call := range msg.ToolCalls {
+			blocks = append(blocks, map[string]interface{}{
+				"type":  "tool_use",
+				"id":    call.ID,
+				"name":  call.Name,
+				"input": call.Arguments,
+			})
+		}
+		out = append(out, map[string]interface{}{"role": msg.Role, "content": blocks})
+	}
+	flush()
+
+	return out
+}
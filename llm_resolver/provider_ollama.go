@@ -0,0 +1,144 @@
+package llm_resolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const defaultOllamaAPIURL = "http://localhost:11434/api/chat"
+
+// ollamaProvider talks to the native Ollama /api/chat endpoint
+type ollamaProvider struct {
+	apiURL     string
+	apiKey     string
+	model      string
+	structured bool
+	httpClient *http.Client
+}
+
+func (p *ollamaProvider) Name() string  { return ProviderOllama }
+func (p *ollamaProvider) Model() string { return p.model }
+
+// Complete sends a non-streaming chat request. Schemas are passed as Ollama's
+// structured output format; otherwise plain JSON format mode is used.
+// Ollama has no tool choice, so tools are offered and the model may still
+// answer in plain text.
+// Ollama needs no authentication; an API key is only sent when configured
+// (e.g. when Ollama sits behind an authenticating reverse proxy).
+func (p *ollamaProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	messages := []map[string]interface{}{
+		{"role": "system", "content": req.SystemPrompt},
+		{"role": "user", "content": req.UserPrompt},
+	}
+	for _, msg := range req.Messages {
+		messages = append(messages, ollamaMessage(msg))
+	}
+
+	requestBody := map[string]interface{}{
+		"model":    p.model,
+		"messages": messages,
+		"stream":   false,
+	}
+
+	switch {
+	case len(req.Tools) > 0:
+		tools := make([]map[string]interface{}, len(req.Tools))
+		for i, tool := range req.Tools {
+			tools[i] = map[string]interface{}{
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":        tool.Name,
+					"description": tool.Description,
+					"parameters":  tool.Schema,
+				},
+			}
+		}
+		requestBody["tools"] = tools
+	case p.structured && req.Schema != nil:
+		requestBody["format"] = req.Schema.Schema
+	default:
+		requestBody["format"] = "json"
+	}
+
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+
+	body, err := postJSON(ctx, p.httpClient, p.apiURL, headers, requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResponse struct {
+		Message struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Function struct {
+					Name      string          `json:"name"`
+					Arguments json.RawMessage `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"message"`
+		PromptEvalCount int `json:"prompt_eval_count"`
+		EvalCount       int `json:"eval_count"`
+	}
+
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse API response: %w", err)
+	}
+
+	result := &CompletionResponse{
+		Content: apiResponse.Message.Content,
+		Raw:     string(body),
+		Usage: Usage{
+			InputTokens:  apiResponse.PromptEvalCount,
+			OutputTokens: apiResponse.EvalCount,
+		},
+	}
+	// Ollama does not assign call IDs
+	for i, call := range apiResponse.Message.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, ToolCall{
+			ID:        fmt.Sprintf("call_%d", i),
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+
+	if result.Content == "" && len(result.ToolCalls) == 0 {
+		return nil, fmt.Errorf("no response from LLM")
+	}
+
+	return result, nil
+}
+
+// ollamaMessage converts a loop turn to the /api/chat format
+func ollamaMessage(msg Message) map[string]interface{} {
+	if msg.Role == "tool" {
+		return map[string]interface{}{
+			"role":      "tool",
+			"content":   msg.Content,
+			"tool_name": msg.ToolName,
+		}
+	}
+
+	out := map[string]interface{}{
+		"role":    msg.Role,
+		"content": msg.Content,
+	}
+	if len(msg.ToolCalls) > 0 {
+		calls := make([]map[string]interface{}, len(msg.ToolCalls))
+		for i, call := range msg.ToolCalls {
+			calls[i] = map[string]interface{}{
+				"function": map[string]interface{}{
+					"name":      call.Name,
+					"arguments": call.Arguments,
+				},
+			}
+		}
+		out["tool_calls"] = calls
+	}
+	return out
+}
@@ -0,0 +1,174 @@
+package llm_resolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const defaultAPIURL = "https://openrouter.ai/api/v1/chat/completions"
+
+// openAIProvider talks to OpenAI-compatible chat completions APIs
+// (OpenRouter, OpenAI, LM Studio, vLLM, Ollama's /v1 endpoint)
+type openAIProvider struct {
+	apiURL     string
+	apiKey     string
+	model      string
+	structured bool
+	httpClient *http.Client
+}
+
+func (p *openAIProvider) Name() string  { return ProviderOpenAI }
+func (p *openAIProvider) Model() string { return p.model }
+
+// Complete sends a chat completion request. Schemas are enforced with a
+// strict json_schema response format; otherwise plain JSON mode is used.
+// Tools are sent as functions with a required tool choice.
+func (p *openAIProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if p.apiKey == "" {
+		return nil, errAPIKeyNotSet
+	}
+
+	messages := []map[string]interface{}{
+		{"role": "system", "content": req.SystemPrompt},
+		{"role": "user", "content": req.UserPrompt},
+	}
+	for _, msg := range req.Messages {
+		messages = append(messages, openAIMessage(msg))
+	}
+
+	requestBody := map[string]interface{}{
+		"model":    p.model,
+		"messages": messages,
+	}
+
+	switch {
+	case len(req.Tools) > 0:
+		tools := make([]map[string]interface{}, len(req.Tools))
+		for i, tool := range req.Tools {
+			tools[i] = map[string]interface{}{
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":        tool.Name,
+					"description": tool.Description,
+					"parameters":  tool.Schema,
+				},
+			}
+		}
+		requestBody["tools"] = tools
+		requestBody["tool_choice"] = "required"
+		if req.ToolChoice != "" {
+			requestBody["tool_choice"] = map[string]interface{}{
+				"type":     "function",
+				"function": map[string]string{"name": req.ToolChoice},
+			}
+		}
+	case p.structured && req.Schema != nil:
+		requestBody["response_format"] = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   req.Schema.Name,
+				"strict": true,
+				"schema": req.Schema.Schema,
+			},
+		}
+	default:
+		requestBody["response_format"] = map[string]string{"type": "json_object"}
+	}
+
+	body, err := postJSON(ctx, p.httpClient, p.apiURL, map[string]string{
+		"Authorization": "Bearer " + p.apiKey,
+	}, requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResponse struct {
+		Choices []struct {
+			Message struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					ID       string `json:"id"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int     `json:"prompt_tokens"`
+			CompletionTokens int     `json:"completion_tokens"`
+			Cost             float64 `json:"cost"` // OpenRouter usage accounting
+		} `json:"usage"`
+	}
+
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse API response: %w", err)
+	}
+
+	if len(apiResponse.Choices) == 0 {
+		return nil, fmt.Errorf("no response from LLM")
+	}
+
+	message := apiResponse.Choices[0].Message
+	result := &CompletionResponse{
+		Content: message.Content,
+		Raw:     string(body),
+		Usage: Usage{
+			InputTokens:  apiResponse.Usage.PromptTokens,
+			OutputTokens: apiResponse.Usage.CompletionTokens,
+			Cost:         apiResponse.Usage.Cost,
+		},
+	}
+	for _, call := range message.ToolCalls {
+		// Arguments are model output; keep malformed ones as a JSON string
+		args := json.RawMessage(call.Function.Arguments)
+		if !json.Valid(args) {
+			args, _ = json.Marshal(call.Function.Arguments)
+		}
+		result.ToolCalls = append(result.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: args,
+		})
+	}
+
+	if result.Content == "" && len(result.ToolCalls) == 0 {
+		return nil, fmt.Errorf("no response from LLM")
+	}
+
+	return result, nil
+}
+
+// openAIMessage converts a loop turn to the chat completions format
+func openAIMessage(msg Message) map[string]interface{} {
+	if msg.Role == "tool" {
+		return map[string]interface{}{
+			"role":         "tool",
+			"tool_call_id": msg.ToolCallID,
+			"content":      msg.Content,
+		}
+	}
+
+	out := map[string]interface{}{
+		"role":    msg.Role,
+		"content": msg.Content,
+	}
+	if len(msg.ToolCalls) > 0 {
+		calls := make([]map[string]interface{}, len(msg.ToolCalls))
+		for i, call := range msg.ToolCalls {
+			calls[i] = map[string]interface{}{
+				"id":   call.ID,
+				"type": "function",
+				"function": map[string]string{
+					"name":      call.Name,
+					"arguments": string(call.Arguments),
+				},
+			}
+		}
+		out["tool_calls"] = calls
+	}
+	return out
+}
@@ -0,0 +1,244 @@
+package llm_resolver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestProvider creates a provider adapter pointed at apiURL
+func newTestProvider(t *testing.T, name, apiURL, apiKey string) Provider {
+	t.Helper()
+	p, err := NewProvider(EndpointConfig{Provider: name, APIURL: apiURL, APIKey: apiKey, Model: "test-model"}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// completeTest sends one exchange through a provider
+func completeTest(p Provider, req *CompletionRequest) (*CompletionResponse, error) {
+	return p.Complete(context.Background(), req)
+}
+
+// fakeAPI serves reply to every request and records the last request body
+// and headers
+type fakeAPI struct {
+	*httptest.Server
+	status  int
+	reply   string
+	body    map[string]interface{}
+	headers http.Header
+}
+
+func newFakeAPI(t *testing.T, reply string) *fakeAPI {
+	t.Helper()
+	api := &fakeAPI{status: http.StatusOK, reply: reply}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		api.body = nil
+		json.Unmarshal(data, &api.body)
+		api.headers = r.Header.Clone()
+		w.WriteHeader(api.status)
+		io.WriteString(w, api.reply)
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func TestProviderAdapters(t *testing.T) {
+	tests := []struct {
+		provider   string
+		reply      string
+		want       string
+		authHeader string
+		authValue  string
+	}{
+		{
+			provider:   ProviderOpenAI,
+			reply:      `{"choices":[{"message":{"content":"{\"type\":\"none\"}"}}]}`,
+			want:       `{"type":"none"}`,
+			authHeader: "Authorization",
+			authValue:  "Bearer secret",
+		},
+		{
+			provider:   ProviderAnthropic,
+			reply:      `{"content":[{"type":"text","text":"\"type\":\"none\"}"}]}`,
+			want:       `{"type":"none"}`,
+			authHeader: "X-Api-Key",
+			authValue:  "secret",
+		},
+		{
+			provider:   ProviderOllama,
+			reply:      `{"message":{"content":"{\"type\":\"none\"}"}}`,
+			want:       `{"type":"none"}`,
+			authHeader: "Authorization",
+			authValue:  "Bearer secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			api := newFakeAPI(t, tt.reply)
+			p := newTestProvider(t, tt.provider, api.URL, "secret")
+			if p.Name() != tt.provider || p.Model() != "test-model" {
+				t.Errorf("Name, Model = %q, %q", p.Name(), p.Model())
+			}
+
+			resp, err := completeTest(p, &CompletionRequest{SystemPrompt: "system", UserPrompt: "user"})
+			if err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+			if resp.Content != tt.want {
+				t.Errorf("Content = %q, want %q", resp.Content, tt.want)
+			}
+			if got := api.headers.Get(tt.authHeader); got != tt.authValue {
+				t.Errorf("%s = %q, want %q", tt.authHeader, got, tt.authValue)
+			}
+			if api.body["model"] != "test-model" {
+				t.Errorf("model = %v, want test-model", api.body["model"])
+			}
+			if data, _ := json.Marshal(api.body); !strings.Contains(string(data), `"user"`) || !strings.Contains(string(data), `"system"`) {
+				t.Errorf("request does not carry both prompts: %s", data)
+			}
+		})
+	}
+}
+
+func TestProviderErrors(t *testing.T) {
+	for _, name := range []string{ProviderOpenAI, ProviderAnthropic, ProviderOllama} {
+		t.Run(name, func(t *testing.T) {
+			api := newFakeAPI(t, `{"error":"overloaded"}`)
+			api.status = http.StatusServiceUnavailable
+			_, err := completeTest(newTestProvider(t, name, api.URL, "secret"), &CompletionRequest{UserPrompt: "user"})
+			if err == nil || !strings.Contains(err.Error(), "503") {
+				t.Errorf("non-200 answer: got %v, want an error with the status", err)
+			}
+
+			api.status = http.StatusOK
+			api.reply = `{}`
+			if _, err := completeTest(newTestProvider(t, name, api.URL, "secret"), &CompletionRequest{UserPrompt: "user"}); err == nil {
+				t.Error("empty answer: got no error")
+			}
+		})
+	}
+}
+
+func TestProviderAPIKey(t *testing.T) {
+	api := newFakeAPI(t, `{"message":{"content":"{}"}}`)
+
+	// Cloud APIs need a key; Ollama does not
+	for _, name := range []string{ProviderOpenAI, ProviderAnthropic} {
+		if _, err := completeTest(newTestProvider(t, name, api.URL, ""), &CompletionRequest{}); err == nil {
+			t.Errorf("%s: got no error without an API key", name)
+		}
+	}
+	if _, err := completeTest(newTestProvider(t, ProviderOllama, api.URL, ""), &CompletionRequest{}); err != nil {
+		t.Errorf("ollama without an API key: %v", err)
+	}
+	if got := api.headers.Get("Authorization"); got != "" {
+		t.Errorf("ollama sent Authorization %q without an API key", got)
+	}
+}
+
+func TestNewProviderUnknown(t *testing.T) {
+	if _, err := NewProvider(EndpointConfig{Provider: "gemini"}, http.DefaultClient); err == nil {
+		t.Error("got no error for an unknown provider")
+	}
+}
+
+func TestProviderSchema(t *testing.T) {
+	schema := candidateSchema([]string{"p1", "d1"})
+	req := &CompletionRequest{SystemPrompt: "system", UserPrompt: "user", Schema: schema}
+
+	tests := []struct {
+		provider string
+		reply    string
+		field    string // request field carrying the schema
+	}{
+		{ProviderOpenAI, `{"choices":[{"message":{"content":"{\"candidate_id\":\"p1\"}"}}]}`, "response_format"},
+		{ProviderAnthropic, `{"content":[{"type":"tool_use","name":"route_choice","input":{"candidate_id":"p1"}}]}`, "tools"},
+		{ProviderOllama, `{"message":{"content":"{\"candidate_id\":\"p1\"}"}}`, "format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			api := newFakeAPI(t, tt.reply)
+			resp, err := completeTest(newTestProvider(t, tt.provider, api.URL, "secret"), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Content != `{"candidate_id":"p1"}` {
+				t.Errorf("Content = %q", resp.Content)
+			}
+			if data, _ := json.Marshal(api.body[tt.field]); !strings.Contains(string(data), `"candidate_id"`) {
+				t.Errorf("%s does not carry the schema: %s", tt.field, data)
+			}
+		})
+	}
+}
+
+func TestProviderSchemaDisabled(t *testing.T) {
+	api := newFakeAPI(t, `{"choices":[{"message":{"content":"{}"}}]}`)
+	off := false
+	p, err := NewProvider(EndpointConfig{Provider: ProviderOpenAI, APIURL: api.URL, APIKey: "secret", StructuredOutput: &off}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := completeTest(p, &CompletionRequest{Schema: candidateSchema([]string{"p1"})}); err != nil {
+		t.Fatal(err)
+	}
+	if format, _ := api.body["response_format"].(map[string]interface{}); format["type"] != "json_object" {
+		t.Errorf("response_format = %v, want plain JSON mode", api.body["response_format"])
+	}
+}
+
+func TestProviderTools(t *testing.T) {
+	tools := []*ResponseSchema{
+		{Name: "read_manifest", Schema: map[string]interface{}{"type": "object"}},
+		candidateSchema([]string{"p1"}),
+	}
+
+	tests := []struct {
+		provider string
+		reply    string
+	}{
+		{ProviderOpenAI, `{"choices":[{"message":{"tool_calls":[{"id":"c1","function":{"name":"read_manifest","arguments":"{\"candidate_id\":\"p1\"}"}}]}}]}`},
+		{ProviderAnthropic, `{"content":[{"type":"tool_use","id":"c1","name":"read_manifest","input":{"candidate_id":"p1"}}]}`},
+		{ProviderOllama, `{"message":{"tool_calls":[{"function":{"name":"read_manifest","arguments":{"candidate_id":"p1"}}}]}}`},
+	}
+
+	// Agent tools are sent even with structured output disabled
+	off := false
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			api := newFakeAPI(t, tt.reply)
+			p, err := NewProvider(EndpointConfig{Provider: tt.provider, APIURL: api.URL, APIKey: "secret", Model: "test-model", StructuredOutput: &off}, http.DefaultClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := completeTest(p, &CompletionRequest{UserPrompt: "user", Tools: tools})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sent, _ := api.body["tools"].([]interface{}); len(sent) != len(tools) {
+				t.Errorf("sent %d tools, want %d", len(sent), len(tools))
+			}
+			if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "read_manifest" {
+				t.Fatalf("ToolCalls = %+v", resp.ToolCalls)
+			}
+			var args struct {
+				CandidateID string `json:"candidate_id"`
+			}
+			if err := json.Unmarshal(resp.ToolCalls[0].Arguments, &args); err != nil || args.CandidateID != "p1" {
+				t.Errorf("Arguments = %s", resp.ToolCalls[0].Arguments)
+			}
+		})
+	}
+}
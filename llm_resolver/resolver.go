@@ -1,88 +1,215 @@
 package llm_resolver
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"regexp"
+	"sort"
 	"strings"
+	"text/template"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-const defaultAPIURL = "https://openrouter.ai/api/v1/chat/completions"
-
 // Resolver handles LLM-based target resolution
 type Resolver struct {
-	apiKey         string
-	apiURL         string
-	model          string
-	composeProject string
-	logger         *zap.Logger
-	httpClient     *http.Client
+	endpoints []Endpoint
+	opts      ResolverOptions
+	logger    *zap.Logger
+}
+
+// noneType is the answer type for "no listed target serves this hostname"
+const noneType = "none"
+
+// defaultMinConfidence is the confidence below which an answer is not routed
+const defaultMinConfidence = 0.5
+
+// defaultPickMargin is how close an alternative's confidence must be to the
+// chosen candidate's for the user to be asked to pick
+const defaultPickMargin = 0.15
+
+// ErrNoMatch is returned without asking the LLM when discovery found nothing
+// that could serve the hostname. Every *NoMatchError matches it with errors.Is.
+var ErrNoMatch = errors.New("no running process or container to route to")
+
+// NoMatchError is returned when the model found no suitable target or was
+// not confident enough in its choice. Such answers are never cached.
+type NoMatchError struct {
+	Reason     string
+	Confidence float64
+	Guess      *RouteMapping // the low-confidence choice, nil for "none"
 }
 
-// NewResolver creates a new resolver instance
-func NewResolver(apiKey, apiURL, model, composeProject string, logger *zap.Logger) *Resolver {
-	if apiURL == "" {
-		apiURL = defaultAPIURL
+func (e *NoMatchError) Error() string {
+	if e.Guess != nil {
+		return fmt.Sprintf("no confident match (best guess %s:%s:%d at confidence %.2f): %s",
+			e.Guess.Type, e.Guess.Target, e.Guess.Port, e.Confidence, e.Reason)
 	}
+	return fmt.Sprintf("no matching service: %s", e.Reason)
+}
+
+// Is makes every NoMatchError match ErrNoMatch
+func (e *NoMatchError) Is(target error) bool {
+	return target == ErrNoMatch
+}
+
+// AmbiguousError is returned when the model rates other candidates about as
+// plausible as its choice, so the user should pick one
+type AmbiguousError struct {
+	Choices []*RouteMapping // most confident first
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("%d candidates are about equally plausible", len(e.Choices))
+}
+
+// ResolverOptions configures optional resolver behaviour
+type ResolverOptions struct {
+	// MinConfidence is the confidence below which an answer is treated as
+	// no match (0 = accept any answer that is not "none")
+	MinConfidence float64
+
+	// PickMargin makes hostname resolution return an *AmbiguousError when an
+	// alternative's confidence is within this margin of the chosen
+	// candidate's (negative = always route to the chosen candidate)
+	PickMargin float64
+
+	// ComposeProject limits container discovery to one compose project
+	ComposeProject string
+
+	// DisableHeuristics always asks the LLM
+	DisableHeuristics bool
+
+	// Agent enables the agentic resolution loop (nil = single call)
+	Agent *AgentConfig
+
+	// Journal records every resolution and its LLM exchanges (optional)
+	Journal *Journal
+
+	// Corrections provides past manual fixes as prompt examples (optional)
+	Corrections *Corrections
+
+	// Usage keeps LLM usage totals and enforces daily limits (optional)
+	Usage *UsageTracker
+
+	// Redactor scrubs secrets from prompts for endpoints with redaction on
+	Redactor *Redactor
+
+	// Discover replaces live process and container discovery (used by the
+	// eval harness to replay snapshots)
+	Discover func(ctx context.Context) ([]LocalProcess, []DockerContainer)
+
+	// DiscoveryTimeout bounds process and container discovery per resolution
+	// (0 = only the per-command timeouts apply)
+	DiscoveryTimeout time.Duration
+
+	// ContextBudget is the approximate token budget for the discovery and
+	// mappings sections of the prompt (0 = unlimited)
+	ContextBudget int
+
+	// SystemPromptTemplate and RelatedPromptTemplate override the built-in
+	// prompts (see LoadPromptTemplate)
+	SystemPromptTemplate  *template.Template
+	RelatedPromptTemplate *template.Template
+}
+
+// NewResolver creates a new resolver instance. Endpoints are tried in order.
+func NewResolver(endpoints []Endpoint, opts ResolverOptions, logger *zap.Logger) *Resolver {
 	return &Resolver{
-		apiKey:         apiKey,
-		apiURL:         apiURL,
-		model:          model,
-		composeProject: composeProject,
-		logger:         logger,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		endpoints: endpoints,
+		opts:      opts,
+		logger:    logger,
 	}
 }
 
-// LLMResponse represents the expected response from the LLM
+// LLMResponse represents the expected response from the LLM.
+// The model answers with CandidateID; Type, Target, Port and Workdir are then
+// filled in from the discovery record. Free-form target fields are still
+// accepted from models that ignore the candidate format.
 type LLMResponse struct {
-	Type           string `json:"type"`
-	Target         string `json:"target"`
-	Port           int    `json:"port"`
-	Reason         string `json:"reason"`
-	Workdir        string `json:"workdir,omitempty"`        // Working directory for process identification
-	CommandPattern string `json:"commandPattern,omitempty"` // Optional regex to match command
+	CandidateID    string        `json:"candidate_id,omitempty"`
+	Type           string        `json:"type"` // "process", "docker" or "none"
+	Target         string        `json:"target"`
+	Port           int           `json:"port"`
+	Confidence     *float64      `json:"confidence,omitempty"` // 0-1, missing from models that ignore the format
+	Reason         string        `json:"reason"`
+	Alternatives   []Alternative `json:"alternatives,omitempty"`
+	Workdir        string        `json:"workdir,omitempty"`        // Working directory for process identification
+	CommandPattern string        `json:"commandPattern,omitempty"` // Optional regex to match command
 }
 
-// ResolveTarget resolves a hostname to a target using the LLM
-func (r *Resolver) ResolveTarget(hostname, userPrompt string, existingMappings Mappings) (*RouteMapping, error) {
-	if r.apiKey == "" {
-		return nil, fmt.Errorf("API key is not set")
-	}
+// Alternative is another candidate the model considers plausible
+type Alternative struct {
+	CandidateID string  `json:"candidate_id"`
+	Confidence  float64 `json:"confidence"`
+	Reason      string  `json:"reason"`
+}
 
-	// Gather context
-	processes, err := DiscoverLocalProcesses()
-	if err != nil {
-		r.logger.Warn("failed to discover processes", zap.Error(err))
+// ResolveTarget resolves a hostname to a target, trying the local heuristic
+// matcher first and falling back to the LLM. Discovery commands and LLM
+// requests are aborted when ctx is done.
+func (r *Resolver) ResolveTarget(ctx context.Context, hostname, userPrompt string, existingMappings Mappings) (*RouteMapping, error) {
+	res := newResolution(hostname, "")
+	mapping, err := r.resolveTarget(ctx, res, hostname, userPrompt, existingMappings)
+	r.finishResolution(res, mapping, err)
+	return mapping, err
+}
+
+func (r *Resolver) resolveTarget(ctx context.Context, res *Resolution, hostname, userPrompt string, existingMappings Mappings) (*RouteMapping, error) {
+	processes, containers := r.discover(ctx)
+
+	// A custom prompt means the user wants the LLM to reconsider
+	if !r.opts.DisableHeuristics && userPrompt == "" {
+		if mapping := ResolveHeuristic(hostname, processes, containers); mapping != nil {
+			reportProgress(ctx, StageHeuristic, "Matched by name without asking the model")
+			res.prompt = &promptContext{Candidates: buildCandidates(processes, containers)}
+			return mapping, nil
+		}
 	}
 
-	containers, err := DiscoverDockerContainers(r.composeProject)
+	corrections := r.opts.Corrections.Relevant(hostname, maxCorrectionExamples)
+	pc := newPromptContext(hostname, buildCandidates(processes, containers), containers, existingMappings, corrections, r.opts.ContextBudget)
+	res.prompt = pc
+	if len(pc.Candidates) == 0 {
+		return nil, ErrNoMatch
+	}
+	candidates := pc.Candidates
+	systemPrompt, prompt, err := renderPrompts(r.opts.SystemPromptTemplate, &PromptData{
+		Hostname:            hostname,
+		Candidates:          candidates,
+		Processes:           processes,
+		Containers:          containers,
+		Mappings:            existingMappings,
+		Corrections:         pc.Corrections,
+		UserPrompt:          userPrompt,
+		Discovery:           discoveryText(pc),
+		DefaultSystemPrompt: r.getSystemPrompt(),
+		DefaultUserPrompt:   r.buildPrompt(hostname, pc, userPrompt),
+	})
 	if err != nil {
-		r.logger.Warn("failed to discover containers", zap.Error(err))
+		return nil, err
 	}
 
-	prompt := r.buildPrompt(hostname, processes, containers, existingMappings, userPrompt)
-	systemPrompt := r.getSystemPrompt()
-
-	response, err := r.callLLM(systemPrompt, prompt)
+	response, transcript, err := r.ask(ctx, res, systemPrompt, prompt, candidates, processes, containers)
 	if err != nil {
 		return nil, err
 	}
+	if err := r.checkMatch(response); err != nil {
+		return nil, err
+	}
 
 	mapping := &RouteMapping{
-		Type:      response.Type,
-		Target:    response.Target,
-		Port:      response.Port,
-		CreatedAt: time.Now().UTC().Format(time.RFC3339),
-		LLMReason: response.Reason,
+		Type:       response.Type,
+		Target:     response.Target,
+		Port:       response.Port,
+		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+		LLMReason:  response.Reason,
+		Confidence: confidence(response),
+		Tier:       TierLLM,
+		Transcript: transcript,
 	}
 
 	// For process type, create ProcessIdentifier for dynamic port resolution
@@ -93,46 +220,87 @@ func (r *Resolver) ResolveTarget(hostname, userPrompt string, existingMappings M
 		}
 	}
 
+	// Several plausible targets (e.g. the same app in sibling worktrees) are
+	// left for the user to pick
+	if choices := r.ambiguousChoices(response, mapping, candidates); choices != nil {
+		for _, choice := range choices {
+			choice.ResolutionID = res.ID
+		}
+		return nil, &AmbiguousError{Choices: choices}
+	}
+
 	return mapping, nil
 }
 
 // ResolveRelatedService resolves a related service for an origin hostname
 func (r *Resolver) ResolveRelatedService(
+	ctx context.Context,
 	originHostname string,
 	originMapping *RouteMapping,
 	serviceName string,
 	userPrompt string,
 	existingMappings Mappings,
 ) (*RouteMapping, error) {
-	if r.apiKey == "" {
-		return nil, fmt.Errorf("API key is not set")
-	}
+	res := newResolution(originHostname, serviceName)
+	mapping, err := r.resolveRelatedService(ctx, res, originHostname, originMapping, serviceName, userPrompt, existingMappings)
+	r.finishResolution(res, mapping, err)
+	return mapping, err
+}
 
-	// Gather context
-	processes, err := DiscoverLocalProcesses()
+func (r *Resolver) resolveRelatedService(
+	ctx context.Context,
+	res *Resolution,
+	originHostname string,
+	originMapping *RouteMapping,
+	serviceName string,
+	userPrompt string,
+	existingMappings Mappings,
+) (*RouteMapping, error) {
+	processes, containers := r.discover(ctx)
+
+	// Rank by the origin hostname and service name together
+	corrections := r.opts.Corrections.Relevant(originHostname+":"+serviceName, maxCorrectionExamples)
+	pc := newPromptContext(originHostname+"."+serviceName, buildCandidates(processes, containers), containers, existingMappings, corrections, r.opts.ContextBudget)
+	res.prompt = pc
+	if len(pc.Candidates) == 0 {
+		return nil, ErrNoMatch
+	}
+	candidates := pc.Candidates
+	systemPrompt, prompt, err := renderPrompts(r.opts.RelatedPromptTemplate, &PromptData{
+		Hostname:            originHostname,
+		Service:             serviceName,
+		Origin:              originMapping,
+		Candidates:          candidates,
+		Processes:           processes,
+		Containers:          containers,
+		Mappings:            existingMappings,
+		Corrections:         pc.Corrections,
+		UserPrompt:          userPrompt,
+		Discovery:           discoveryText(pc),
+		DefaultSystemPrompt: r.getRelatedServiceSystemPrompt(),
+		DefaultUserPrompt:   r.buildRelatedServicePrompt(originHostname, originMapping, serviceName, pc, userPrompt),
+	})
 	if err != nil {
-		r.logger.Warn("failed to discover processes", zap.Error(err))
+		return nil, err
 	}
 
-	containers, err := DiscoverDockerContainers(r.composeProject)
+	response, transcript, err := r.ask(ctx, res, systemPrompt, prompt, candidates, processes, containers)
 	if err != nil {
-		r.logger.Warn("failed to discover containers", zap.Error(err))
+		return nil, err
 	}
-
-	prompt := r.buildRelatedServicePrompt(originHostname, originMapping, serviceName, processes, containers, existingMappings, userPrompt)
-	systemPrompt := r.getRelatedServiceSystemPrompt()
-
-	response, err := r.callLLM(systemPrompt, prompt)
-	if err != nil {
+	if err := r.checkMatch(response); err != nil {
 		return nil, err
 	}
 
 	mapping := &RouteMapping{
-		Type:      response.Type,
-		Target:    response.Target,
-		Port:      response.Port,
-		CreatedAt: time.Now().UTC().Format(time.RFC3339),
-		LLMReason: response.Reason,
+		Type:       response.Type,
+		Target:     response.Target,
+		Port:       response.Port,
+		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+		LLMReason:  response.Reason,
+		Confidence: confidence(response),
+		Tier:       TierLLM,
+		Transcript: transcript,
 	}
 
 	// For process type, create ProcessIdentifier for dynamic port resolution
@@ -146,6 +314,123 @@ func (r *Resolver) ResolveRelatedService(
 	return mapping, nil
 }
 
+// discover gathers the processes and containers the resolution chooses from
+func (r *Resolver) discover(ctx context.Context) ([]LocalProcess, []DockerContainer) {
+	if r.opts.Discover != nil {
+		return r.opts.Discover(ctx)
+	}
+
+	if r.opts.DiscoveryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.opts.DiscoveryTimeout)
+		defer cancel()
+	}
+	reportProgress(ctx, StageDiscovering, "Discovering processes and containers")
+
+	processes, err := DiscoverLocalProcesses(ctx)
+	if err != nil {
+		r.logger.Warn("failed to discover processes", zap.Error(err))
+	}
+
+	containers, err := DiscoverDockerContainers(ctx, r.opts.ComposeProject)
+	if err != nil {
+		r.logger.Warn("failed to discover containers", zap.Error(err))
+	}
+
+	return processes, containers
+}
+
+// ambiguousChoices returns the chosen mapping and the alternatives within
+// the pick margin, most confident first, or nil if the choice is clear
+func (r *Resolver) ambiguousChoices(response *LLMResponse, mapping *RouteMapping, candidates Candidates) []*RouteMapping {
+	if r.opts.PickMargin < 0 || response.Confidence == nil || response.CandidateID == "" {
+		return nil
+	}
+
+	choices := []*RouteMapping{mapping}
+	seen := map[string]bool{response.CandidateID: true}
+	for _, alt := range response.Alternatives {
+		candidate := candidates.Find(alt.CandidateID)
+		if candidate == nil || seen[alt.CandidateID] {
+			continue
+		}
+		if alt.Confidence < r.opts.MinConfidence || *response.Confidence-alt.Confidence > r.opts.PickMargin {
+			continue
+		}
+		seen[alt.CandidateID] = true
+
+		choice := candidate.Mapping()
+		choice.CreatedAt = mapping.CreatedAt
+		choice.LLMReason = alt.Reason
+		choice.Confidence = alt.Confidence
+		choice.Tier = TierLLM
+		choices = append(choices, choice)
+	}
+	if len(choices) < 2 {
+		return nil
+	}
+
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].Confidence > choices[j].Confidence })
+	return choices
+}
+
+// checkMatch turns "none" answers and answers below the confidence
+// threshold into a *NoMatchError
+func (r *Resolver) checkMatch(response *LLMResponse) error {
+	if response.Type == noneType {
+		return &NoMatchError{Reason: response.Reason}
+	}
+	if response.Confidence != nil && *response.Confidence < r.opts.MinConfidence {
+		return &NoMatchError{
+			Reason:     response.Reason,
+			Confidence: *response.Confidence,
+			Guess:      &RouteMapping{Type: response.Type, Target: response.Target, Port: response.Port},
+		}
+	}
+	return nil
+}
+
+// confidence returns the answer's confidence, or 0 when the model gave none
+func confidence(response *LLMResponse) float64 {
+	if response.Confidence == nil {
+		return 0
+	}
+	return *response.Confidence
+}
+
+// ask runs the agentic loop when enabled, or a single verified call otherwise.
+// The agent needs candidate IDs to inspect, so it is skipped when nothing
+// was discovered.
+func (r *Resolver) ask(
+	ctx context.Context,
+	res *Resolution,
+	systemPrompt, prompt string,
+	candidates Candidates,
+	processes []LocalProcess,
+	containers []DockerContainer,
+) (*LLMResponse, []TranscriptStep, error) {
+	if r.opts.Agent != nil && len(candidates) > 0 {
+		return r.askAgent(ctx, res, systemPrompt, prompt, candidates, processes, containers)
+	}
+	response, err := r.askVerified(ctx, res, systemPrompt, prompt, candidates, processes, containers)
+	return response, nil, err
+}
+
+// finishResolution stores the outcome in the journal and links the mapping
+// to its journal entry
+func (r *Resolver) finishResolution(res *Resolution, mapping *RouteMapping, err error) {
+	res.finish(mapping, err)
+	if mapping != nil {
+		mapping.ResolutionID = res.ID
+	}
+	if r.opts.Journal == nil {
+		return
+	}
+	if err := r.opts.Journal.Add(res); err != nil {
+		r.logger.Warn("failed to write resolution journal", zap.Error(err))
+	}
+}
+
 func (r *Resolver) getSystemPrompt() string {
 	return `You are a routing resolver for a local development proxy. Your job is to determine which local service a request should be forwarded to based on the hostname.
 
@@ -161,18 +446,21 @@ Your task is to analyze the hostname and determine the best matching service. Co
 - Project names in the hostname vs working directories
 - Container names vs hostname parts
 
+Every routable process and container port is labelled with an ID in square brackets (e.g. [p1], [d2]).
+
 Respond with a JSON object:
 {
-  "type": "process" | "docker",
-  "target": "localhost" for process, or container name for docker,
-  "port": the port number to connect to,
+  "candidate_id": "ID of the chosen process or container port, e.g. \"p1\" or \"d2\", or \"none\"",
+  "confidence": 0.9,
   "reason": "brief explanation of why this target was chosen",
-  "workdir": "working directory of the matched process (REQUIRED for type=process, omit for docker)"
+  "alternatives": [{"candidate_id": "p2", "confidence": 0.4, "reason": "why this one could also fit"}]
 }
 
-IMPORTANT: For type="process", you MUST include the "workdir" field with the full working directory path of the matched process. This is used for dynamic port resolution when the process restarts on a different port.
+confidence is a number from 0 (a guess) to 1 (certain). List other candidates that could also plausibly serve the hostname under alternatives, with their own confidence; leave it empty if the choice is clear.
 
-If no suitable target is found, still provide your best guess with explanation.`
+IMPORTANT: Only answer with an ID that appears in the lists. Do not invent IDs, names, ports or paths.
+
+If no listed target plausibly serves the hostname, answer "none" instead of guessing. A wrong route is worse than no route.`
 }
 
 func (r *Resolver) getRelatedServiceSystemPrompt() string {
@@ -191,82 +479,31 @@ Your task is to find the related service. Consider:
 - Docker compose services often have related names (app, api, db, redis, etc.)
 - Common patterns: frontend+backend, app+api, web+server
 
+Every routable process and container port is labelled with an ID in square brackets (e.g. [p1], [d2]).
+
 Respond with a JSON object:
 {
-  "type": "process" | "docker",
-  "target": "localhost" for process, or container name for docker,
-  "port": the port number to connect to,
+  "candidate_id": "ID of the chosen process or container port, e.g. \"p1\" or \"d2\", or \"none\"",
+  "confidence": 0.9,
   "reason": "brief explanation of why this target was chosen",
-  "workdir": "working directory of the matched process (REQUIRED for type=process, omit for docker)"
+  "alternatives": [{"candidate_id": "p2", "confidence": 0.4, "reason": "why this one could also fit"}]
 }
 
-IMPORTANT: For type="process", you MUST include the "workdir" field with the full working directory path of the matched process. This is used for dynamic port resolution when the process restarts on a different port.
+confidence is a number from 0 (a guess) to 1 (certain). List other candidates that could also plausibly serve the hostname under alternatives, with their own confidence; leave it empty if the choice is clear.
+
+IMPORTANT: Only answer with an ID that appears in the lists. Do not invent IDs, names, ports or paths.
 
-If no suitable target is found, still provide your best guess with explanation.`
+If no listed target plausibly serves the hostname, answer "none" instead of guessing. A wrong route is worse than no route.`
 }
 
-func (r *Resolver) buildPrompt(
-	hostname string,
-	processes []LocalProcess,
-	containers []DockerContainer,
-	mappings Mappings,
-	userPrompt string,
-) string {
+func (r *Resolver) buildPrompt(hostname string, pc *promptContext, userPrompt string) string {
 	var b strings.Builder
 
 	b.WriteString(fmt.Sprintf("Hostname to resolve: %s\n\n", hostname))
 
-	b.WriteString("## Local Processes\n")
-	if len(processes) == 0 {
-		b.WriteString("No local processes with open ports found.\n")
-	} else {
-		for _, proc := range processes {
-			b.WriteString(fmt.Sprintf("- Port %d: %s", proc.Port, proc.Command))
-			if proc.Args != "" {
-				b.WriteString(fmt.Sprintf(" (args: %s)", proc.Args))
-			}
-			if proc.Workdir != "" {
-				b.WriteString(fmt.Sprintf(" [workdir: %s]", proc.Workdir))
-			}
-			b.WriteString("\n")
-		}
-	}
-
-	b.WriteString("\n## Docker Containers\n")
-	if len(containers) == 0 {
-		b.WriteString("No Docker containers found.\n")
-	} else {
-		for _, container := range containers {
-			b.WriteString(fmt.Sprintf("- %s (image: %s)", container.Name, container.Image))
-			if len(container.Ports) > 0 {
-				ports := make([]string, len(container.Ports))
-				for i, p := range container.Ports {
-					ports[i] = fmt.Sprintf("%d", p)
-				}
-				b.WriteString(fmt.Sprintf(" ports: %s", strings.Join(ports, ", ")))
-			}
-			if container.IP != "" {
-				b.WriteString(fmt.Sprintf(" [ip: %s]", container.IP))
-			}
-			if container.Workdir != "" {
-				b.WriteString(fmt.Sprintf(" [workdir: %s]", container.Workdir))
-			}
-			b.WriteString("\n")
-		}
-	}
-
-	b.WriteString("\n## Current Mappings\n")
-	if len(mappings) == 0 {
-		b.WriteString("No existing mappings.\n")
-	} else {
-		for host, mapping := range mappings {
-			b.WriteString(fmt.Sprintf("- %s -> %s:%s:%d", host, mapping.Type, mapping.Target, mapping.Port))
-			if mapping.LLMReason != "" {
-				b.WriteString(fmt.Sprintf(" (%s)", mapping.LLMReason))
-			}
-			b.WriteString("\n")
-		}
-	}
+	writeDiscovery(&b, pc)
+	writeMappings(&b, pc)
+	writeCorrections(&b, pc)
 
 	if userPrompt != "" {
 		b.WriteString(fmt.Sprintf("\n## Additional Context from User\n%s\n", userPrompt))
@@ -279,9 +516,7 @@ func (r *Resolver) buildRelatedServicePrompt(
 	originHostname string,
 	originMapping *RouteMapping,
 	serviceName string,
-	processes []LocalProcess,
-	containers []DockerContainer,
-	mappings Mappings,
+	pc *promptContext,
 	userPrompt string,
 ) string {
 	var b strings.Builder
@@ -293,121 +528,155 @@ func (r *Resolver) buildRelatedServicePrompt(
 	}
 	b.WriteString(fmt.Sprintf("Looking for related service: \"%s\"\n\n", serviceName))
 
+	writeDiscovery(&b, pc)
+	writeMappings(&b, pc)
+	writeCorrections(&b, pc)
+
+	if userPrompt != "" {
+		b.WriteString(fmt.Sprintf("\n## Additional Context from User\n%s\n", userPrompt))
+	}
+
+	return b.String()
+}
+
+// writeDiscovery writes the process and container sections, labelling every
+// routable target with its candidate ID
+func writeDiscovery(b *strings.Builder, pc *promptContext) {
 	b.WriteString("## Local Processes\n")
-	if len(processes) == 0 {
-		b.WriteString("No local processes with open ports found.\n")
-	} else {
-		for _, proc := range processes {
-			b.WriteString(fmt.Sprintf("- Port %d: %s", proc.Port, proc.Command))
-			if proc.Args != "" {
-				b.WriteString(fmt.Sprintf(" (args: %s)", proc.Args))
-			}
-			if proc.Workdir != "" {
-				b.WriteString(fmt.Sprintf(" [workdir: %s]", proc.Workdir))
-			}
-			b.WriteString("\n")
+	found := false
+	for _, c := range pc.Candidates {
+		if c.Process == nil {
+			continue
 		}
+		found = true
+		b.WriteString(processLine(c))
+	}
+	if !found {
+		b.WriteString("No local processes with open ports found.\n")
+	}
+	if pc.OmittedProcesses > 0 {
+		b.WriteString(fmt.Sprintf("(%d less relevant processes omitted)\n", pc.OmittedProcesses))
 	}
 
 	b.WriteString("\n## Docker Containers\n")
-	if len(containers) == 0 {
+	if len(pc.Containers) == 0 {
 		b.WriteString("No Docker containers found.\n")
-	} else {
-		for _, container := range containers {
-			b.WriteString(fmt.Sprintf("- %s (image: %s)", container.Name, container.Image))
-			if len(container.Ports) > 0 {
-				ports := make([]string, len(container.Ports))
-				for i, p := range container.Ports {
-					ports[i] = fmt.Sprintf("%d", p)
-				}
-				b.WriteString(fmt.Sprintf(" ports: %s", strings.Join(ports, ", ")))
-			}
-			if container.IP != "" {
-				b.WriteString(fmt.Sprintf(" [ip: %s]", container.IP))
-			}
-			if container.Workdir != "" {
-				b.WriteString(fmt.Sprintf(" [workdir: %s]", container.Workdir))
-			}
-			b.WriteString("\n")
-		}
 	}
+	for _, container := range pc.Containers {
+		b.WriteString(containerLine(container, pc.Candidates.ForContainer(container.Name)))
+	}
+	if pc.OmittedContainers > 0 {
+		b.WriteString(fmt.Sprintf("(%d less relevant containers omitted)\n", pc.OmittedContainers))
+	}
+}
 
-	b.WriteString("\n## Current Mappings\n")
-	if len(mappings) == 0 {
-		b.WriteString("No existing mappings.\n")
-	} else {
-		for host, mapping := range mappings {
-			b.WriteString(fmt.Sprintf("- %s -> %s:%s:%d", host, mapping.Type, mapping.Target, mapping.Port))
-			if mapping.LLMReason != "" {
-				b.WriteString(fmt.Sprintf(" (%s)", mapping.LLMReason))
-			}
-			b.WriteString("\n")
-		}
+// processLine describes a process candidate
+func processLine(c Candidate) string {
+	proc := c.Process
+	line := fmt.Sprintf("- [%s] Port %d: %s", c.ID, proc.Port, proc.Command)
+	if proc.Args != "" {
+		line += fmt.Sprintf(" (args: %s)", proc.Args)
+	}
+	if proc.Workdir != "" {
+		line += fmt.Sprintf(" [workdir: %s]", proc.Workdir)
 	}
+	return line + "\n"
+}
 
-	if userPrompt != "" {
-		b.WriteString(fmt.Sprintf("\n## Additional Context from User\n%s\n", userPrompt))
+// containerLine describes a container with its port candidates
+func containerLine(container DockerContainer, ports Candidates) string {
+	line := fmt.Sprintf("- %s (image: %s)", container.Name, container.Image)
+	if len(ports) > 0 {
+		labels := make([]string, len(ports))
+		for i, c := range ports {
+			labels[i] = fmt.Sprintf("%d [%s]", c.Port, c.ID)
+		}
+		line += fmt.Sprintf(" ports: %s", strings.Join(labels, ", "))
+	} else {
+		line += " (no exposed ports)"
+	}
+	if container.IP != "" {
+		line += fmt.Sprintf(" [ip: %s]", container.IP)
 	}
+	if container.Workdir != "" {
+		line += fmt.Sprintf(" [workdir: %s]", container.Workdir)
+	}
+	return line + "\n"
+}
 
+// discoveryText returns the discovery sections as a string for prompt templates
+func discoveryText(pc *promptContext) string {
+	var b strings.Builder
+	writeDiscovery(&b, pc)
 	return b.String()
 }
 
-func (r *Resolver) callLLM(systemPrompt, userPrompt string) (*LLMResponse, error) {
-	requestBody := map[string]interface{}{
-		"model": r.model,
-		"messages": []map[string]string{
-			{"role": "system", "content": systemPrompt},
-			{"role": "user", "content": userPrompt},
-		},
-		"response_format": map[string]string{"type": "json_object"},
+// writeMappings writes the current mappings section
+func writeMappings(b *strings.Builder, pc *promptContext) {
+	b.WriteString("\n## Current Mappings\n")
+	if len(pc.Mappings) == 0 && pc.OmittedMappings == 0 {
+		b.WriteString("No existing mappings.\n")
+		return
 	}
-
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	for _, hm := range pc.Mappings {
+		b.WriteString(mappingLine(hm))
 	}
-
-	req, err := http.NewRequest("POST", r.apiURL, bytes.NewReader(jsonBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	if pc.OmittedMappings > 0 {
+		b.WriteString(fmt.Sprintf("(%d less relevant mappings omitted)\n", pc.OmittedMappings))
 	}
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+r.apiKey)
-
-	resp, err := r.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to call API: %w", err)
+// mappingLine describes an existing mapping with its reason shortened
+func mappingLine(hm hostMapping) string {
+	mapping := hm.Mapping
+	line := fmt.Sprintf("- %s -> %s:%s:%d", hm.Host, mapping.Type, mapping.Target, mapping.Port)
+	if mapping.LLMReason != "" {
+		line += fmt.Sprintf(" (%s)", truncate(mapping.LLMReason, maxReasonChars))
 	}
-	defer resp.Body.Close()
+	return line + "\n"
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+// writeCorrections writes past manual fixes of model answers as examples
+func writeCorrections(b *strings.Builder, pc *promptContext) {
+	if len(pc.Corrections) == 0 {
+		return
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
+	b.WriteString("\n## Past Corrections\n")
+	b.WriteString("The user corrected these earlier answers. Follow the same naming conventions and avoid repeating the mistakes.\n")
+	for _, correction := range pc.Corrections {
+		b.WriteString(correctionLine(correction))
 	}
+}
 
-	var apiResponse struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
+// correctionLine describes a correction as an example for the model
+func correctionLine(c *Correction) string {
+	return fmt.Sprintf("- %s: answered %s, corrected to %s\n", c.Hostname, targetText(c.Original), targetText(c.Corrected))
+}
 
-	if err := json.Unmarshal(body, &apiResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse API response: %w", err)
+// targetText describes one side of a correction
+func targetText(t CorrectionTarget) string {
+	text := fmt.Sprintf("%s:%s:%d", t.Type, t.Target, t.Port)
+	if t.Description != "" {
+		text += fmt.Sprintf(" (%s)", t.Description)
 	}
+	return text
+}
 
-	if len(apiResponse.Choices) == 0 || apiResponse.Choices[0].Message.Content == "" {
-		return nil, fmt.Errorf("no response from LLM")
+func (r *Resolver) callLLM(ctx context.Context, res *Resolution, systemPrompt, userPrompt string, schema *ResponseSchema) (*LLMResponse, error) {
+	completion, err := r.complete(ctx, res, &CompletionRequest{
+		SystemPrompt: systemPrompt,
+		UserPrompt:   userPrompt,
+		Schema:       schema,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	content := apiResponse.Choices[0].Message.Content
+	return parseLLMResponse(completion.Content)
+}
 
+// parseLLMResponse decodes an answer object, tolerating markdown code fences
+func parseLLMResponse(content string) (*LLMResponse, error) {
 	// Strip markdown code blocks if present
 	content = stripMarkdownCodeBlocks(content)
 
@@ -416,11 +685,6 @@ func (r *Resolver) callLLM(systemPrompt, userPrompt string) (*LLMResponse, error
 		return nil, fmt.Errorf("failed to parse LLM response: %s - %w", content, err)
 	}
 
-	// Validate response
-	if err := validateLLMResponse(&llmResponse); err != nil {
-		return nil, fmt.Errorf("invalid LLM response: %w", err)
-	}
-
 	return &llmResponse, nil
 }
 
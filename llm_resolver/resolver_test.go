@@ -0,0 +1,105 @@
+package llm_resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCheckMatch(t *testing.T) {
+	r := NewResolver(nil, ResolverOptions{MinConfidence: 0.5}, zap.NewNop())
+	low, high := 0.3, 0.8
+	tests := []struct {
+		name      string
+		response  LLMResponse
+		wantGuess bool
+		wantErr   bool
+	}{
+		{"confident", LLMResponse{Type: "process", Target: "localhost", Port: 3000, Confidence: &high}, false, false},
+		{"no confidence given", LLMResponse{Type: "process", Target: "localhost", Port: 3000}, false, false},
+		{"below the threshold", LLMResponse{Type: "process", Target: "localhost", Port: 3000, Confidence: &low}, true, true},
+		{"none", LLMResponse{Type: noneType, Reason: "nothing like it"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.checkMatch(&tt.response)
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("got %v, want no error", err)
+				}
+				return
+			}
+			var noMatch *NoMatchError
+			if !errors.As(err, &noMatch) || !errors.Is(err, ErrNoMatch) {
+				t.Fatalf("got %v, want a NoMatchError", err)
+			}
+			if (noMatch.Guess != nil) != tt.wantGuess {
+				t.Errorf("guess = %+v", noMatch.Guess)
+			}
+		})
+	}
+}
+
+func TestResolveTargetNone(t *testing.T) {
+	provider := &replyProvider{replies: []string{`{"candidate_id":"none","confidence":0.9,"reason":"no shop service runs"}`}}
+	r := NewResolver([]Endpoint{{Provider: provider}}, ResolverOptions{
+		DisableHeuristics: true,
+		Discover: func(context.Context) ([]LocalProcess, []DockerContainer) {
+			return verifyProcesses, verifyContainers
+		},
+	}, zap.NewNop())
+
+	mapping, err := r.ResolveTarget(context.Background(), "shop.localhost", "", nil)
+	if mapping != nil || !errors.Is(err, ErrNoMatch) {
+		t.Errorf("got %+v, %v, want no match", mapping, err)
+	}
+}
+
+func TestAmbiguousChoices(t *testing.T) {
+	candidates := buildCandidates([]LocalProcess{
+		{Port: 3000, Command: "node", Workdir: "/home/dev/shop"},
+		{Port: 3001, Command: "node", Workdir: "/home/dev/shop-feature"},
+		{Port: 3002, Command: "node", Workdir: "/home/dev/blog"},
+	}, nil)
+	chosen := 0.7
+	answer := func(alternatives ...Alternative) *LLMResponse {
+		return &LLMResponse{CandidateID: "p1", Confidence: &chosen, Alternatives: alternatives}
+	}
+	mapping := &RouteMapping{Type: "process", Target: "localhost", Port: 3000, Confidence: chosen}
+
+	tests := []struct {
+		name     string
+		margin   float64
+		response *LLMResponse
+		want     []int // ports of the choices, nil when the choice is clear
+	}{
+		{"no alternatives", 0.15, answer(), nil},
+		{"alternative within the margin", 0.15, answer(Alternative{CandidateID: "p2", Confidence: 0.8}), []int{3001, 3000}},
+		{"alternative outside the margin", 0.15, answer(Alternative{CandidateID: "p2", Confidence: 0.4}), nil},
+		{"alternative below min confidence", 0.5, answer(Alternative{CandidateID: "p2", Confidence: 0.45}), nil},
+		{"unknown and repeated IDs", 0.15, answer(
+			Alternative{CandidateID: "x9", Confidence: 0.7},
+			Alternative{CandidateID: "p1", Confidence: 0.7},
+			Alternative{CandidateID: "p3", Confidence: 0.6},
+			Alternative{CandidateID: "p3", Confidence: 0.6},
+		), []int{3000, 3002}},
+		{"picker off", -1, answer(Alternative{CandidateID: "p2", Confidence: 0.7}), nil},
+		{"no confidence", 0.15, &LLMResponse{CandidateID: "p1", Alternatives: []Alternative{{CandidateID: "p2", Confidence: 0.7}}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewResolver(nil, ResolverOptions{MinConfidence: 0.5, PickMargin: tt.margin}, zap.NewNop())
+			choices := r.ambiguousChoices(tt.response, mapping, candidates)
+			var got []int
+			for _, choice := range choices {
+				got = append(got, choice.Port)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("choices on ports %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
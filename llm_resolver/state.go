@@ -0,0 +1,88 @@
+package llm_resolver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap"
+)
+
+// statePool holds the state of provisioned instances by configuration.
+// Caddy provisions a handler per server, so the llm_resolver on the port 80
+// server answering the on-demand TLS "ask" and the one on the HTTPS server are
+// separate instances; sharing the state lets both use one cache and one
+// flight group. A config reload with an unchanged block keeps the state.
+var statePool = caddy.NewUsagePool()
+
+// sharedState is everything an instance builds from its configuration
+type sharedState struct {
+	logger        *zap.Logger
+	logBuffer     *LogBuffer
+	cache         *Cache
+	journal       *Journal
+	corrections   *Corrections
+	usage         *UsageTracker
+	processCache  *ProcessCache
+	resolver      *Resolver
+	resolveGroup  *flightGroup
+	hostFilter    *HostFilter
+	picks         *Picks
+	sightings     *Sightings
+	launcher      *Launcher
+	networkTunnel *NetworkTunnel
+	stopVerify    context.CancelFunc
+}
+
+// Destruct stops the network tunnel, the verifier and the launched
+// processes once the last instance is cleaned up
+func (s *sharedState) Destruct() error {
+	s.networkTunnel.Stop()
+	if s.stopVerify != nil {
+		s.stopVerify()
+	}
+	s.launcher.StopAll()
+	return nil
+}
+
+// stateKey identifies a configuration; it is taken after defaults are applied.
+// The prompt template files are part of it, so a reload after editing one
+// parses the templates again.
+func stateKey(m *LLMResolver) (string, error) {
+	config, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	key := string(config)
+	for _, file := range []string{m.SystemPromptFile, m.RelatedPromptFile} {
+		if file == "" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("reading prompt template: %w", err)
+		}
+		key += fmt.Sprintf("\x00%x", sha256.Sum256(data))
+	}
+	return key, nil
+}
+
+// useState points the instance at the shared state
+func (m *LLMResolver) useState(s *sharedState) {
+	m.logger = s.logger
+	m.logBuffer = s.logBuffer
+	m.cache = s.cache
+	m.journal = s.journal
+	m.corrections = s.corrections
+	m.usage = s.usage
+	m.processCache = s.processCache
+	m.resolver = s.resolver
+	m.resolveGroup = s.resolveGroup
+	m.hostFilter = s.hostFilter
+	m.picks = s.picks
+	m.sightings = s.sightings
+	m.launcher = s.launcher
+}
@@ -0,0 +1,79 @@
+package llm_resolver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+func provisionTest(t *testing.T, m *LLMResolver) error {
+	t.Helper()
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	t.Cleanup(cancel)
+	if err := m.Provision(ctx); err != nil {
+		return err
+	}
+	t.Cleanup(func() { m.Cleanup() })
+	return nil
+}
+
+func TestProvisionSharesState(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "mappings.json")
+	first := &LLMResolver{CacheFile: cacheFile}
+	second := &LLMResolver{CacheFile: cacheFile}
+	other := &LLMResolver{CacheFile: cacheFile, MaxDailyRequests: 10}
+	for _, m := range []*LLMResolver{first, second, other} {
+		if err := provisionTest(t, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if first.resolver != second.resolver {
+		t.Error("instances with the same configuration do not share a resolver")
+	}
+	if first.resolver == other.resolver {
+		t.Error("instances with different configurations share a resolver")
+	}
+}
+
+func TestProvisionReloadsEditedTemplate(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "mappings.json")
+	promptFile := writeTemplate(t, "first")
+	render := func(m *LLMResolver) string {
+		var b strings.Builder
+		if err := m.resolver.opts.SystemPromptTemplate.Execute(&b, &PromptData{}); err != nil {
+			t.Fatal(err)
+		}
+		return b.String()
+	}
+
+	before := &LLMResolver{CacheFile: cacheFile, SystemPromptFile: promptFile}
+	if err := provisionTest(t, before); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(promptFile, []byte("second"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	after := &LLMResolver{CacheFile: cacheFile, SystemPromptFile: promptFile}
+	if err := provisionTest(t, after); err != nil {
+		t.Fatal(err)
+	}
+	if got := render(after); got != "second" {
+		t.Errorf("template after edit renders %q, want %q", got, "second")
+	}
+	if got := render(before); got != "first" {
+		t.Errorf("running instance renders %q, want %q", got, "first")
+	}
+
+	if err := os.WriteFile(promptFile, []byte("{{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	broken := &LLMResolver{CacheFile: cacheFile, SystemPromptFile: promptFile}
+	if err := provisionTest(t, broken); err == nil {
+		t.Error("broken template edit provisioned without error")
+	}
+}
@@ -0,0 +1,231 @@
+package llm_resolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// usageRetentionDays is the number of daily totals kept in the usage file
+const usageRetentionDays = 31
+
+// UsageTotals accumulates LLM requests, tokens and cost
+type UsageTotals struct {
+	Requests     int     `json:"requests"`
+	InputTokens  int     `json:"inputTokens"`
+	OutputTokens int     `json:"outputTokens"`
+	Cost         float64 `json:"cost"` // USD, only when the provider reports it
+}
+
+func (t *UsageTotals) add(requests int, usage Usage) {
+	t.Requests += requests
+	t.InputTokens += usage.InputTokens
+	t.OutputTokens += usage.OutputTokens
+	t.Cost += usage.Cost
+}
+
+// LimitError is returned when a daily LLM limit is reached
+type LimitError struct {
+	Limit string // "requests" or "cost"
+	Used  float64
+	Max   float64
+}
+
+func (e *LimitError) Error() string {
+	if e.Limit == "cost" {
+		return fmt.Sprintf("daily LLM cost limit reached ($%.4f of $%.2f)", e.Used, e.Max)
+	}
+	return fmt.Sprintf("daily LLM request limit reached (%.0f of %.0f)", e.Used, e.Max)
+}
+
+// usageData is the persisted form of the usage totals
+type usageData struct {
+	Days  map[string]*UsageTotals `json:"days"`  // keyed by local date (YYYY-MM-DD)
+	Hosts map[string]*UsageTotals `json:"hosts"` // keyed by hostname
+}
+
+// UsageTracker keeps per-day and per-hostname LLM usage totals and enforces
+// the daily limits
+type UsageTracker struct {
+	mu          sync.Mutex
+	data        usageData
+	filePath    string
+	maxRequests int
+	maxCost     float64
+	logger      *zap.Logger
+}
+
+// NewUsageTracker creates a tracker stored at filePath. Zero limits disable the check.
+func NewUsageTracker(filePath string, maxRequests int, maxCost float64, logger *zap.Logger) *UsageTracker {
+	return &UsageTracker{
+		data: usageData{
+			Days:  make(map[string]*UsageTotals),
+			Hosts: make(map[string]*UsageTotals),
+		},
+		filePath:    filePath,
+		maxRequests: maxRequests,
+		maxCost:     maxCost,
+		logger:      logger,
+	}
+}
+
+// Load reads the totals from the usage file
+func (u *UsageTracker) Load() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	data, err := os.ReadFile(u.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var loaded usageData
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return err
+	}
+	if loaded.Days != nil {
+		u.data.Days = loaded.Days
+	}
+	if loaded.Hosts != nil {
+		u.data.Hosts = loaded.Hosts
+	}
+	return nil
+}
+
+// Reserve counts one LLM request for hostname before it is sent. Once
+// today's totals reach a configured limit, it returns a *LimitError and
+// counts nothing. Checking and counting under one lock keeps concurrent
+// resolutions from overshooting the request limit.
+func (u *UsageTracker) Reserve(hostname string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if totals := u.data.Days[today()]; totals != nil {
+		if u.maxRequests > 0 && totals.Requests >= u.maxRequests {
+			return &LimitError{Limit: "requests", Used: float64(totals.Requests), Max: float64(u.maxRequests)}
+		}
+		if u.maxCost > 0 && totals.Cost >= u.maxCost {
+			return &LimitError{Limit: "cost", Used: totals.Cost, Max: u.maxCost}
+		}
+	}
+
+	u.addLocked(hostname, 1, Usage{})
+	return nil
+}
+
+// Add records the tokens and cost reported for a completed request
+func (u *UsageTracker) Add(hostname string, usage Usage) {
+	if usage == (Usage{}) {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.addLocked(hostname, 0, usage)
+}
+
+// addLocked adds to today's and the hostname's totals and saves them.
+// Callers must hold u.mu.
+func (u *UsageTracker) addLocked(hostname string, requests int, usage Usage) {
+	day := today()
+	if u.data.Days[day] == nil {
+		u.data.Days[day] = &UsageTotals{}
+	}
+	u.data.Days[day].add(requests, usage)
+	if u.data.Hosts[hostname] == nil {
+		u.data.Hosts[hostname] = &UsageTotals{}
+	}
+	u.data.Hosts[hostname].add(requests, usage)
+	u.prune()
+
+	if err := u.save(); err != nil {
+		u.logger.Warn("failed to save usage totals", zap.Error(err))
+	}
+}
+
+// Today returns today's totals
+func (u *UsageTracker) Today() UsageTotals {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if totals := u.data.Days[today()]; totals != nil {
+		return *totals
+	}
+	return UsageTotals{}
+}
+
+// Snapshot returns the totals and limits for the debug JSON
+func (u *UsageTracker) Snapshot() map[string]interface{} {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	days := make(map[string]UsageTotals, len(u.data.Days))
+	for day, totals := range u.data.Days {
+		days[day] = *totals
+	}
+	hosts := make(map[string]UsageTotals, len(u.data.Hosts))
+	for host, totals := range u.data.Hosts {
+		hosts[host] = *totals
+	}
+
+	var todayTotals UsageTotals
+	if totals := u.data.Days[today()]; totals != nil {
+		todayTotals = *totals
+	}
+
+	return map[string]interface{}{
+		"today":              todayTotals,
+		"days":               days,
+		"hosts":              hosts,
+		"max_daily_requests": u.maxRequests,
+		"max_daily_cost":     u.maxCost,
+	}
+}
+
+// prune drops daily totals beyond the retention window
+func (u *UsageTracker) prune() {
+	if len(u.data.Days) <= usageRetentionDays {
+		return
+	}
+	days := make([]string, 0, len(u.data.Days))
+	for day := range u.data.Days {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	for _, day := range days[:len(days)-usageRetentionDays] {
+		delete(u.data.Days, day)
+	}
+}
+
+// save writes the totals atomically
+func (u *UsageTracker) save() error {
+	data, err := json.MarshalIndent(u.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(u.filePath), 0755); err != nil {
+		return err
+	}
+
+	tmpFile := u.filePath + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, u.filePath)
+}
+
+// today returns the local date used to key daily totals
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
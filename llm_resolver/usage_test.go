@@ -0,0 +1,77 @@
+package llm_resolver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestUsageTrackerLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	usage := NewUsageTracker(path, 2, 0, zap.NewNop())
+
+	for i := 0; i < 2; i++ {
+		if err := usage.Reserve("blog.localhost"); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+	var limitErr *LimitError
+	if err := usage.Reserve("shop.localhost"); !errors.As(err, &limitErr) || limitErr.Limit != "requests" {
+		t.Fatalf("third request: got %v, want a request limit error", err)
+	}
+	if got := usage.Today().Requests; got != 2 {
+		t.Errorf("refused request was counted: %d requests", got)
+	}
+
+	// Totals survive a reload
+	reloaded := NewUsageTracker(path, 0, 0.01, zap.NewNop())
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Today().Requests; got != 2 {
+		t.Errorf("reloaded %d requests, want 2", got)
+	}
+
+	reloaded.Add("blog.localhost", Usage{InputTokens: 100, OutputTokens: 10, Cost: 0.02})
+	if err := reloaded.Reserve("blog.localhost"); !errors.As(err, &limitErr) || limitErr.Limit != "cost" {
+		t.Errorf("got %v, want a cost limit error", err)
+	}
+
+	hosts := reloaded.Snapshot()["hosts"].(map[string]UsageTotals)
+	if blog := hosts["blog.localhost"]; blog.Requests != 2 || blog.InputTokens != 100 || blog.Cost != 0.02 {
+		t.Errorf("blog.localhost totals = %+v", blog)
+	}
+}
+
+func TestCompleteCountsEveryRequest(t *testing.T) {
+	usage := NewUsageTracker(filepath.Join(t.TempDir(), "usage.json"), 2, 0, zap.NewNop())
+	unauthorized := &APIError{StatusCode: http.StatusUnauthorized}
+
+	// The failed primary and the fallback each count as a request
+	primary := &scriptedProvider{name: "primary", errs: []error{unauthorized}}
+	fallback := &scriptedProvider{name: "fallback", usage: Usage{InputTokens: 100, OutputTokens: 10}}
+	r := NewResolver([]Endpoint{{Provider: primary}, {Provider: fallback}}, ResolverOptions{Usage: usage}, zap.NewNop())
+
+	if _, err := r.complete(context.Background(), newResolution("blog.localhost", ""), &CompletionRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	today := usage.Today()
+	if today.Requests != 2 || today.InputTokens != 100 || today.OutputTokens != 10 {
+		t.Errorf("today = %+v, want 2 requests and the fallback's tokens", today)
+	}
+
+	// The limit is checked before every provider call, not once per resolution
+	primary = &scriptedProvider{name: "primary"}
+	r = NewResolver([]Endpoint{{Provider: primary}}, ResolverOptions{Usage: usage}, zap.NewNop())
+	var limitErr *LimitError
+	if _, err := r.complete(context.Background(), newResolution("blog.localhost", ""), &CompletionRequest{}); !errors.As(err, &limitErr) {
+		t.Errorf("got %v, want a limit error", err)
+	}
+	if primary.calls != 0 {
+		t.Errorf("provider was called %d times past the limit", primary.calls)
+	}
+}
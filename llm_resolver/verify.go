@@ -0,0 +1,194 @@
+package llm_resolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// askVerified calls the LLM and checks its answer against the discovery
+// snapshot. A rejected answer is re-asked once with the validation error
+// included; if the second answer is also rejected, an error is returned so
+// the unverifiable mapping is never persisted.
+func (r *Resolver) askVerified(
+	ctx context.Context,
+	res *Resolution,
+	systemPrompt, prompt string,
+	candidates Candidates,
+	processes []LocalProcess,
+	containers []DockerContainer,
+) (*LLMResponse, error) {
+	var schema *ResponseSchema
+	if len(candidates) > 0 {
+		schema = candidateSchema(candidates.IDs())
+	}
+
+	response, err := r.callLLM(ctx, res, systemPrompt, prompt, schema)
+	if err != nil {
+		return nil, err
+	}
+	reportProgress(ctx, StageValidating, "Checking the answer against discovery")
+
+	verifyErr := validateAnswer(res, response, candidates, processes, containers)
+	if verifyErr == nil {
+		return response, nil
+	}
+
+	r.logger.Warn("LLM answer rejected, asking again",
+		zap.String("candidate_id", response.CandidateID),
+		zap.String("type", response.Type),
+		zap.String("target", response.Target),
+		zap.Int("port", response.Port),
+		zap.Error(verifyErr),
+	)
+
+	reportProgress(ctx, StageValidating, fmt.Sprintf("Answer rejected (%v), asking again", verifyErr))
+
+	previous, _ := json.Marshal(response)
+	retryPrompt := fmt.Sprintf("%s\n## Previous Answer Rejected\nYour previous answer %s was rejected: %v\nChoose a candidate ID that appears in the lists above.\n",
+		prompt, previous, verifyErr)
+
+	response, err = r.callLLM(ctx, res, systemPrompt, retryPrompt, schema)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAnswer(res, response, candidates, processes, containers); err != nil {
+		return nil, fmt.Errorf("invalid LLM response after retry: %w", err)
+	}
+
+	return response, nil
+}
+
+// validateAnswer fills in the chosen candidate and verifies the answer,
+// recording the outcome on res
+func validateAnswer(res *Resolution, response *LLMResponse, candidates Candidates, processes []LocalProcess, containers []DockerContainer) error {
+	err := applyCandidate(response, candidates)
+	if err == nil {
+		err = verifyResponse(response, processes, containers)
+	}
+	answer, _ := json.Marshal(response)
+	res.check(string(answer), err)
+	return err
+}
+
+// applyCandidate fills the response target fields from the chosen candidate's
+// discovery record, discarding anything the model typed itself
+func applyCandidate(response *LLMResponse, candidates Candidates) error {
+	if response.CandidateID == "" {
+		return nil
+	}
+	if response.CandidateID == noneCandidateID {
+		response.Type = noneType
+		response.Target = ""
+		response.Port = 0
+		response.Workdir = ""
+		response.CommandPattern = ""
+		return nil
+	}
+
+	candidate := candidates.Find(response.CandidateID)
+	if candidate == nil {
+		return fmt.Errorf("unknown candidate_id %q", response.CandidateID)
+	}
+
+	mapping := candidate.Mapping()
+	response.Type = mapping.Type
+	response.Target = mapping.Target
+	response.Port = mapping.Port
+	response.Workdir = ""
+	response.CommandPattern = ""
+	if mapping.ProcessIdentifier != nil {
+		response.Workdir = mapping.ProcessIdentifier.Workdir
+	}
+	return nil
+}
+
+// verifyResponse validates the response structure and checks that it
+// points at a discovered target
+func verifyResponse(response *LLMResponse, processes []LocalProcess, containers []DockerContainer) error {
+	// "No match" is a valid answer; it is turned into an error later
+	if response.Type == noneType {
+		return nil
+	}
+	if err := validateLLMResponse(response); err != nil {
+		return err
+	}
+	// Candidate answers were filled in from the discovery record itself
+	if response.CandidateID != "" {
+		return nil
+	}
+	if response.Type == "process" {
+		return verifyProcess(response, processes)
+	}
+	return verifyContainer(response, containers)
+}
+
+// verifyMapping checks that the target of a persisted mapping is still
+// running. Process mappings with an identifier may have moved to another port.
+func verifyMapping(mapping *RouteMapping, processes []LocalProcess, containers []DockerContainer) error {
+	if mapping.Type != "process" {
+		return verifyContainer(&LLMResponse{Target: mapping.Target, Port: mapping.Port}, containers)
+	}
+
+	for _, proc := range processes {
+		if id := mapping.ProcessIdentifier; id != nil {
+			if matchesWorkdir(proc.Workdir, id.Workdir) && (id.CommandPattern == "" || matchesCommand(proc, id.CommandPattern)) {
+				return nil
+			}
+		} else if proc.Port == mapping.Port {
+			return nil
+		}
+	}
+	if mapping.ProcessIdentifier != nil {
+		return fmt.Errorf("no discovered process runs in %q", mapping.ProcessIdentifier.Workdir)
+	}
+	return fmt.Errorf("no discovered process listens on port %d", mapping.Port)
+}
+
+// verifyProcess checks that a discovered process listens on the chosen port
+// and runs in the chosen workdir
+func verifyProcess(response *LLMResponse, processes []LocalProcess) error {
+	if response.Workdir == "" {
+		return fmt.Errorf("workdir is required for process targets")
+	}
+
+	for _, proc := range processes {
+		if proc.Port != response.Port {
+			continue
+		}
+		if !matchesWorkdir(proc.Workdir, response.Workdir) {
+			return fmt.Errorf("process on port %d runs in %q, not %q", proc.Port, proc.Workdir, response.Workdir)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("no discovered process listens on port %d", response.Port)
+}
+
+// verifyContainer checks that the chosen container exists and exposes the chosen port
+func verifyContainer(response *LLMResponse, containers []DockerContainer) error {
+	for _, container := range containers {
+		if container.Name != response.Target && !(len(response.Target) >= 12 && strings.HasPrefix(container.ID, response.Target)) {
+			continue
+		}
+
+		var exposed []string
+		for _, p := range container.Ports {
+			if p == response.Port {
+				return nil
+			}
+			exposed = append(exposed, fmt.Sprintf("%d", p))
+		}
+		for _, pm := range container.PortMappings {
+			if pm.ContainerPort == response.Port {
+				return nil
+			}
+		}
+		return fmt.Errorf("container %q does not expose port %d (exposed: %s)", container.Name, response.Port, strings.Join(exposed, ", "))
+	}
+
+	return fmt.Errorf("no discovered container named %q", response.Target)
+}
@@ -0,0 +1,125 @@
+package llm_resolver
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/contember/tudy/llm_resolver/discovery"
+	"go.uber.org/zap"
+)
+
+// replyProvider answers with replies in order and records every user prompt
+type replyProvider struct {
+	replies []string
+	prompts []string
+}
+
+func (p *replyProvider) Name() string  { return "reply" }
+func (p *replyProvider) Model() string { return "test-model" }
+
+func (p *replyProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	p.prompts = append(p.prompts, req.UserPrompt)
+	reply := p.replies[0]
+	if len(p.replies) > 1 {
+		p.replies = p.replies[1:]
+	}
+	return &CompletionResponse{Content: reply}, nil
+}
+
+var (
+	verifyProcesses = []LocalProcess{
+		{Port: 3000, Command: "node", Workdir: "/home/dev/projects/blog"},
+	}
+	verifyContainers = []DockerContainer{
+		{ID: "0123456789abcdef", Name: "shop-postgres-1", Ports: []int{5432}},
+		{ID: "fedcba9876543210", Name: "web", PortMappings: []discovery.PortMapping{{HostPort: 8080, ContainerPort: 80}}},
+	}
+)
+
+func TestVerifyResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response LLMResponse
+		wantErr  string // "" when the answer must be accepted
+	}{
+		{"process", LLMResponse{Type: "process", Target: "localhost", Port: 3000, Workdir: "/home/dev/projects/blog"}, ""},
+		{"process in parent workdir", LLMResponse{Type: "process", Target: "localhost", Port: 3000, Workdir: "/home/dev/projects/blog/"}, ""},
+		{"container by name", LLMResponse{Type: "docker", Target: "shop-postgres-1", Port: 5432}, ""},
+		{"container by ID prefix", LLMResponse{Type: "docker", Target: "0123456789ab", Port: 5432}, ""},
+		{"container port mapping", LLMResponse{Type: "docker", Target: "web", Port: 80}, ""},
+
+		{"unknown type", LLMResponse{Type: "vm", Target: "x", Port: 80}, "type must be"},
+		{"port out of range", LLMResponse{Type: "docker", Target: "web", Port: 70000}, "port must be"},
+		{"process without workdir", LLMResponse{Type: "process", Target: "localhost", Port: 3000}, "workdir is required"},
+		{"process on unknown port", LLMResponse{Type: "process", Target: "localhost", Port: 4000, Workdir: "/home/dev/projects/blog"}, "no discovered process"},
+		{"process in other workdir", LLMResponse{Type: "process", Target: "localhost", Port: 3000, Workdir: "/home/dev/projects/shop"}, "runs in"},
+		{"unknown container", LLMResponse{Type: "docker", Target: "redis", Port: 6379}, "no discovered container"},
+		{"short ID prefix", LLMResponse{Type: "docker", Target: "0123", Port: 5432}, "no discovered container"},
+		{"container port not exposed", LLMResponse{Type: "docker", Target: "shop-postgres-1", Port: 80}, "does not expose"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyResponse(&tt.response, verifyProcesses, verifyContainers)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAskVerified(t *testing.T) {
+	invalid := `{"type":"docker","target":"redis","port":6379}`
+	valid := `{"type":"docker","target":"shop-postgres-1","port":5432}`
+
+	t.Run("rejected answer is asked again", func(t *testing.T) {
+		provider := &replyProvider{replies: []string{invalid, valid}}
+		r := NewResolver([]Endpoint{{Provider: provider}}, ResolverOptions{}, zap.NewNop())
+
+		response, err := r.askVerified(context.Background(), nil, "system", "prompt", nil, verifyProcesses, verifyContainers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Target != "shop-postgres-1" {
+			t.Errorf("target = %q, want shop-postgres-1", response.Target)
+		}
+		if len(provider.prompts) != 2 || !strings.Contains(provider.prompts[1], "Previous Answer Rejected") {
+			t.Errorf("second prompt does not explain the rejection: %q", provider.prompts)
+		}
+	})
+
+	t.Run("second rejection fails", func(t *testing.T) {
+		provider := &replyProvider{replies: []string{invalid}}
+		r := NewResolver([]Endpoint{{Provider: provider}}, ResolverOptions{}, zap.NewNop())
+
+		if _, err := r.askVerified(context.Background(), nil, "system", "prompt", nil, verifyProcesses, verifyContainers); err == nil {
+			t.Error("got no error")
+		}
+		if len(provider.prompts) != 2 {
+			t.Errorf("asked %d times, want 2", len(provider.prompts))
+		}
+	})
+	t.Run("candidate answer", func(t *testing.T) {
+		provider := &replyProvider{replies: []string{`{"candidate_id":"x9","reason":"guess"}`, `{"candidate_id":"d1","reason":"database"}`}}
+		r := NewResolver([]Endpoint{{Provider: provider}}, ResolverOptions{}, zap.NewNop())
+
+		candidates := buildCandidates(verifyProcesses, verifyContainers)
+		response, err := r.askVerified(context.Background(), nil, "system", "prompt", candidates, verifyProcesses, verifyContainers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Type != "docker" || response.Target != "shop-postgres-1" || response.Port != 5432 {
+			t.Errorf("got %s %s:%d, want docker shop-postgres-1:5432", response.Type, response.Target, response.Port)
+		}
+		if len(provider.prompts) != 2 {
+			t.Errorf("asked %d times, want 2", len(provider.prompts))
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package llm_resolver
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// resolveSpeculatively starts resolving a hostname that on-demand TLS is
+// about to issue a certificate for, so the mapping is ready (or in flight)
+// when the HTTPS request arrives
+func (m *LLMResolver) resolveSpeculatively(ctx context.Context, hostname string) {
+	if hostname == "proxy.localhost" || m.cache.Get(hostname) != nil || m.hostFilter.Check(hostname, false) != nil {
+		return
+	}
+
+	m.logger.Debug("resolving ahead of the TLS handshake", zap.String("hostname", hostname))
+	m.resolveGroup.Start(ctx, hostname, time.Duration(m.ResolveTimeout), m.resolveHostname(hostname, false, ""))
+}
+
+// warmup re-verifies every persisted mapping at startup and re-resolves the
+// ones whose target is gone, including mappings already marked stale before
+// the restart. Manual and picked mappings are only marked. A failed
+// re-resolution keeps the old mapping. It runs once per shared state and
+// stops when ctx (the Caddy config) is done.
+func (m *LLMResolver) warmup(ctx context.Context) {
+	stats, err := m.verifyMappings(ctx, true)
+	if err != nil {
+		m.logger.Warn("warmup stopped", zap.Error(err))
+		return
+	}
+
+	m.logger.Info("warmup finished",
+		zap.Int("verified", stats.verified),
+		zap.Int("stale", stats.stale),
+		zap.Int("reresolved", stats.reresolved),
+		zap.Int("failed", stats.failed),
+	)
+}